@@ -9,6 +9,7 @@ import (
 	"syscall"
 
 	"github.com/ishaan29/vectorDB/internal/api"
+	"github.com/ishaan29/vectorDB/internal/api/grpcserver"
 	"github.com/ishaan29/vectorDB/internal/config"
 	"github.com/ishaan29/vectorDB/internal/engine"
 	"github.com/ishaan29/vectorDB/internal/logger"
@@ -35,7 +36,8 @@ func main() {
 	log_instance.Info("Starting VectorDB HTTP Server",
 		logger.String("config", configPath),
 		logger.String("host", cfg.Server.Host),
-		logger.Int("port", cfg.Server.Port))
+		logger.Int("port", cfg.Server.Port),
+		logger.Int("grpc_port", cfg.Server.GRPCPort))
 
 	// Initialize engine
 	eng, err := engine.NewEngine(cfg, log_instance)
@@ -58,8 +60,9 @@ func main() {
 
 	log_instance.Info("Engine started successfully")
 
-	// Create and start HTTP server
+	// Create HTTP and gRPC servers
 	server := api.NewServer(eng, log_instance, cfg)
+	grpcServer := grpcserver.NewServer(eng, log_instance, cfg)
 
 	// Handle graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -72,6 +75,11 @@ func main() {
 			log_instance.Error("HTTP server error", logger.Error("error", err))
 		}
 	}()
+	go func() {
+		if err := grpcServer.Start(serverCtx); err != nil {
+			log_instance.Error("gRPC server error", logger.Error("error", err))
+		}
+	}()
 
 	// Wait for shutdown signal
 	<-quit
@@ -80,9 +80,10 @@ func main() {
 		Embedding: testVectors[0].Embedding, // Search with first vector
 	}
 
+	threshold := float32(0.5)
 	results, err := eng.Search(query, engine.SearchParams{
 		K:           5,
-		Threshold:   0.5,
+		Threshold:   &threshold,
 		IncludeVecs: false,
 		IncludeMeta: true,
 	})
@@ -0,0 +1,70 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ishaan29/vectorDB/internal/config"
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	pb "github.com/ishaan29/vectorDB/proto"
+	"google.golang.org/grpc"
+)
+
+const defaultGRPCPort = 9090
+
+// Server exposes the engine over gRPC. It mirrors api.Server and runs next
+// to it on server.grpc_port.
+type Server struct {
+	engine     *engine.Engine
+	logger     logger.Logger
+	config     *config.Config
+	grpcServer *grpc.Server
+}
+
+func NewServer(eng *engine.Engine, log logger.Logger, cfg *config.Config) *Server {
+	s := &Server{
+		engine:     eng,
+		logger:     log,
+		config:     cfg,
+		grpcServer: grpc.NewServer(),
+	}
+
+	pb.RegisterVectorDBServer(s.grpcServer, &service{engine: eng, logger: log})
+	return s
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	port := s.config.Server.GRPCPort
+	if port == 0 {
+		port = defaultGRPCPort
+	}
+	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, port)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.logger.Info("Starting gRPC server",
+		logger.String("address", addr))
+
+	go func() {
+		if err := s.grpcServer.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			s.logger.Error("gRPC server failed", logger.Error("error", err))
+		}
+	}()
+
+	<-ctx.Done()
+
+	s.logger.Info("Shutting down gRPC server...")
+	s.grpcServer.GracefulStop()
+	s.logger.Info("gRPC server stopped")
+	return nil
+}
+
+func (s *Server) Stop() error {
+	s.grpcServer.GracefulStop()
+	return nil
+}
@@ -0,0 +1,480 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/filter"
+	"github.com/ishaan29/vectorDB/pkg/types"
+	pb "github.com/ishaan29/vectorDB/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// bulkInsertBatchSize is how many streamed vectors BulkInsert buffers
+// before writing them to the collection.
+const bulkInsertBatchSize = 500
+
+// service implements pb.VectorDBServer on top of the engine, following the
+// same rules as the REST handlers.
+type service struct {
+	pb.UnimplementedVectorDBServer
+	engine *engine.Engine
+	logger logger.Logger
+}
+
+// collection resolves a request's collection name, falling back to the
+// default collection when it is empty.
+func (s *service) collection(name string) (*engine.Collection, error) {
+	if name == "" {
+		name = engine.DefaultCollection
+	}
+
+	coll, err := s.engine.Collection(name)
+	if err != nil {
+		if errors.Is(err, engine.ErrNoSuchCollection) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return coll, nil
+}
+
+func (s *service) Insert(ctx context.Context, req *pb.InsertRequest) (*pb.InsertResponse, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetVector() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing vector")
+	}
+
+	vector := fromProtoVector(req.GetVector())
+	lsn, created, err := coll.Upsert(vector, req.GetIfAbsent())
+	if err != nil {
+		s.logger.Error("Failed to insert vector",
+			logger.String("id", vector.ID),
+			logger.Error("error", err))
+		return nil, engineError(err)
+	}
+
+	return &pb.InsertResponse{Success: true, Lsn: lsn, Created: created}, nil
+}
+
+func (s *service) UpdateMetadata(ctx context.Context, req *pb.UpdateMetadataRequest) (*pb.UpdateMetadataResponse, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing vector ID")
+	}
+	if req.GetMetadata() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing metadata")
+	}
+
+	lsn, metadata, err := coll.PatchMetadata(req.GetId(), req.GetMetadata().AsMap())
+	if err != nil {
+		s.logger.Error("Failed to update vector metadata",
+			logger.String("id", req.GetId()),
+			logger.Error("error", err))
+		return nil, engineError(err)
+	}
+
+	resp := &pb.UpdateMetadataResponse{Success: true, Lsn: lsn}
+	if len(metadata) > 0 {
+		if resp.Metadata, err = toProtoStruct(metadata); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// engineError maps the errors of collection reads and writes to gRPC
+// statuses.
+func engineError(err error) error {
+	switch {
+	case engine.IsInvalidInput(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, engine.ErrVectorExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, engine.ErrVectorNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, engine.ErrCollectionClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (s *service) BatchInsert(ctx context.Context, req *pb.BatchInsertRequest) (*pb.BatchInsertResponse, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetVectors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty vectors array")
+	}
+
+	start := time.Now()
+
+	vectors := make([]types.Vector, len(req.GetVectors()))
+	for i, v := range req.GetVectors() {
+		vectors[i] = fromProtoVector(v)
+	}
+
+	lsn, err := coll.BatchInsert(vectors)
+	if err != nil {
+		s.logger.Error("Batch insert failed",
+			logger.Int("count", len(vectors)),
+			logger.Error("error", err))
+		return nil, engineError(err)
+	}
+
+	return &pb.BatchInsertResponse{
+		Success:  true,
+		Inserted: int32(len(vectors)),
+		TookMs:   time.Since(start).Milliseconds(),
+		Lsn:      lsn,
+	}, nil
+}
+
+// BulkInsert receives vectors until the client closes the stream and
+// writes them in batches. The first message decides the collection.
+func (s *service) BulkInsert(stream pb.VectorDB_BulkInsertServer) error {
+	start := time.Now()
+
+	var (
+		coll     *engine.Collection
+		batch    []types.Vector
+		inserted int32
+		failed   int32
+		lastLSN  uint64
+	)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if lsn, err := coll.BatchInsert(batch); err != nil {
+			s.logger.Error("Bulk insert batch failed",
+				logger.Int("count", len(batch)),
+				logger.Error("error", err))
+			failed += int32(len(batch))
+		} else {
+			inserted += int32(len(batch))
+			lastLSN = lsn
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if coll == nil {
+			if coll, err = s.collection(req.GetCollection()); err != nil {
+				return err
+			}
+		}
+		if req.GetVector() == nil {
+			failed++
+			continue
+		}
+
+		batch = append(batch, fromProtoVector(req.GetVector()))
+		if len(batch) >= bulkInsertBatchSize {
+			flush()
+		}
+	}
+	if coll != nil {
+		flush()
+	}
+
+	return stream.SendAndClose(&pb.BatchInsertResponse{
+		Success:  failed == 0,
+		Inserted: inserted,
+		Failed:   failed,
+		TookMs:   time.Since(start).Milliseconds(),
+		Lsn:      lastLSN,
+	})
+}
+
+func (s *service) Get(ctx context.Context, req *pb.GetRequest) (*pb.Vector, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing vector ID")
+	}
+
+	vector, found := coll.Get(req.GetId())
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "vector with ID %s does not exist", req.GetId())
+	}
+	return toProtoVector(vector, true, true), nil
+}
+
+func (s *service) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing vector ID")
+	}
+
+	lsn, err := coll.Delete(req.GetId())
+	if err != nil {
+		s.logger.Error("Failed to delete vector",
+			logger.String("id", req.GetId()),
+			logger.Error("error", err))
+		return nil, engineError(err)
+	}
+	return &pb.DeleteResponse{Success: true, Lsn: lsn}, nil
+}
+
+func (s *service) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	start := time.Now()
+
+	results, err := s.search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.SearchResponse{
+		Results: make([]*pb.SearchResult, len(results)),
+		Total:   int32(len(results)),
+	}
+	for i, r := range results {
+		response.Results[i] = toProtoSearchResult(r, req.GetIncludeVectors(), req.GetIncludeMetadata())
+	}
+	response.TookMs = time.Since(start).Milliseconds()
+	return response, nil
+}
+
+// SearchStream runs the same search as Search but sends each result as its
+// own message, best match first.
+func (s *service) SearchStream(req *pb.SearchRequest, stream pb.VectorDB_SearchStreamServer) error {
+	results, err := s.search(req)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err := stream.Send(toProtoSearchResult(r, req.GetIncludeVectors(), req.GetIncludeMetadata())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) search(req *pb.SearchRequest) ([]types.SearchResult, error) {
+	coll, err := s.collection(req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetK() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "k must be at least 1")
+	}
+	if req.GetEf() < 0 || req.GetEf() > 1000 {
+		return nil, status.Error(codes.InvalidArgument, "ef must be between 1 and 1000, or 0 for the collection's ef_search")
+	}
+	if req.GetNprobe() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "nprobe must be at least 1, or 0 for the collection's nprobe")
+	}
+
+	f, err := fromProtoFilter(req.GetFilter())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+	}
+
+	params := engine.SearchParams{
+		K:           int(req.GetK()),
+		Threshold:   req.Threshold,
+		IncludeVecs: req.GetIncludeVectors(),
+		IncludeMeta: req.GetIncludeMetadata(),
+		Filter:      f,
+		Ef:          int(req.GetEf()),
+		NProbe:      int(req.GetNprobe()),
+		Exact:       req.GetExact(),
+	}
+
+	results, err := coll.Search(types.Vector{Embedding: req.GetEmbedding()}, params)
+	if err != nil {
+		s.logger.Error("Search failed",
+			logger.Int("k", params.K),
+			logger.Error("error", err))
+		return nil, engineError(err)
+	}
+	return results, nil
+}
+
+func (s *service) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	start := time.Now()
+
+	stats, err := toProtoStruct(s.engine.Stats())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.StatsResponse{
+		Stats:  stats,
+		TookMs: time.Since(start).Milliseconds(),
+	}, nil
+}
+
+func (s *service) Health(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	stats := s.engine.Stats()
+
+	health := "ok"
+	if !stats["running"].(bool) {
+		health = "engine_not_running"
+	}
+
+	pbStats, err := toProtoStruct(stats)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.HealthResponse{
+		Status:  health,
+		Engine:  "vectordb",
+		Version: "1.0.0",
+		Stats:   pbStats,
+	}, nil
+}
+
+func fromProtoVector(v *pb.Vector) types.Vector {
+	vector := types.Vector{
+		ID:        v.GetId(),
+		Embedding: v.GetEmbedding(),
+		Text:      v.GetText(),
+	}
+	if v.GetMetadata() != nil {
+		vector.Metadata = v.GetMetadata().AsMap()
+	}
+	if v.GetSparse() != nil {
+		vector.Sparse = &types.SparseVector{Indices: v.GetSparse().GetIndices(), Values: v.GetSparse().GetValues()}
+	}
+	if len(v.GetVectors()) > 0 {
+		vector.Vectors = make(map[string][]float32, len(v.GetVectors()))
+		for name, embedding := range v.GetVectors() {
+			vector.Vectors[name] = embedding.GetValues()
+		}
+	}
+	if len(v.GetMultivectors()) > 0 {
+		vector.MultiVectors = make(map[string][][]float32, len(v.GetMultivectors()))
+		for name, multi := range v.GetMultivectors() {
+			tokens := make([][]float32, len(multi.GetTokens()))
+			for i, token := range multi.GetTokens() {
+				tokens[i] = token.GetValues()
+			}
+			vector.MultiVectors[name] = tokens
+		}
+	}
+	return vector
+}
+
+// toProtoVector converts a stored vector. includeEmbedding covers all the
+// parts IncludeVecs does: the embeddings, text and sparse vector.
+func toProtoVector(v types.Vector, includeEmbedding, includeMetadata bool) *pb.Vector {
+	vector := &pb.Vector{Id: v.ID}
+	if includeEmbedding {
+		vector.Embedding = v.Embedding
+		vector.Text = v.Text
+		if v.Sparse != nil {
+			vector.Sparse = &pb.SparseVector{Indices: v.Sparse.Indices, Values: v.Sparse.Values}
+		}
+		if len(v.Vectors) > 0 {
+			vector.Vectors = make(map[string]*pb.Embedding, len(v.Vectors))
+			for name, embedding := range v.Vectors {
+				vector.Vectors[name] = &pb.Embedding{Values: embedding}
+			}
+		}
+		if len(v.MultiVectors) > 0 {
+			vector.Multivectors = make(map[string]*pb.MultiVector, len(v.MultiVectors))
+			for name, tokens := range v.MultiVectors {
+				multi := &pb.MultiVector{Tokens: make([]*pb.Embedding, len(tokens))}
+				for i, token := range tokens {
+					multi.Tokens[i] = &pb.Embedding{Values: token}
+				}
+				vector.Multivectors[name] = multi
+			}
+		}
+	}
+	if includeMetadata && v.Metadata != nil {
+		// Metadata read back from the store is plain JSON, so this only
+		// fails for values a client could not have sent in the first place
+		if metadata, err := toProtoStruct(v.Metadata); err == nil {
+			vector.Metadata = metadata
+		}
+	}
+	return vector
+}
+
+func toProtoSearchResult(r types.SearchResult, includeVectors, includeMetadata bool) *pb.SearchResult {
+	result := &pb.SearchResult{
+		Id:       r.Vector.ID,
+		Score:    r.Score,
+		Distance: r.Distance,
+	}
+	if includeVectors || includeMetadata {
+		result.Vector = toProtoVector(r.Vector, includeVectors, includeMetadata)
+	}
+	return result
+}
+
+// fromProtoFilter decodes a filter sent as a Struct. It goes through JSON
+// so gRPC and REST clients share exactly one filter syntax.
+func fromProtoFilter(s *structpb.Struct) (*filter.Filter, error) {
+	if s == nil || len(s.GetFields()) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return nil, err
+	}
+	var f filter.Filter
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// toProtoStruct converts a map of JSON-like values; anything structpb
+// doesn't know natively is normalized through JSON first.
+func toProtoStruct(m map[string]interface{}) (*structpb.Struct, error) {
+	if s, err := structpb.NewStruct(m); err == nil {
+		return s, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(normalized)
+}
@@ -0,0 +1,214 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/internal/config"
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	pb "github.com/ishaan29/vectorDB/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestClient serves the service for a fresh engine over an in-memory
+// connection.
+func newTestClient(t *testing.T) (pb.VectorDBClient, *engine.Engine) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{Path: dir},
+		Index:   config.IndexConfig{Type: "hnsw", Dimensions: 3},
+		Badger:  config.BadgerConfig{Path: dir},
+	}
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+
+	eng, err := engine.NewEngine(cfg, log)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if err := eng.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start engine: %v", err)
+	}
+	t.Cleanup(func() { eng.Stop() })
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterVectorDBServer(server, &service{engine: eng, logger: log})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewVectorDBClient(conn), eng
+}
+
+func TestService(t *testing.T) {
+	client, eng := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := eng.CreateCollection(engine.CollectionConfig{Name: "docs", Dimensions: 2, Metric: "euclidean",
+		Vectors: map[string]engine.VectorConfig{
+			"title":  {Dimensions: 2, Metric: "euclidean"},
+			"tokens": {Dimensions: 2, Metric: "dot", Multivector: true},
+		}})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	code := func(err error) codes.Code { return status.Code(err) }
+
+	t.Run("Insert", func(t *testing.T) {
+		metadata, _ := structpb.NewStruct(map[string]interface{}{"lang": "go"})
+		vector := &pb.Vector{
+			Id:        "a",
+			Embedding: []float32{1, 0},
+			Metadata:  metadata,
+			Text:      "hello world",
+			Sparse:    &pb.SparseVector{Indices: []uint32{3, 7}, Values: []float32{0.5, 1}},
+			Vectors:   map[string]*pb.Embedding{"title": {Values: []float32{0, 1}}},
+			Multivectors: map[string]*pb.MultiVector{"tokens": {Tokens: []*pb.Embedding{
+				{Values: []float32{1, 0}}, {Values: []float32{0, 1}},
+			}}},
+		}
+		resp, err := client.Insert(ctx, &pb.InsertRequest{Collection: "docs", Vector: vector})
+		if err != nil || !resp.GetCreated() || resp.GetLsn() == 0 {
+			t.Fatalf("Expected a to be created, got %v (err %v)", resp, err)
+		}
+
+		// Every part of the vector is stored, not just the embedding
+		got, err := client.Get(ctx, &pb.GetRequest{Collection: "docs", Id: "a"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.GetText() != "hello world" || fmt.Sprint(got.GetSparse().GetIndices()) != "[3 7]" ||
+			fmt.Sprint(got.GetVectors()["title"].GetValues()) != "[0 1]" ||
+			len(got.GetMultivectors()["tokens"].GetTokens()) != 2 ||
+			got.GetMetadata().AsMap()["lang"] != "go" {
+			t.Errorf("Expected a back whole, got %v", got)
+		}
+
+		resp, err = client.Insert(ctx, &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "a", Embedding: []float32{1, 1}}})
+		if err != nil || resp.GetCreated() {
+			t.Errorf("Expected a to be replaced, got %v (err %v)", resp, err)
+		}
+		if got, _ := client.Get(ctx, &pb.GetRequest{Collection: "docs", Id: "a"}); got.GetText() != "" || got.GetVectors() != nil {
+			t.Errorf("Expected the replacement to drop the parts it left out, got %v", got)
+		}
+	})
+
+	t.Run("InsertErrors", func(t *testing.T) {
+		cases := []struct {
+			name string
+			req  *pb.InsertRequest
+			want codes.Code
+		}{
+			{"exists", &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "a", Embedding: []float32{1, 0}}, IfAbsent: true}, codes.AlreadyExists},
+			{"dimensions", &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "b", Embedding: []float32{1, 0, 0}}}, codes.InvalidArgument},
+			{"named dimensions", &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "b", Embedding: []float32{1, 0},
+				Vectors: map[string]*pb.Embedding{"title": {Values: []float32{1}}}}}, codes.InvalidArgument},
+			{"unknown vector", &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "b", Embedding: []float32{1, 0},
+				Vectors: map[string]*pb.Embedding{"body": {Values: []float32{1, 0}}}}}, codes.InvalidArgument},
+			{"sparse", &pb.InsertRequest{Collection: "docs", Vector: &pb.Vector{Id: "b", Embedding: []float32{1, 0},
+				Sparse: &pb.SparseVector{Indices: []uint32{1, 1}, Values: []float32{1, 2}}}}, codes.InvalidArgument},
+			{"missing vector", &pb.InsertRequest{Collection: "docs"}, codes.InvalidArgument},
+			{"unknown collection", &pb.InsertRequest{Collection: "missing", Vector: &pb.Vector{Id: "b"}}, codes.NotFound},
+		}
+		for _, tc := range cases {
+			if _, err := client.Insert(ctx, tc.req); code(err) != tc.want {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+			}
+		}
+	})
+
+	t.Run("BatchInsert", func(t *testing.T) {
+		resp, err := client.BatchInsert(ctx, &pb.BatchInsertRequest{Collection: "docs", Vectors: []*pb.Vector{
+			{Id: "b", Embedding: []float32{0, 1}},
+			{Id: "c", Embedding: []float32{-1, 0}, Text: "third"},
+		}})
+		if err != nil || resp.GetInserted() != 2 {
+			t.Fatalf("Expected 2 inserted, got %v (err %v)", resp, err)
+		}
+
+		// A batch with one bad vector is rejected whole
+		_, err = client.BatchInsert(ctx, &pb.BatchInsertRequest{Collection: "docs", Vectors: []*pb.Vector{
+			{Id: "d", Embedding: []float32{0, 1}},
+			{Id: "e", Embedding: []float32{1}},
+		}})
+		if code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument for a bad vector, got %v", err)
+		}
+		if _, err := client.Get(ctx, &pb.GetRequest{Collection: "docs", Id: "d"}); code(err) != codes.NotFound {
+			t.Errorf("Expected d not to be written, got %v", err)
+		}
+		if _, err := client.BatchInsert(ctx, &pb.BatchInsertRequest{Collection: "docs"}); code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument for an empty batch, got %v", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		resp, err := client.Search(ctx, &pb.SearchRequest{Collection: "docs", Embedding: []float32{1, 0}, K: 3, IncludeVectors: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var ids []string
+		for _, r := range resp.GetResults() {
+			ids = append(ids, r.GetId())
+		}
+		if fmt.Sprint(ids) != "[a b c]" || resp.GetResults()[2].GetVector().GetText() != "third" {
+			t.Errorf("Expected [a b c] with their vectors, got %v", resp.GetResults())
+		}
+
+		filter, _ := structpb.NewStruct(map[string]interface{}{"field": "lang", "op": "nope", "value": "go"})
+		cases := []struct {
+			name string
+			req  *pb.SearchRequest
+			want codes.Code
+		}{
+			{"k", &pb.SearchRequest{Collection: "docs", Embedding: []float32{1, 0}}, codes.InvalidArgument},
+			{"dimensions", &pb.SearchRequest{Collection: "docs", Embedding: []float32{1, 0, 0}, K: 1}, codes.InvalidArgument},
+			{"ef", &pb.SearchRequest{Collection: "docs", Embedding: []float32{1, 0}, K: 1, Ef: 1001}, codes.InvalidArgument},
+			{"filter", &pb.SearchRequest{Collection: "docs", Embedding: []float32{1, 0}, K: 1, Filter: filter}, codes.InvalidArgument},
+			{"unknown collection", &pb.SearchRequest{Collection: "missing", Embedding: []float32{1, 0}, K: 1}, codes.NotFound},
+		}
+		for _, tc := range cases {
+			if _, err := client.Search(ctx, tc.req); code(err) != tc.want {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp, err := client.Delete(ctx, &pb.DeleteRequest{Collection: "docs", Id: "b"})
+		if err != nil || !resp.GetSuccess() {
+			t.Fatalf("Expected b to be deleted, got %v (err %v)", resp, err)
+		}
+		if _, err := client.Get(ctx, &pb.GetRequest{Collection: "docs", Id: "b"}); code(err) != codes.NotFound {
+			t.Errorf("Expected b to be gone, got %v", err)
+		}
+		if _, err := client.Delete(ctx, &pb.DeleteRequest{Collection: "docs"}); code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument without an ID, got %v", err)
+		}
+	})
+
+	t.Run("DroppedCollection", func(t *testing.T) {
+		if err := eng.DropCollection("docs"); err != nil {
+			t.Fatalf("Failed to drop collection: %v", err)
+		}
+		if _, err := client.Delete(ctx, &pb.DeleteRequest{Collection: "docs", Id: "a"}); code(err) != codes.NotFound {
+			t.Errorf("Expected NotFound for a dropped collection, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ishaan29/vectorDB/internal/api/models"
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// collection resolves the :name route parameter, falling back to the
+// default collection for the un-prefixed routes. It writes the error
+// response itself and returns false if the collection can't be used.
+func (h *Handlers) collection(c *gin.Context) (*engine.Collection, bool) {
+	name := c.Param("name")
+	if name == "" {
+		name = engine.DefaultCollection
+	}
+
+	coll, err := h.engine.Collection(name)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, engine.ErrNoSuchCollection) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Collection unavailable",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return nil, false
+	}
+	return coll, true
+}
+
+func (h *Handlers) CreateCollection(c *gin.Context) {
+	var req models.CreateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	coll, err := h.engine.CreateCollection(engine.CollectionConfig{
+		Name:           req.Name,
+		Dimensions:     req.Dimensions,
+		Metric:         req.Metric,
+		IndexType:      req.IndexType,
+		M:              req.M,
+		EfConstruction: req.EfConstruction,
+		EfSearch:       req.EfSearch,
+		NList:          req.NList,
+		NProbe:         req.NProbe,
+		PQSubvectors:   req.PQSubvectors,
+		TrainSize:      req.TrainSize,
+		Quantization:   req.Quantization,
+		Rerank:         req.Rerank,
+		Vectors:        vectorConfigs(req.Vectors),
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, engine.ErrDuplicateCollection):
+			status = http.StatusConflict
+		case errors.Is(err, engine.ErrEngineNotRunning):
+			status = http.StatusServiceUnavailable
+		}
+
+		h.logger.Error("Failed to create collection",
+			logger.String("collection", req.Name),
+			logger.Error("error", err))
+
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Create collection failed",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ConvertCollection(coll.Config(), coll.Stats()))
+}
+
+func (h *Handlers) ListCollections(c *gin.Context) {
+	collections := h.engine.Collections()
+
+	response := models.CollectionListResponse{
+		Collections: make([]models.CollectionResponse, len(collections)),
+		Total:       len(collections),
+	}
+	for i, coll := range collections {
+		response.Collections[i] = models.ConvertCollection(coll.Config(), nil)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handlers) GetCollection(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ConvertCollection(coll.Config(), coll.Stats()))
+}
+
+func (h *Handlers) DropCollection(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := h.engine.DropCollection(name); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, engine.ErrNoSuchCollection):
+			status = http.StatusNotFound
+		case errors.Is(err, engine.ErrDropDefaultCollection):
+			status = http.StatusBadRequest
+		}
+
+		h.logger.Error("Failed to drop collection",
+			logger.String("collection", name),
+			logger.Error("error", err))
+
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Drop collection failed",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Collection dropped successfully",
+	})
+}
+
+// vectorConfigs converts the named vectors of a create request.
+func vectorConfigs(req map[string]models.VectorConfigRequest) map[string]engine.VectorConfig {
+	if len(req) == 0 {
+		return nil
+	}
+	vectors := make(map[string]engine.VectorConfig, len(req))
+	for name, v := range req {
+		vectors[name] = engine.VectorConfig{
+			Dimensions:     v.Dimensions,
+			Metric:         v.Metric,
+			IndexType:      v.IndexType,
+			M:              v.M,
+			EfConstruction: v.EfConstruction,
+			EfSearch:       v.EfSearch,
+			NList:          v.NList,
+			NProbe:         v.NProbe,
+			PQSubvectors:   v.PQSubvectors,
+			TrainSize:      v.TrainSize,
+			Quantization:   v.Quantization,
+			Rerank:         v.Rerank,
+			Multivector:    v.Multivector,
+		}
+	}
+	return vectors
+}
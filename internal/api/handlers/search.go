@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -12,6 +16,11 @@ import (
 )
 
 func (h *Handlers) SearchVectors(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
 	var req models.SearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -22,31 +31,44 @@ func (h *Handlers) SearchVectors(c *gin.Context) {
 		return
 	}
 
-	start := time.Now()
-
-	query := types.Vector{
-		ID:        "", // Anonymous query
-		Embedding: req.Embedding,
+	if req.Filter != nil {
+		if err := req.Filter.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid filter",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
 	}
 
-	params := engine.SearchParams{
-		K:           req.K,
-		Threshold:   req.Threshold,
-		IncludeVecs: req.IncludeVectors,
-		IncludeMeta: req.IncludeMetadata,
+	if err := validateQuery(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
 	}
 
-	results, err := h.engine.Search(query, params)
+	start := time.Now()
+
+	results, err := coll.Search(searchQuery(req), searchParams(req))
 	if err != nil {
 		h.logger.Error("Search failed",
 			logger.Int("k", req.K),
-			logger.Float64("threshold", float64(req.Threshold)),
+			logger.Bool("threshold", req.Threshold != nil),
+			logger.Bool("exact", req.Exact),
 			logger.Error("error", err))
 
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		status := http.StatusInternalServerError
+		if engine.IsInvalidInput(err) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
 			Error:   "Search failed",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    status,
 		})
 		return
 	}
@@ -65,17 +87,272 @@ func (h *Handlers) SearchVectors(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// BatchSearch runs many queries against a collection concurrently. A query
+// that fails reports its error in its own slot of the response; an invalid
+// query fails the whole request before any is run.
+func (h *Handlers) BatchSearch(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	var req models.BatchSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	queries := make([]engine.BatchQuery, len(req.Queries))
+	for i, q := range req.Queries {
+		if q.Filter != nil {
+			if err := q.Filter.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "Invalid filter",
+					Message: fmt.Sprintf("query %d: %v", i, err),
+					Code:    http.StatusBadRequest,
+				})
+				return
+			}
+		}
+		if err := validateQuery(q); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: fmt.Sprintf("query %d: %v", i, err),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		queries[i] = engine.BatchQuery{
+			Query:  searchQuery(q),
+			Params: searchParams(q),
+		}
+	}
+
+	start := time.Now()
+	batch := coll.SearchBatch(queries)
+
+	response := models.BatchSearchResponse{Results: make([]models.BatchSearchResult, len(batch))}
+	failed := 0
+	for i, b := range batch {
+		result := models.BatchSearchResult{
+			Results: make([]models.SearchResult, len(b.Results)),
+			Total:   len(b.Results),
+			TookMs:  float64(b.Took.Microseconds()) / 1000,
+		}
+		for j, r := range b.Results {
+			result.Results[j] = models.ConvertSearchResult(r, req.Queries[i].IncludeVectors, req.Queries[i].IncludeMetadata)
+		}
+		if b.Err != nil {
+			result.Error = b.Err.Error()
+			failed++
+		}
+		response.Results[i] = result
+	}
+	response.TookMs = time.Since(start).Milliseconds()
+
+	if failed > 0 {
+		h.logger.Warn("Batch search had failed queries",
+			logger.Int("queries", len(queries)),
+			logger.Int("failed", failed))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Recommend searches for vectors like the positive example IDs and unlike
+// the negative ones. Unknown example IDs answer 404.
+func (h *Handlers) Recommend(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	var req models.RecommendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if req.Filter != nil {
+		if err := req.Filter.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid filter",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	start := time.Now()
+
+	query := engine.RecommendQuery{
+		Positive: req.Positive,
+		Negative: req.Negative,
+		Strategy: engine.RecommendStrategy(req.Strategy),
+	}
+	results, err := coll.Recommend(query, searchParams(models.SearchRequest{
+		K:               req.K,
+		Threshold:       req.Threshold,
+		IncludeVectors:  req.IncludeVectors,
+		IncludeMetadata: req.IncludeMetadata,
+		Filter:          req.Filter,
+		Ef:              req.Ef,
+		NProbe:          req.NProbe,
+		Exact:           req.Exact,
+		Using:           req.Using,
+	}))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, engine.ErrVectorNotFound) {
+			status = http.StatusNotFound
+		} else if engine.IsInvalidInput(err) {
+			status = http.StatusBadRequest
+		} else {
+			h.logger.Error("Recommend failed",
+				logger.Int("positive", len(req.Positive)),
+				logger.Int("negative", len(req.Negative)),
+				logger.Error("error", err))
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Recommend failed",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	searchResults := make([]models.SearchResult, len(results))
+	for i, r := range results {
+		searchResults[i] = models.ConvertSearchResult(r, req.IncludeVectors, req.IncludeMetadata)
+	}
+
+	c.JSON(http.StatusOK, models.SearchResponse{
+		Results: searchResults,
+		TookMs:  time.Since(start).Milliseconds(),
+		Total:   len(searchResults),
+	})
+}
+
+// searchQuery builds the anonymous query vector of a search request. Tokens
+// go under the multi-vector they are searched against.
+func searchQuery(req models.SearchRequest) types.Vector {
+	query := types.Vector{Embedding: req.Embedding, Sparse: req.Sparse}
+	if len(req.MultiVector) > 0 {
+		query.MultiVectors = map[string][][]float32{req.Using: req.MultiVector}
+	}
+	return query
+}
+
+// searchParams maps a search request onto the engine's parameters.
+func searchParams(req models.SearchRequest) engine.SearchParams {
+	var fusion *engine.FusionParams
+	if req.Fusion != nil {
+		fusion = &engine.FusionParams{Method: engine.FusionMethod(req.Fusion.Method), Alpha: engine.DefaultFusionAlpha}
+		if req.Fusion.Alpha != nil {
+			fusion.Alpha = *req.Fusion.Alpha
+		}
+	}
+	var mmr *engine.MMRParams
+	if req.MMR != nil {
+		mmr = &engine.MMRParams{Lambda: engine.DefaultMMRLambda, FetchK: req.MMR.FetchK}
+		if req.MMR.Lambda != nil {
+			mmr.Lambda = *req.MMR.Lambda
+		}
+	}
+	return engine.SearchParams{
+		K:           req.K,
+		Threshold:   req.Threshold,
+		IncludeVecs: req.IncludeVectors,
+		IncludeMeta: req.IncludeMetadata,
+		Filter:      req.Filter,
+		Ef:          req.Ef,
+		NProbe:      req.NProbe,
+		Exact:       req.Exact,
+		MMR:         mmr,
+		Text:        req.Text,
+		Fusion:      fusion,
+		Using:       req.Using,
+	}
+}
+
+// validateQuery checks what binding tags can't express: that there is
+// something to search with and that MMR has k candidates to pick from.
+func validateQuery(req models.SearchRequest) error {
+	if len(req.Embedding) == 0 && len(req.MultiVector) == 0 && req.Text == "" && req.Sparse == nil {
+		return errors.New("embedding, multivector, text or sparse is required")
+	}
+	if req.MMR != nil && req.MMR.FetchK != 0 && req.MMR.FetchK < req.K {
+		return fmt.Errorf("mmr fetch_k %d is smaller than k %d", req.MMR.FetchK, req.K)
+	}
+	return nil
+}
+
+// Optimize starts a background job that rebuilds indexes carrying
+// tombstones (every index with force), retrains the ones fitted to the
+// data and compacts the store. It answers 202 with the job to poll at
+// /optimize/:id, or 409 with the running one. The body is optional, but
+// one that doesn't parse or names unknown fields is rejected rather than
+// taken for a request to optimize everything.
 func (h *Handlers) Optimize(c *gin.Context) {
 	var req models.OptimizeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		req.Force = false
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
 	}
 
 	h.logger.Info("Index optimization requested",
-		logger.Bool("force", req.Force))
+		logger.Bool("force", req.Force),
+		logger.String("collection", req.Collection))
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "Optimization completed",
-	})
+	var names []string
+	if req.Collection != "" {
+		names = []string{req.Collection}
+	}
+	job, err := h.engine.Optimize(names, req.Force)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		switch {
+		case errors.Is(err, engine.ErrNoSuchCollection):
+			status = http.StatusNotFound
+		case errors.Is(err, engine.ErrOptimizeInProgress):
+			c.JSON(http.StatusConflict, models.ConvertJob(job))
+			return
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Optimization unavailable",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.ConvertJob(job))
+}
+
+// OptimizeStatus reports the progress of an optimize job.
+func (h *Handlers) OptimizeStatus(c *gin.Context) {
+	job, ok := h.engine.OptimizeJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Job not found",
+			Message: c.Param("id"),
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ConvertJob(job))
 }
@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ishaan29/vectorDB/internal/api/models"
+	"github.com/ishaan29/vectorDB/internal/engine"
 	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/filter"
 	"github.com/ishaan29/vectorDB/pkg/types"
 )
 
 func (h *Handlers) InsertVector(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
 	var req models.InsertRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -21,32 +30,143 @@ func (h *Handlers) InsertVector(c *gin.Context) {
 		return
 	}
 
-	vector := types.Vector{
-		ID:        req.ID,
-		Embedding: req.Embedding,
-		Metadata:  req.Metadata,
+	h.upsert(c, coll, types.Vector{
+		ID:           req.ID,
+		Embedding:    req.Embedding,
+		Vectors:      req.Vectors,
+		MultiVectors: req.MultiVectors,
+		Metadata:     req.Metadata,
+		Text:         req.Text,
+		Sparse:       req.Sparse,
+	}, req.IfAbsent)
+}
+
+// PutVector replaces the vector named in the path, creating it if needed.
+// It answers 201 when the vector was created and 200 when it was replaced.
+func (h *Handlers) PutVector(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	var req models.PutVectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
 	}
 
-	if err := h.engine.Insert(vector); err != nil {
-		h.logger.Error("Failed to insert vector",
-			logger.String("id", req.ID),
+	h.upsert(c, coll, types.Vector{
+		ID:           c.Param("id"),
+		Embedding:    req.Embedding,
+		Vectors:      req.Vectors,
+		MultiVectors: req.MultiVectors,
+		Metadata:     req.Metadata,
+		Text:         req.Text,
+		Sparse:       req.Sparse,
+	}, req.IfAbsent)
+}
+
+func (h *Handlers) upsert(c *gin.Context, coll *engine.Collection, vector types.Vector, ifAbsent bool) {
+	lsn, created, err := coll.Upsert(vector, ifAbsent)
+	if err != nil {
+		h.logger.Error("Failed to write vector",
+			logger.String("id", vector.ID),
 			logger.Error("error", err))
 
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		status := writeErrorStatus(err)
+		c.JSON(status, models.ErrorResponse{
 			Error:   "Insert failed",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    status,
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.SuccessResponse{
+	status := http.StatusOK
+	message := "Vector updated successfully"
+	if created {
+		status = http.StatusCreated
+		message = "Vector inserted successfully"
+	}
+	c.JSON(status, models.WriteVectorResponse{
 		Success: true,
-		Message: "Vector inserted successfully",
+		ID:      vector.ID,
+		Created: created,
+		Message: message,
+		LSN:     lsn,
 	})
 }
 
+// PatchVector merges metadata into an existing vector and leaves its
+// embedding as it is.
+func (h *Handlers) PatchVector(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	var req models.PatchVectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	id := c.Param("id")
+	lsn, metadata, err := coll.PatchMetadata(id, req.Metadata)
+	if err != nil {
+		h.logger.Error("Failed to update vector metadata",
+			logger.String("id", id),
+			logger.Error("error", err))
+
+		status := writeErrorStatus(err)
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Update failed",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WriteVectorResponse{
+		Success:  true,
+		ID:       id,
+		Created:  false,
+		Message:  "Vector metadata updated successfully",
+		Metadata: metadata,
+		LSN:      lsn,
+	})
+}
+
+// writeErrorStatus maps the errors of vector writes to HTTP statuses.
+func writeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, engine.ErrVectorExists):
+		return http.StatusConflict
+	case errors.Is(err, engine.ErrVectorNotFound):
+		return http.StatusNotFound
+	case engine.IsInvalidInput(err):
+		return http.StatusBadRequest
+	case errors.Is(err, engine.ErrCollectionClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (h *Handlers) GetVector(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -56,7 +176,7 @@ func (h *Handlers) GetVector(c *gin.Context) {
 		return
 	}
 
-	vector, found := h.engine.Get(id)
+	vector, found := coll.Get(id)
 	if !found {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error:   "Vector not found",
@@ -70,7 +190,83 @@ func (h *Handlers) GetVector(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// ListVectors pages through the collection's vectors in ID order, with
+// their metadata and optionally their embeddings. Passing a page's
+// next_cursor back as cursor fetches the next one, so a whole collection
+// can be streamed while it is written to.
+func (h *Handlers) ListVectors(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
+	var req models.ListVectorsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var f *filter.Filter
+	if req.Filter != "" {
+		f = &filter.Filter{}
+		err := json.Unmarshal([]byte(req.Filter), f)
+		if err == nil {
+			err = f.Validate()
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid filter",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	start := time.Now()
+	vectors, next, err := coll.Scroll(engine.ScrollParams{
+		Cursor:      req.Cursor,
+		Limit:       req.Limit,
+		Filter:      f,
+		IncludeVecs: req.IncludeVectors,
+	})
+	if err != nil {
+		status := writeErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to list vectors",
+				logger.String("cursor", req.Cursor),
+				logger.Error("error", err))
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "List failed",
+			Message: err.Error(),
+			Code:    status,
+		})
+		return
+	}
+
+	response := models.ListVectorsResponse{
+		Vectors:    make([]models.VectorResponse, len(vectors)),
+		NextCursor: next,
+		Total:      len(vectors),
+	}
+	for i, v := range vectors {
+		response.Vectors[i] = models.ConvertVector(v, req.IncludeVectors, true)
+	}
+	response.TookMs = time.Since(start).Milliseconds()
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *Handlers) DeleteVector(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -80,7 +276,8 @@ func (h *Handlers) DeleteVector(c *gin.Context) {
 		return
 	}
 
-	if err := h.engine.Delete(id); err != nil {
+	lsn, err := coll.Delete(id)
+	if err != nil {
 		h.logger.Error("Failed to delete vector",
 			logger.String("id", id),
 			logger.Error("error", err))
@@ -96,10 +293,16 @@ func (h *Handlers) DeleteVector(c *gin.Context) {
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Message: "Vector deleted successfully",
+		LSN:     lsn,
 	})
 }
 
 func (h *Handlers) BatchInsert(c *gin.Context) {
+	coll, ok := h.collection(c)
+	if !ok {
+		return
+	}
+
 	var req models.BatchInsertRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -124,21 +327,27 @@ func (h *Handlers) BatchInsert(c *gin.Context) {
 	vectors := make([]types.Vector, len(req.Vectors))
 	for i, v := range req.Vectors {
 		vectors[i] = types.Vector{
-			ID:        v.ID,
-			Embedding: v.Embedding,
-			Metadata:  v.Metadata,
+			ID:           v.ID,
+			Embedding:    v.Embedding,
+			Vectors:      v.Vectors,
+			MultiVectors: v.MultiVectors,
+			Metadata:     v.Metadata,
+			Text:         v.Text,
+			Sparse:       v.Sparse,
 		}
 	}
 
-	if err := h.engine.BatchInsert(vectors); err != nil {
+	lsn, err := coll.BatchInsert(vectors)
+	if err != nil {
 		h.logger.Error("Batch insert failed",
 			logger.Int("count", len(vectors)),
 			logger.Error("error", err))
 
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		status := writeErrorStatus(err)
+		c.JSON(status, models.ErrorResponse{
 			Error:   "Batch insert failed",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    status,
 		})
 		return
 	}
@@ -148,6 +357,7 @@ func (h *Handlers) BatchInsert(c *gin.Context) {
 		Inserted: len(vectors),
 		Failed:   0,
 		TookMs:   time.Since(start).Milliseconds(),
+		LSN:      lsn,
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ishaan29/vectorDB/internal/config"
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// newTestRouter serves the vector, search and optimize routes for a fresh
+// engine whose default collection has 2 dimensions.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{Path: dir},
+		Index:   config.IndexConfig{Type: "hnsw", Dimensions: 2},
+		Badger:  config.BadgerConfig{Path: dir},
+	}
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+
+	eng, err := engine.NewEngine(cfg, log)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if err := eng.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start engine: %v", err)
+	}
+	t.Cleanup(func() { eng.Stop() })
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	h := NewHandlers(eng, log)
+	r.POST("/vectors", h.InsertVector)
+	r.POST("/vectors/batch", h.BatchInsert)
+	r.PUT("/vectors/:id", h.PutVector)
+	r.PATCH("/vectors/:id", h.PatchVector)
+	r.POST("/search", h.SearchVectors)
+	r.POST("/optimize", h.Optimize)
+	return r
+}
+
+func TestStatus(t *testing.T) {
+	r := newTestRouter(t)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"insert", "POST", "/vectors", `{"id": "a", "embedding": [1, 0]}`, http.StatusCreated},
+		{"put", "PUT", "/vectors/a", `{"embedding": [0, 1]}`, http.StatusOK},
+		{"insert if absent", "POST", "/vectors", `{"id": "a", "embedding": [1, 0], "if_absent": true}`, http.StatusConflict},
+		{"insert dimensions", "POST", "/vectors", `{"id": "b", "embedding": [1, 0, 0]}`, http.StatusBadRequest},
+		{"put dimensions", "PUT", "/vectors/b", `{"embedding": [1]}`, http.StatusBadRequest},
+		{"put sparse", "PUT", "/vectors/b", `{"embedding": [1, 0], "sparse": {"indices": [1, 1], "values": [1, 2]}}`, http.StatusBadRequest},
+		{"put unknown vector", "PUT", "/vectors/b", `{"embedding": [1, 0], "vectors": {"title": [1, 0]}}`, http.StatusBadRequest},
+		{"batch item", "POST", "/vectors/batch", `{"vectors": [{"id": "b", "embedding": [1, 0]}, {"id": "c", "embedding": [1]}]}`, http.StatusBadRequest},
+		{"batch", "POST", "/vectors/batch", `{"vectors": [{"id": "b", "embedding": [1, 0]}, {"id": "c", "embedding": [0, 1]}]}`, http.StatusCreated},
+		{"patch missing", "PATCH", "/vectors/missing", `{"metadata": {"a": 1}}`, http.StatusNotFound},
+		{"search", "POST", "/search", `{"embedding": [1, 0], "k": 2}`, http.StatusOK},
+		{"search dimensions", "POST", "/search", `{"embedding": [1, 0, 0], "k": 2}`, http.StatusBadRequest},
+		{"exact search dimensions", "POST", "/search", `{"embedding": [1], "k": 2, "exact": true}`, http.StatusBadRequest},
+		{"hybrid mmr", "POST", "/search", `{"embedding": [1, 0], "k": 2, "text": "hello", "mmr": {"lambda": 0.5}}`, http.StatusBadRequest},
+		{"optimize malformed", "POST", "/optimize", `{"force": tru`, http.StatusBadRequest},
+		{"optimize unknown field", "POST", "/optimize", `{"colection": "default"}`, http.StatusBadRequest},
+		{"optimize missing collection", "POST", "/optimize", `{"collection": "missing"}`, http.StatusNotFound},
+		{"optimize without body", "POST", "/optimize", ``, http.StatusAccepted},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d (%s)", tc.name, tc.want, w.Code, w.Body)
+		}
+	}
+}
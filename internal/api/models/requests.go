@@ -1,23 +1,181 @@
 package models
 
+import (
+	"github.com/ishaan29/vectorDB/pkg/filter"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
 type InsertRequest struct {
 	ID        string                 `json:"id" binding:"required"`
 	Embedding []float32              `json:"embedding" binding:"required"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	// Vectors are named embeddings, searched with "using"
+	Vectors map[string][]float32 `json:"vectors,omitempty"`
+	// MultiVectors are named bags of token embeddings, searched with
+	// "using" and a "multivector" query
+	MultiVectors map[string][][]float32 `json:"multivectors,omitempty"`
+	// Text is indexed for keyword and hybrid search
+	Text string `json:"text,omitempty"`
+	// Sparse is a sparse embedding, such as SPLADE term weights, indexed
+	// for sparse and hybrid search
+	Sparse *types.SparseVector `json:"sparse,omitempty"`
+	// IfAbsent rejects the write with 409 when the ID already exists
+	// instead of replacing the vector.
+	IfAbsent bool `json:"if_absent,omitempty"`
+}
+
+// PutVectorRequest replaces the vector named in the path, or creates it.
+type PutVectorRequest struct {
+	Embedding    []float32              `json:"embedding" binding:"required"`
+	Vectors      map[string][]float32   `json:"vectors,omitempty"`
+	MultiVectors map[string][][]float32 `json:"multivectors,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Text         string                 `json:"text,omitempty"`
+	Sparse       *types.SparseVector    `json:"sparse,omitempty"`
+	IfAbsent     bool                   `json:"if_absent,omitempty"`
+}
+
+// PatchVectorRequest merges metadata into an existing vector. A null value
+// removes the key.
+type PatchVectorRequest struct {
+	Metadata map[string]interface{} `json:"metadata" binding:"required"`
+}
+
+// ListVectorsRequest pages through a collection's vectors in ID order. It
+// is bound from the query string.
+type ListVectorsRequest struct {
+	// Cursor is the next_cursor of the previous page; the first page when
+	// empty
+	Cursor string `form:"cursor"`
+	// Limit is the page size; 100 when omitted
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+	// Filter is a metadata filter in the JSON form search requests take
+	Filter string `form:"filter"`
+	// IncludeVectors adds the embeddings, named, multi and sparse vectors
+	IncludeVectors bool `form:"include_vectors"`
 }
 
 type BatchInsertRequest struct {
 	Vectors []InsertRequest `json:"vectors" binding:"required"`
 }
 
+// SearchRequest needs an embedding (or multi-vector tokens), text, a
+// sparse embedding or any mix of them. Each one makes a ranking, and several are fused into a hybrid
+// search.
 type SearchRequest struct {
-	Embedding       []float32 `json:"embedding" binding:"required"`
-	K               int       `json:"k" binding:"required,min=1"`
-	Threshold       float32   `json:"threshold,omitempty"`
-	IncludeVectors  bool      `json:"include_vectors,omitempty"`
-	IncludeMetadata bool      `json:"include_metadata,omitempty"`
+	Embedding       []float32      `json:"embedding,omitempty"`
+	K               int            `json:"k" binding:"required,min=1"`
+	Threshold       *float32       `json:"threshold,omitempty"`
+	IncludeVectors  bool           `json:"include_vectors,omitempty"`
+	IncludeMetadata bool           `json:"include_metadata,omitempty"`
+	Filter          *filter.Filter `json:"filter,omitempty"`
+	// Ef overrides the collection's HNSW search effort for this query
+	Ef int `json:"ef,omitempty" binding:"omitempty,min=1,max=1000"`
+	// NProbe overrides the number of IVF lists scanned for this query
+	NProbe int `json:"nprobe,omitempty" binding:"omitempty,min=1"`
+	// Exact compares the query with every stored vector instead of using
+	// the index; slow, but useful as ground truth
+	Exact bool `json:"exact,omitempty"`
+	// MMR diversifies the results by maximal marginal relevance; it can't
+	// be combined with Text or Sparse
+	MMR *MMRRequest `json:"mmr,omitempty"`
+	// Text is a keyword query scored with BM25 against the vectors' text
+	Text string `json:"text,omitempty"`
+	// Sparse is scored by dot product against the vectors' sparse
+	// embeddings
+	Sparse *types.SparseVector `json:"sparse,omitempty"`
+	// Fusion combines the rankings of a hybrid search
+	Fusion *FusionRequest `json:"fusion,omitempty"`
+	// Using names the vector the embedding is searched against; the
+	// vectors' own embedding when empty
+	Using string `json:"using,omitempty"`
+	// MultiVector holds the query's token embeddings, scored by MaxSim
+	// against the multi-vector named by Using
+	MultiVector [][]float32 `json:"multivector,omitempty"`
+}
+
+type FusionRequest struct {
+	// Method is "rrf" (reciprocal rank fusion, the default) or "weighted"
+	Method string `json:"method,omitempty" binding:"omitempty,oneof=rrf weighted"`
+	// Alpha is the weight of dense vector scores in weighted fusion, the
+	// keyword and sparse scores sharing 1 - alpha; 0.5 when omitted
+	Alpha *float32 `json:"alpha,omitempty" binding:"omitempty,min=0,max=1"`
+}
+
+type MMRRequest struct {
+	// Lambda weighs relevance against diversity, from 0 (most diverse) to 1
+	// (a plain search); 0.5 when omitted
+	Lambda *float32 `json:"lambda,omitempty" binding:"omitempty,min=0,max=1"`
+	// FetchK is how many candidates K results are picked from; 4K when 0
+	FetchK int `json:"fetch_k,omitempty" binding:"omitempty,min=1"`
+}
+
+// BatchSearchRequest carries queries that are searched concurrently; each
+// has its own k, threshold and filter.
+type BatchSearchRequest struct {
+	Queries []SearchRequest `json:"queries" binding:"required,min=1,max=1000,dive"`
+}
+
+// RecommendRequest searches for vectors like the positive examples and
+// unlike the negative ones, all given by ID.
+type RecommendRequest struct {
+	Positive []string `json:"positive" binding:"required,min=1"`
+	Negative []string `json:"negative,omitempty"`
+	// Strategy is "average_vector" (the default) or "best_score"
+	Strategy        string         `json:"strategy,omitempty" binding:"omitempty,oneof=average_vector best_score"`
+	K               int            `json:"k" binding:"required,min=1"`
+	Threshold       *float32       `json:"threshold,omitempty"`
+	IncludeVectors  bool           `json:"include_vectors,omitempty"`
+	IncludeMetadata bool           `json:"include_metadata,omitempty"`
+	Filter          *filter.Filter `json:"filter,omitempty"`
+	Ef              int            `json:"ef,omitempty" binding:"omitempty,min=1,max=1000"`
+	NProbe          int            `json:"nprobe,omitempty" binding:"omitempty,min=1"`
+	Exact           bool           `json:"exact,omitempty"`
+	// Using names the vector the examples are compared by
+	Using string `json:"using,omitempty"`
+}
+
+type CreateCollectionRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Dimensions     int    `json:"dimensions" binding:"required,min=1"`
+	Metric         string `json:"metric,omitempty"`
+	IndexType      string `json:"index_type,omitempty"`
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+	EfSearch       int    `json:"ef_search,omitempty"`
+	NList          int    `json:"nlist,omitempty" binding:"omitempty,min=1"`
+	NProbe         int    `json:"nprobe,omitempty" binding:"omitempty,min=1"`
+	PQSubvectors   int    `json:"pq_subvectors,omitempty" binding:"omitempty,min=1"`
+	TrainSize      int    `json:"train_size,omitempty" binding:"omitempty,min=1"`
+	Quantization   string `json:"quantization,omitempty"`
+	Rerank         int    `json:"rerank,omitempty" binding:"omitempty,min=1"`
+	// Vectors declares named embeddings the vectors may carry besides their
+	// own, each with its own dimension, metric and index
+	Vectors map[string]VectorConfigRequest `json:"vectors,omitempty" binding:"omitempty,dive"`
+}
+
+// VectorConfigRequest configures a named vector like the collection's own
+// embedding.
+type VectorConfigRequest struct {
+	Dimensions     int    `json:"dimensions" binding:"required,min=1"`
+	Metric         string `json:"metric,omitempty"`
+	IndexType      string `json:"index_type,omitempty"`
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+	EfSearch       int    `json:"ef_search,omitempty"`
+	NList          int    `json:"nlist,omitempty" binding:"omitempty,min=1"`
+	NProbe         int    `json:"nprobe,omitempty" binding:"omitempty,min=1"`
+	PQSubvectors   int    `json:"pq_subvectors,omitempty" binding:"omitempty,min=1"`
+	TrainSize      int    `json:"train_size,omitempty" binding:"omitempty,min=1"`
+	Quantization   string `json:"quantization,omitempty"`
+	Rerank         int    `json:"rerank,omitempty" binding:"omitempty,min=1"`
+	// Multivector makes each vector carry a bag of embeddings of this
+	// dimension, such as ColBERT token embeddings, searched by MaxSim
+	Multivector bool `json:"multivector,omitempty"`
 }
 
 type OptimizeRequest struct {
 	Force bool `json:"force,omitempty"`
+	// Collection limits the optimization to one collection; empty means all
+	Collection string `json:"collection,omitempty"`
 }
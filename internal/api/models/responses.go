@@ -1,6 +1,11 @@
 package models
 
-import "github.com/ishaan29/vectorDB/pkg/types"
+import (
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/engine"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
 
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -11,12 +16,70 @@ type ErrorResponse struct {
 type SuccessResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	LSN     uint64 `json:"lsn,omitempty"`
+}
+
+// JobResponse reports the progress of a background optimize job.
+type JobResponse struct {
+	ID          string     `json:"id"`
+	State       string     `json:"state"`
+	Force       bool       `json:"force"`
+	Collections []string   `json:"collections"`
+	Step        string     `json:"step,omitempty"`
+	Collection  string     `json:"collection,omitempty"`
+	Progress    float64    `json:"progress"`
+	Indexed     int        `json:"vectors_indexed"`
+	Total       int        `json:"vectors_total"`
+	Rebuilt     []string   `json:"rebuilt"`
+	Retrained   []string   `json:"retrained"`
+	Rewritten   int        `json:"vlog_files_rewritten"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func ConvertJob(s engine.JobStatus) JobResponse {
+	resp := JobResponse{
+		ID:          s.ID,
+		State:       string(s.State),
+		Force:       s.Force,
+		Collections: s.Collections,
+		Step:        s.Step,
+		Collection:  s.Collection,
+		Progress:    s.Progress(),
+		Indexed:     s.Indexed,
+		Total:       s.Total,
+		Rebuilt:     s.Rebuilt,
+		Retrained:   s.Retrained,
+		Rewritten:   s.Rewritten,
+		Error:       s.Error,
+		StartedAt:   s.StartedAt,
+	}
+	if !s.FinishedAt.IsZero() {
+		resp.FinishedAt = &s.FinishedAt
+	}
+	return resp
+}
+
+// WriteVectorResponse reports whether a write created the vector or
+// updated an existing one.
+type WriteVectorResponse struct {
+	Success  bool                   `json:"success"`
+	ID       string                 `json:"id"`
+	Created  bool                   `json:"created"`
+	Message  string                 `json:"message,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	LSN      uint64                 `json:"lsn,omitempty"`
 }
 
 type VectorResponse struct {
-	ID        string                 `json:"id"`
-	Embedding []float32              `json:"embedding,omitempty"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ID           string                 `json:"id"`
+	Embedding    []float32              `json:"embedding,omitempty"`
+	Vectors      map[string][]float32   `json:"vectors,omitempty"`
+	MultiVectors map[string][][]float32 `json:"multivectors,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Text         string                 `json:"text,omitempty"`
+	Sparse       *types.SparseVector    `json:"sparse,omitempty"`
 }
 
 type SearchResult struct {
@@ -26,17 +89,42 @@ type SearchResult struct {
 	Vector   *VectorResponse `json:"vector,omitempty"`
 }
 
+// ListVectorsResponse is one page of vectors. NextCursor fetches the page
+// after it and is absent after the last one.
+type ListVectorsResponse struct {
+	Vectors    []VectorResponse `json:"vectors"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	TookMs     int64            `json:"took_ms"`
+	Total      int              `json:"total"`
+}
+
 type SearchResponse struct {
 	Results []SearchResult `json:"results"`
 	TookMs  int64          `json:"took_ms"`
 	Total   int            `json:"total"`
 }
 
+// BatchSearchResponse holds one result per query, in request order.
+type BatchSearchResponse struct {
+	Results []BatchSearchResult `json:"results"`
+	TookMs  int64               `json:"took_ms"`
+}
+
+// BatchSearchResult is the answer to one query of a batch. A failed query
+// carries its error and no results.
+type BatchSearchResult struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	TookMs  float64        `json:"took_ms"`
+	Error   string         `json:"error,omitempty"`
+}
+
 type BatchInsertResponse struct {
-	Success       bool  `json:"success"`
-	Inserted      int   `json:"inserted"`
-	Failed        int   `json:"failed"`
-	TookMs        int64 `json:"took_ms"`
+	Success       bool   `json:"success"`
+	Inserted      int    `json:"inserted"`
+	Failed        int    `json:"failed"`
+	TookMs        int64  `json:"took_ms"`
+	LSN           uint64 `json:"lsn,omitempty"`
 	FailedVectors []struct {
 		ID    string `json:"id"`
 		Error string `json:"error"`
@@ -55,13 +143,60 @@ type StatsResponse struct {
 	TookMs int64                  `json:"took_ms"`
 }
 
+type CollectionResponse struct {
+	Name           string                         `json:"name"`
+	Dimensions     int                            `json:"dimensions"`
+	Metric         string                         `json:"metric"`
+	IndexType      string                         `json:"index_type"`
+	M              int                            `json:"m,omitempty"`
+	EfConstruction int                            `json:"ef_construction,omitempty"`
+	EfSearch       int                            `json:"ef_search,omitempty"`
+	NList          int                            `json:"nlist,omitempty"`
+	NProbe         int                            `json:"nprobe,omitempty"`
+	PQSubvectors   int                            `json:"pq_subvectors,omitempty"`
+	TrainSize      int                            `json:"train_size,omitempty"`
+	Quantization   string                         `json:"quantization,omitempty"`
+	Rerank         int                            `json:"rerank,omitempty"`
+	Vectors        map[string]engine.VectorConfig `json:"vectors,omitempty"`
+	Stats          map[string]interface{}         `json:"stats,omitempty"`
+}
+
+type CollectionListResponse struct {
+	Collections []CollectionResponse `json:"collections"`
+	Total       int                  `json:"total"`
+}
+
+func ConvertCollection(cfg engine.CollectionConfig, stats map[string]interface{}) CollectionResponse {
+	return CollectionResponse{
+		Name:           cfg.Name,
+		Dimensions:     cfg.Dimensions,
+		Metric:         cfg.Metric,
+		IndexType:      cfg.IndexType,
+		M:              cfg.M,
+		EfConstruction: cfg.EfConstruction,
+		EfSearch:       cfg.EfSearch,
+		NList:          cfg.NList,
+		NProbe:         cfg.NProbe,
+		PQSubvectors:   cfg.PQSubvectors,
+		TrainSize:      cfg.TrainSize,
+		Quantization:   cfg.Quantization,
+		Rerank:         cfg.Rerank,
+		Vectors:        cfg.Vectors,
+		Stats:          stats,
+	}
+}
+
 func ConvertVector(v types.Vector, includeEmbedding, includeMetadata bool) VectorResponse {
 	resp := VectorResponse{
-		ID: v.ID,
+		ID:   v.ID,
+		Text: v.Text,
 	}
 
 	if includeEmbedding {
 		resp.Embedding = v.Embedding
+		resp.Vectors = v.Vectors
+		resp.MultiVectors = v.MultiVectors
+		resp.Sparse = v.Sparse
 	}
 
 	if includeMetadata {
@@ -56,12 +56,38 @@ func (s *Server) setupRouter() {
 
 		v1.POST("/vectors", h.InsertVector)
 		v1.POST("/vectors/batch", h.BatchInsert)
+		v1.GET("/vectors", h.ListVectors)
 		v1.GET("/vectors/:id", h.GetVector)
+		v1.PUT("/vectors/:id", h.PutVector)
+		v1.PATCH("/vectors/:id", h.PatchVector)
 		v1.DELETE("/vectors/:id", h.DeleteVector)
 
 		v1.POST("/search", h.SearchVectors)
+		v1.POST("/search/batch", h.BatchSearch)
+		v1.POST("/recommend", h.Recommend)
+
+		v1.POST("/collections", h.CreateCollection)
+		v1.GET("/collections", h.ListCollections)
+		v1.GET("/collections/:name", h.GetCollection)
+		v1.DELETE("/collections/:name", h.DropCollection)
+
+		coll := v1.Group("/collections/:name")
+		{
+			coll.POST("/vectors", h.InsertVector)
+			coll.POST("/vectors/batch", h.BatchInsert)
+			coll.GET("/vectors", h.ListVectors)
+			coll.GET("/vectors/:id", h.GetVector)
+			coll.PUT("/vectors/:id", h.PutVector)
+			coll.PATCH("/vectors/:id", h.PatchVector)
+			coll.DELETE("/vectors/:id", h.DeleteVector)
+
+			coll.POST("/search", h.SearchVectors)
+			coll.POST("/search/batch", h.BatchSearch)
+			coll.POST("/recommend", h.Recommend)
+		}
 
 		v1.POST("/optimize", h.Optimize)
+		v1.GET("/optimize/:id", h.OptimizeStatus)
 	}
 
 	s.router = r
@@ -15,12 +15,14 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	Logging  logger.Config  `yaml:"logging"`
 	Badger   BadgerConfig   `yaml:"badger"`
+	WAL      WALConfig      `yaml:"wal"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	GRPCPort int    `yaml:"grpc_port"`
 }
 
 // StorageConfig holds storage-specific configuration
@@ -30,8 +32,19 @@ type StorageConfig struct {
 
 // IndexConfig holds indexing-specific configuration
 type IndexConfig struct {
-	Type       string `yaml:"type"`
-	Dimensions int    `yaml:"dimensions"`
+	Type           string `yaml:"type"` // hnsw (default), flat, ivfflat or ivfpq
+	Dimensions     int    `yaml:"dimensions"`
+	Metric         string `yaml:"metric"`          // cosine (default), euclidean, dot or manhattan
+	M              int    `yaml:"m"`               // HNSW links per node, 0 for the default
+	EfConstruction int    `yaml:"ef_construction"` // HNSW build effort, 0 for the default
+	EfSearch       int    `yaml:"ef_search"`       // HNSW search effort, 0 for the default
+	NList          int    `yaml:"nlist"`           // IVF coarse clusters, 0 for the default
+	NProbe         int    `yaml:"nprobe"`          // IVF clusters scanned per query, 0 for the default
+	PQSubvectors   int    `yaml:"pq_subvectors"`   // PQ code bytes per vector, must divide dimensions; 0 for the default
+	TrainSize      int    `yaml:"train_size"`      // Vectors sampled for IVF training or HNSW quantizer calibration, 0 for the default
+	Quantization   string `yaml:"quantization"`    // HNSW in-memory vectors: none (default), int8 or binary
+	Rerank         int    `yaml:"rerank"`          // Candidates per result re-scored with exact distances, 0 to disable
+	SnapshotPath   string `yaml:"snapshot_path"`   // Legacy snapshot file, imported once into the store; defaults to hnsw.snapshot inside the badger path
 }
 
 // DatabaseConfig holds database-specific configuration
@@ -43,6 +56,12 @@ type BadgerConfig struct {
 	Path string `yaml:"path"`
 }
 
+// WALConfig holds write-ahead log configuration
+type WALConfig struct {
+	Path           string `yaml:"path"`            // Defaults to wal.log inside the badger path
+	CheckpointSize int64  `yaml:"checkpoint_size"` // Log size in bytes that triggers a checkpoint, 0 for the default
+}
+
 // Load reads the configuration file and returns a Config struct
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -62,8 +81,9 @@ func Load(path string) (*Config, error) {
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: "localhost",
-			Port: 8080,
+			Host:     "localhost",
+			Port:     8080,
+			GRPCPort: 9090,
 		},
 		Storage: StorageConfig{
 			Path: "data",
@@ -71,6 +91,7 @@ func DefaultConfig() *Config {
 		Index: IndexConfig{
 			Type:       "hnsw",
 			Dimensions: 128,
+			Metric:     "cosine",
 		},
 		Database: DatabaseConfig{
 			MaxVectors: 1000000,
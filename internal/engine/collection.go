@@ -0,0 +1,867 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/index"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/persistence"
+	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// DefaultCollection holds the vectors of the un-prefixed /api/v1 routes and
+// is configured from the index section of the config file.
+const DefaultCollection = "default"
+
+var collectionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// CollectionConfig describes a collection: its vectors' own embeddings all
+// share one dimension, distance metric and set of index parameters, and
+// each named vector has its own.
+type CollectionConfig struct {
+	Name           string `json:"name"`
+	Dimensions     int    `json:"dimensions"`
+	Metric         string `json:"metric"`
+	IndexType      string `json:"index_type,omitempty"`
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+	EfSearch       int    `json:"ef_search,omitempty"`
+	NList          int    `json:"nlist,omitempty"`
+	NProbe         int    `json:"nprobe,omitempty"`
+	PQSubvectors   int    `json:"pq_subvectors,omitempty"`
+	TrainSize      int    `json:"train_size,omitempty"`
+	// Quantization compresses the vectors an HNSW graph keeps in memory:
+	// int8 or binary. Searches then rescore candidates from the store.
+	Quantization string `json:"quantization,omitempty"`
+	// Rerank fetches this many candidates per requested result from the
+	// index and orders them by their exact distance from the store
+	Rerank int `json:"rerank,omitempty"`
+	// Vectors are the named embeddings the vectors may carry besides
+	// their own, each indexed separately
+	Vectors map[string]VectorConfig `json:"vectors,omitempty"`
+}
+
+// Index types built into the index package. Others can be used once
+// registered with index.Register.
+const (
+	IndexHNSW    = index.TypeHNSW
+	IndexFlat    = index.TypeFlat
+	IndexIVFFlat = index.TypeIVFFlat
+	IndexIVFPQ   = index.TypeIVFPQ
+)
+
+func (cfg *CollectionConfig) validate() error {
+	if !collectionNamePattern.MatchString(cfg.Name) {
+		return ErrInvalidCollectionName(cfg.Name)
+	}
+	if cfg.Dimensions <= 0 {
+		return ErrInvalidDimensions(1, cfg.Dimensions)
+	}
+	metric, err := vectormath.ParseMetric(cfg.Metric)
+	if err != nil {
+		return ErrUnsupportedMetric(cfg.Metric)
+	}
+	cfg.Metric = string(metric)
+
+	if cfg.IndexType == "" {
+		cfg.IndexType = index.DefaultType
+	}
+	quantization, err := index.ParseQuantization(cfg.Quantization)
+	if err != nil {
+		return ErrInvalidIndexParams(err.Error())
+	}
+	cfg.Quantization = string(quantization)
+	if quantization == index.QuantizationNone {
+		cfg.Quantization = ""
+	}
+
+	// The index type checks its own parameters
+	if _, err = newIndex(*cfg, nil); err != nil {
+		return err
+	}
+	return cfg.validateVectors()
+}
+
+// Candidates per result rescored from the store when a quantized graph is
+// searched without an explicit Rerank. One bit per dimension loses far more
+// ordering than a byte does.
+const (
+	defaultInt8Rerank   = 3
+	defaultBinaryRerank = 10
+)
+
+// rerankFactor returns how many candidates per result a search rescores
+// with exact distances, or 1 to use the index's distances as they are.
+func (cfg *CollectionConfig) rerankFactor() int {
+	if cfg.Rerank > 1 {
+		return cfg.Rerank
+	}
+	switch index.Quantization(cfg.Quantization) {
+	case index.QuantizationInt8:
+		return defaultInt8Rerank
+	case index.QuantizationBinary:
+		return defaultBinaryRerank
+	default:
+		return 1
+	}
+}
+
+// Collection is an independent keyspace with its own index.
+type Collection struct {
+	mu     sync.RWMutex
+	config CollectionConfig
+	store  *persistence.BadgerStore
+	index  index.VectorIndex
+	named  map[string]*vectorSpace // Indexes of the named vectors
+	wal    *persistence.WAL
+	logger logger.Logger
+	// legacySnapshotPath is the snapshot file of versions that kept the
+	// graph outside the store
+	legacySnapshotPath string
+	closed             bool
+
+	// pending holds logged writes that failed to apply, oldest first. They
+	// are retried before the next write, so the store never records an
+	// applied LSN past a write it is missing.
+	pending []*persistence.WALRecord
+}
+
+func newCollection(cfg CollectionConfig, store *persistence.BadgerStore, wal *persistence.WAL, legacySnapshotPath string, log logger.Logger) (*Collection, error) {
+	log = log.With(logger.String("collection", cfg.Name))
+	idx, err := newIndex(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]*vectorSpace, len(cfg.Vectors))
+	for name, v := range cfg.Vectors {
+		space := &vectorSpace{name: name, config: v.named(name), multi: v.Multivector}
+		if space.index, err = space.newIndex(log.With(logger.String("vector", name))); err != nil {
+			return nil, err
+		}
+		named[name] = space
+	}
+	return &Collection{
+		config:             cfg,
+		store:              store,
+		index:              idx,
+		named:              named,
+		wal:                wal,
+		logger:             log,
+		legacySnapshotPath: legacySnapshotPath,
+	}, nil
+}
+
+// newIndex builds an empty index of the collection's type from the
+// registry.
+func newIndex(cfg CollectionConfig, log logger.Logger) (index.VectorIndex, error) {
+	idx, err := index.New(cfg.IndexType, index.Config{
+		Dimensions:     cfg.Dimensions,
+		Metric:         vectormath.Metric(cfg.Metric),
+		M:              cfg.M,
+		EfConstruction: cfg.EfConstruction,
+		EfSearch:       cfg.EfSearch,
+		NList:          cfg.NList,
+		NProbe:         cfg.NProbe,
+		Subvectors:     cfg.PQSubvectors,
+		TrainSize:      cfg.TrainSize,
+		Quantization:   index.Quantization(cfg.Quantization),
+	}, log)
+	if errors.Is(err, index.ErrUnknownType) {
+		return nil, ErrUnsupportedIndexType(cfg.IndexType)
+	}
+	if err != nil {
+		return nil, ErrInvalidIndexParams(err.Error())
+	}
+	return idx, nil
+}
+
+// Config returns the collection's definition.
+func (c *Collection) Config() CollectionConfig {
+	return c.config
+}
+
+// load restores the indexes from their snapshots and replays every vector
+// written after the oldest of them.
+func (c *Collection) load(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger.Info("Loading collection, restoring index snapshots",
+		logger.String("index_type", c.config.IndexType))
+
+	// Vectors in a snapshot that are no longer in the store were deleted
+	// after it was taken.
+	type restore struct {
+		space *vectorSpace
+		stale map[string]struct{}
+	}
+	var restores []restore
+	since := uint64(math.MaxUint64)
+	allLoaded := true
+	for _, s := range c.spaces() {
+		r := restore{space: s, stale: make(map[string]struct{})}
+		version, loaded := c.loadSnapshot(s)
+		if loaded {
+			for _, id := range s.index.IDs() {
+				r.stale[id] = struct{}{}
+			}
+			since = min(since, version)
+		}
+		allLoaded = allLoaded && loaded
+		restores = append(restores, r)
+	}
+	if !allLoaded {
+		since = 0
+	}
+
+	count := 0
+	failed := 0
+	startTime := time.Now()
+
+	err := c.store.IterateSince(since, func(vector types.Vector, changed bool) error {
+		fetched := changed
+		for _, r := range restores {
+			_, indexed := r.stale[vector.ID]
+			delete(r.stale, vector.ID)
+
+			if !changed && indexed {
+				continue
+			}
+			if !fetched {
+				stored, err := c.store.Get(vector.ID)
+				if err != nil {
+					failed++
+					return nil
+				}
+				vector, fetched = stored, true
+			}
+
+			embedding := r.space.embedding(vector)
+			if embedding == nil && r.space.name != "" {
+				// The vector doesn't carry this named vector
+				if indexed {
+					r.space.index.Remove(vector.ID)
+				}
+				continue
+			}
+			if !r.space.fits(embedding) {
+				c.logger.Warn("Skipping vector with wrong dimensions",
+					logger.String("id", vector.ID),
+					logger.String("vector", r.space.name),
+					logger.Int("expected", r.space.config.Dimensions),
+					logger.Int("actual", len(embedding)),
+				)
+				if indexed {
+					r.space.index.Remove(vector.ID)
+				}
+				failed++
+				continue
+			}
+
+			if err := r.space.index.Add(vector.ID, embedding); err != nil {
+				c.logger.Error("Failed to index vector ",
+					logger.String("id", vector.ID),
+					logger.String("vector", r.space.name),
+					logger.Error("Error: ", err),
+				)
+				failed++
+				continue
+			}
+
+			count++
+
+			if count%1000 == 0 {
+				c.logger.Info("Indexing progress",
+					logger.Int("vector_indexed", count),
+					logger.Int("errors", failed),
+					logger.Duration("elapsed", time.Since(startTime)),
+				)
+			}
+		}
+
+		// check for contect cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			return nil
+		}
+	})
+
+	if err != nil {
+		c.logger.Error("Error indexing vectors", logger.Error("Error: ", err))
+		return err
+	}
+
+	dropped := 0
+	for _, r := range restores {
+		for id := range r.stale {
+			r.space.index.Remove(id)
+		}
+		dropped += len(r.stale)
+	}
+
+	c.logger.Info("Collection loaded successfully",
+		logger.Bool("snapshot_loaded", allLoaded),
+		logger.Int("vectors_replayed", count),
+		logger.Int("vectors_dropped", dropped),
+		logger.Int("vectors_indexed", c.index.Size()),
+		logger.Int("named_vectors", len(c.named)),
+		logger.Int("errors", failed),
+		logger.Duration("startup_time", time.Since(startTime)))
+	return nil
+}
+
+// Insert logs the vector to the WAL and then applies it to the store and
+// the index, replacing any vector stored under the same ID. It returns the
+// vector's LSN; once Insert returns, the write survives a crash.
+func (c *Collection) Insert(vector types.Vector) (uint64, error) {
+	lsn, _, err := c.Upsert(vector, false)
+	return lsn, err
+}
+
+// Upsert writes the vector like Insert and reports whether it was created
+// rather than replaced. With ifAbsent set, an existing vector is left alone
+// and ErrVectorExists is returned.
+func (c *Collection) Upsert(vector types.Vector, ifAbsent bool) (uint64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, false, ErrCollectionClosed
+	}
+	if err := c.applyPending(); err != nil {
+		return 0, false, err
+	}
+
+	if err := c.checkVector(vector); err != nil {
+		return 0, false, err
+	}
+
+	exists, err := c.store.Exists(vector.ID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up vector: %w", err)
+	}
+	if exists && ifAbsent {
+		return 0, false, ErrVectorAlreadyExists(vector.ID)
+	}
+
+	rec := c.putRecord(vector)
+	lsn, err := c.wal.Append(rec)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to log vector: %w", err)
+	}
+
+	if err := c.applyPut(vector, lsn); err != nil {
+		c.pending = append(c.pending, rec)
+		return 0, false, ErrWriteNotApplied(lsn, err)
+	}
+
+	message := "Vector inserted successfully"
+	if exists {
+		message = "Vector replaced successfully"
+	}
+	c.logger.Info(message,
+		logger.String("id", vector.ID),
+		logger.Int("dimensions", len(vector.Embedding)),
+		logger.Int64("lsn", int64(lsn)))
+
+	return lsn, !exists, nil
+}
+
+// PatchMetadata merges patch into an existing vector's metadata without
+// touching its embedding or the index. A nil value in patch removes that
+// key. It returns the update's LSN and the merged metadata.
+func (c *Collection) PatchMetadata(id string, patch map[string]interface{}) (uint64, map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, nil, ErrCollectionClosed
+	}
+	if err := c.applyPending(); err != nil {
+		return 0, nil, err
+	}
+
+	exists, err := c.store.Exists(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to look up vector: %w", err)
+	}
+	if !exists {
+		return 0, nil, ErrVectorNotFound
+	}
+	metadata, err := c.store.GetMetadata(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	merged := make(map[string]interface{}, len(metadata)+len(patch))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	rec := &persistence.WALRecord{
+		Op:         persistence.WALMetadata,
+		Collection: c.config.Name,
+		Vector:     types.Vector{ID: id, Metadata: merged},
+	}
+	lsn, err := c.wal.Append(rec)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to log metadata update: %w", err)
+	}
+
+	if err := c.applyMetadata(id, merged, lsn); err != nil {
+		c.pending = append(c.pending, rec)
+		return 0, nil, ErrWriteNotApplied(lsn, err)
+	}
+
+	c.logger.Info("Vector metadata updated successfully",
+		logger.String("id", id),
+		logger.Int64("lsn", int64(lsn)))
+	return lsn, merged, nil
+}
+
+func (c *Collection) Search(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
+	if params.MMR != nil {
+		return c.diversifiedSearch(query, params)
+	}
+	if params.Text != "" || query.Sparse != nil {
+		return c.hybridSearch(query, params)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, ErrCollectionClosed
+	}
+
+	space, err := c.space(params.Using)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+
+	// Metadata loaded to evaluate the filter is reused during hydration.
+	// Filtering never reads embeddings.
+	loaded := make(map[string]map[string]interface{})
+	var allow func(id string) bool
+	if params.Filter != nil {
+		allow = func(id string) bool {
+			metadata, err := c.store.GetMetadata(id)
+			if err != nil {
+				return false
+			}
+			loaded[id] = metadata
+			return params.Filter.Match(metadata)
+		}
+	}
+
+	opts := index.SearchOptions{Ef: params.Ef, NProbe: params.NProbe, Allow: allow}
+	var indexResults []index.SearchResult
+	if space.multi {
+		// The query carries its tokens like a vector does
+		tokens := query.MultiVectors[params.Using]
+		if err := checkTokens(space, tokens); err != nil {
+			return nil, err
+		}
+		indexResults, err = c.multiVectorSearch(space, tokens, params.K, params.Exact, opts)
+	} else if len(query.Embedding) != space.config.Dimensions {
+		return nil, ErrInvalidDimensions(space.config.Dimensions, len(query.Embedding))
+	} else if params.Exact {
+		indexResults, err = c.exactSearch(space, query.Embedding, params.K, allow)
+	} else if factor := space.config.rerankFactor(); factor > 1 {
+		indexResults, err = c.rerankedSearch(space, query.Embedding, params.K, factor, opts)
+	} else {
+		indexResults, err = space.index.SearchWithOptions(query.Embedding, params.K, opts)
+	}
+	if err != nil {
+		c.logger.Error("Failed to search index",
+			logger.Bool("exact", params.Exact),
+			logger.Error("Error: ", err))
+		return nil, ErrSearchIndexFailed
+	}
+
+	indexTime := time.Since(startTime)
+
+	results := make([]types.SearchResult, 0, len(indexResults))
+	hydrateStart := time.Now()
+	for _, ir := range indexResults {
+		if params.Threshold != nil && float32(ir.Score) < *params.Threshold {
+			continue
+		}
+
+		var vector types.Vector
+		if params.IncludeVecs {
+			vector, err = c.store.Get(ir.ID)
+		} else if metadata, ok := loaded[ir.ID]; ok {
+			vector = types.Vector{ID: ir.ID, Metadata: metadata}
+		} else {
+			vector.ID = ir.ID
+			vector.Metadata, err = c.store.GetMetadata(ir.ID)
+		}
+		if err != nil {
+			c.logger.Warn("Vector in index but not in storage (inconsistency)",
+				logger.String("id", ir.ID),
+				logger.Error("error", err))
+			continue
+		}
+
+		result := types.SearchResult{
+			Vector:   vector,
+			Distance: float32(ir.Distance),
+			Score:    float32(ir.Score),
+		}
+		if !params.IncludeVecs {
+			dropVectors(&result.Vector)
+		}
+		if !params.IncludeMeta {
+			result.Vector.Metadata = nil
+		}
+		results = append(results, result)
+	}
+	hydrateTime := time.Since(hydrateStart)
+	totalTime := time.Since(startTime)
+
+	c.logger.Debug("Search completed",
+		logger.String("using", params.Using),
+		logger.Int("results_returned", len(results)),
+		logger.Int("index_results", len(indexResults)),
+		logger.Bool("filtered", params.Filter != nil),
+		logger.Bool("exact", params.Exact),
+		logger.Duration("index_time", indexTime),
+		logger.Duration("hydrate_time", hydrateTime),
+		logger.Duration("total_time", totalTime))
+
+	return results, nil
+
+}
+
+// BatchInsert validates every vector before logging any of them, so a
+// batch is either accepted whole or rejected. It returns the LSN of the
+// last vector.
+func (c *Collection) BatchInsert(vectors []types.Vector) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrCollectionClosed
+	}
+	if err := c.applyPending(); err != nil {
+		return 0, err
+	}
+
+	for _, vector := range vectors {
+		if err := c.checkVector(vector); err != nil {
+			return 0, fmt.Errorf("vector %s: %w", vector.ID, err)
+		}
+	}
+
+	startTime := time.Now()
+
+	records := make([]*persistence.WALRecord, len(vectors))
+	for i, vector := range vectors {
+		records[i] = c.putRecord(vector)
+	}
+	lsn, err := c.wal.Append(records...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to log batch: %w", err)
+	}
+
+	if err := c.store.BatchPutAt(vectors, lsn); err != nil {
+		c.logger.Error("Batch persists failed", logger.Error("error", err))
+		c.pending = append(c.pending, records...)
+		return 0, ErrWriteNotApplied(lsn, fmt.Errorf("batch persist failed: %w", err))
+	}
+
+	failedCount := 0
+	for _, vector := range vectors {
+		if err := c.indexVector(vector); err != nil {
+			c.logger.Error("Failed to index vector, it will be indexed on the next start",
+				logger.String("id", vector.ID),
+				logger.Error("error", err))
+			failedCount++
+		}
+	}
+	c.logger.Info("Batch insert completed",
+		logger.Int("total", len(vectors)),
+		logger.Int("success", len(vectors)-failedCount),
+		logger.Int("failed", failedCount),
+		logger.Int64("lsn", int64(lsn)),
+		logger.Duration("duration", time.Since(startTime)))
+	return lsn, nil
+}
+
+func (c *Collection) Get(id string) (types.Vector, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return types.Vector{}, false
+	}
+
+	vector, err := c.store.Get(id)
+	if err != nil {
+		c.logger.Debug("Vector not found",
+			logger.String("id", id),
+			logger.Error("error", err))
+		return types.Vector{}, false
+	}
+	return vector, true
+}
+
+// Delete logs the removal and applies it. It returns the removal's LSN.
+func (c *Collection) Delete(id string) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrCollectionClosed
+	}
+	if err := c.applyPending(); err != nil {
+		return 0, err
+	}
+
+	rec := &persistence.WALRecord{
+		Op:         persistence.WALDelete,
+		Collection: c.config.Name,
+		Vector:     types.Vector{ID: id},
+	}
+	lsn, err := c.wal.Append(rec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to log delete: %w", err)
+	}
+
+	if err := c.applyDelete(id, lsn); err != nil {
+		c.pending = append(c.pending, rec)
+		return 0, ErrWriteNotApplied(lsn, err)
+	}
+
+	c.logger.Info("Vector deleted successfully",
+		logger.String("id", id),
+		logger.Int64("lsn", int64(lsn)))
+	return lsn, nil
+}
+
+// Update replaces an existing vector. It returns the update's LSN.
+func (c *Collection) Update(vector types.Vector) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrCollectionClosed
+	}
+	if err := c.applyPending(); err != nil {
+		return 0, err
+	}
+	if err := c.checkVector(vector); err != nil {
+		return 0, err
+	}
+	exists, err := c.store.Exists(vector.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up vector: %w", err)
+	}
+	if !exists {
+		return 0, ErrVectorNotFound
+	}
+
+	rec := c.putRecord(vector)
+	lsn, err := c.wal.Append(rec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to log update: %w", err)
+	}
+
+	if err := c.applyPut(vector, lsn); err != nil {
+		c.pending = append(c.pending, rec)
+		return 0, ErrWriteNotApplied(lsn, err)
+	}
+
+	c.logger.Info("Vector updated successfully",
+		logger.String("id", vector.ID),
+		logger.Int64("lsn", int64(lsn)))
+	return lsn, nil
+}
+
+// validateSparse checks that a sparse embedding pairs each index with one
+// finite value. A nil one is valid.
+func validateSparse(sparse *types.SparseVector) error {
+	if sparse == nil {
+		return nil
+	}
+	if len(sparse.Indices) != len(sparse.Values) {
+		return ErrInvalidSparseVector(fmt.Sprintf("%d indices but %d values", len(sparse.Indices), len(sparse.Values)))
+	}
+	seen := make(map[uint32]bool, len(sparse.Indices))
+	for i, index := range sparse.Indices {
+		if seen[index] {
+			return ErrInvalidSparseVector(fmt.Sprintf("duplicate index %d", index))
+		}
+		seen[index] = true
+		if v := float64(sparse.Values[i]); math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrInvalidSparseVector(fmt.Sprintf("value of index %d is not finite", index))
+		}
+	}
+	return nil
+}
+
+func (c *Collection) putRecord(vector types.Vector) *persistence.WALRecord {
+	return &persistence.WALRecord{
+		Op:         persistence.WALPut,
+		Collection: c.config.Name,
+		Vector:     vector,
+	}
+}
+
+// applyPut writes a logged vector to the store and the index. The caller
+// holds the write lock.
+func (c *Collection) applyPut(vector types.Vector, lsn uint64) error {
+	if err := c.store.PutAt(vector, lsn); err != nil {
+		return fmt.Errorf("failed to persist vector: %w", err)
+	}
+
+	// The vector is in the store at this point, so a failure here is
+	// repaired when the index is rebuilt on the next start.
+	if err := c.indexVector(vector); err != nil {
+		c.logger.Error("Failed to add to HNSW index, vector is persisted and will be indexed on the next start",
+			logger.String("id", vector.ID),
+			logger.Error("Error: ", err),
+		)
+	}
+	return nil
+}
+
+// indexVector adds a stored vector to the index of each embedding it
+// carries and removes it from the others. Add re-inserts the node when the
+// embedding has changed. The caller holds the write lock.
+func (c *Collection) indexVector(vector types.Vector) error {
+	var failed error
+	for _, s := range c.spaces() {
+		embedding := s.embedding(vector)
+		if embedding == nil {
+			// A replaced vector may have dropped a named vector
+			s.index.Remove(vector.ID)
+			continue
+		}
+		if err := s.index.Add(vector.ID, embedding); err != nil && failed == nil {
+			failed = fmt.Errorf("vector %q: %w", s.name, err)
+		}
+	}
+	return failed
+}
+
+// applyDelete removes a logged vector from the store and the index. The
+// caller holds the write lock.
+func (c *Collection) applyDelete(id string, lsn uint64) error {
+	if err := c.store.DeleteAt(id, lsn); err != nil {
+		return fmt.Errorf("failed to delete from store: %w", err)
+	}
+
+	if err := c.index.Remove(id); err != nil {
+		c.logger.Debug("Deleted vector was not in the index",
+			logger.String("id", id))
+	}
+	for _, s := range c.named {
+		s.index.Remove(id)
+	}
+	return nil
+}
+
+// applyMetadata replaces a logged vector's metadata in the store. The
+// caller holds the write lock.
+func (c *Collection) applyMetadata(id string, metadata map[string]interface{}, lsn uint64) error {
+	if err := c.store.UpdateMetadataAt(id, metadata, lsn); err != nil {
+		return fmt.Errorf("failed to persist metadata: %w", err)
+	}
+	return nil
+}
+
+// applyPending retries writes that were logged but failed to apply. The
+// caller holds the write lock.
+func (c *Collection) applyPending() error {
+	for len(c.pending) > 0 {
+		rec := c.pending[0]
+		if err := c.replay(rec); err != nil {
+			return ErrWriteNotApplied(rec.LSN, err)
+		}
+		c.pending = c.pending[1:]
+	}
+	return nil
+}
+
+// replay applies one logged write, either at startup for records the store
+// hadn't received before a crash or to retry a failed write.
+func (c *Collection) replay(rec *persistence.WALRecord) error {
+	switch rec.Op {
+	case persistence.WALPut:
+		if err := c.checkVector(rec.Vector); err != nil {
+			c.logger.Warn("Skipping logged vector that doesn't fit the collection",
+				logger.String("id", rec.Vector.ID),
+				logger.Int64("lsn", int64(rec.LSN)),
+				logger.Error("error", err))
+			return nil
+		}
+		return c.applyPut(rec.Vector, rec.LSN)
+	case persistence.WALDelete:
+		return c.applyDelete(rec.Vector.ID, rec.LSN)
+	case persistence.WALMetadata:
+		// The vector may have been deleted by a later record that the
+		// store already has
+		exists, err := c.store.Exists(rec.Vector.ID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			c.logger.Debug("Skipping logged metadata of a deleted vector",
+				logger.String("id", rec.Vector.ID),
+				logger.Int64("lsn", int64(rec.LSN)))
+			return nil
+		}
+		return c.applyMetadata(rec.Vector.ID, rec.Vector.Metadata, rec.LSN)
+	}
+	return nil
+}
+
+func (c *Collection) Stats() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := map[string]interface{}{
+		"name":       c.config.Name,
+		"dimensions": c.config.Dimensions,
+		"metric":     c.config.Metric,
+	}
+
+	// Add index stats
+	for k, v := range c.index.Stats() {
+		stats["index_"+k] = v
+	}
+	if len(c.named) > 0 {
+		vectors := make(map[string]interface{}, len(c.named))
+		for name, s := range c.named {
+			vectors[name] = map[string]interface{}{
+				"dimensions":  s.config.Dimensions,
+				"metric":      s.config.Metric,
+				"multivector": s.multi,
+				"index":       s.index.Stats(),
+			}
+		}
+		stats["vectors"] = vectors
+	}
+
+	return stats
+}
@@ -2,24 +2,40 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ishaan29/vectorDB/internal/config"
-	"github.com/ishaan29/vectorDB/internal/index"
 	"github.com/ishaan29/vectorDB/internal/logger"
 	"github.com/ishaan29/vectorDB/persistence"
 	"github.com/ishaan29/vectorDB/pkg/types"
 )
 
+const (
+	walFileName             = "wal.log"
+	defaultCheckpointSize   = 64 << 20 // 64 mb
+	checkpointCheckInterval = 30 * time.Second
+)
+
 type Engine struct {
-	mu      sync.RWMutex
-	config  *config.Config
-	store   *persistence.BadgerStore
-	index   *index.HNSWIndex
-	logger  logger.Logger
-	running bool
+	mu          sync.RWMutex
+	config      *config.Config
+	store       *persistence.BadgerStore
+	wal         *persistence.WAL
+	collections map[string]*Collection
+	logger      logger.Logger
+	running     bool
+	stop        chan struct{}
+	background  sync.WaitGroup // goroutines that use the store directly
+
+	jobsMu sync.Mutex
+	jobs   map[string]*optimizeJob
+	jobSeq int
 }
 
 func NewEngine(cfg *config.Config, log logger.Logger) (*Engine, error) {
@@ -28,17 +44,27 @@ func NewEngine(cfg *config.Config, log logger.Logger) (*Engine, error) {
 		return nil, ErrStoreInitialization
 	}
 
-	hnswIndex := index.NewHNSWIndex(cfg.Index.Dimensions, log)
-	if hnswIndex == nil {
-		return nil, ErrIndexInitialization
+	walPath := cfg.WAL.Path
+	if walPath == "" {
+		walPath = filepath.Join(cfg.Badger.Path, walFileName)
+	}
+	wal, err := persistence.OpenWAL(walPath, log)
+	if err != nil {
+		log.Error("Failed to open write-ahead log",
+			logger.String("path", walPath),
+			logger.Error("error", err))
+		store.Close()
+		return nil, ErrWALInitialization
 	}
 
 	return &Engine{
-		config:  cfg,
-		store:   store,
-		index:   hnswIndex,
-		logger:  log,
-		running: false,
+		config:      cfg,
+		store:       store,
+		wal:         wal,
+		collections: make(map[string]*Collection),
+		logger:      log,
+		running:     false,
+		jobs:        make(map[string]*optimizeJob),
 	}, nil
 }
 
@@ -50,258 +76,287 @@ func (e *Engine) Start(ctx context.Context) error {
 		return ErrEngineAlreadyRunning
 	}
 
-	e.logger.Info("Starting vector engine, rebuilding HSNW index from storage. ")
+	e.logger.Info("Starting vector engine, loading collections. ")
 
-	count := 0
-	errors := 0
-	startTime := time.Now()
+	defaultConfig := e.defaultCollectionConfig()
+	if err := defaultConfig.validate(); err != nil {
+		return fmt.Errorf("invalid index config: %w", err)
+	}
 
-	err := e.store.Iterate(func(vector types.Vector) error {
-		if len(vector.Embedding) != e.config.Index.Dimensions {
-			e.logger.Warn("Skipping vector with wrong dimensions",
-				logger.String("id", vector.ID),
-				logger.Int("expected", e.config.Index.Dimensions),
-				logger.Int("actual", len(vector.Embedding)),
-			)
-			errors++
+	configs := []CollectionConfig{defaultConfig}
+	err := e.store.IterateCatalog(func(name string, data []byte) error {
+		var cfg CollectionConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			e.logger.Error("Skipping unreadable collection definition",
+				logger.String("collection", name),
+				logger.Error("error", err))
 			return nil
 		}
-
-		if err := e.index.Add(vector.ID, vector.Embedding); err != nil {
-			e.logger.Error("Failed to index vector ",
-				logger.String("id", vector.ID),
-				logger.Error("Error: ", err),
-			)
-			errors++
+		// Definitions saved before a field existed get its default
+		if err := cfg.validate(); err != nil {
+			e.logger.Error("Skipping invalid collection definition",
+				logger.String("collection", name),
+				logger.Error("error", err))
 			return nil
 		}
+		configs = append(configs, cfg)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read collection catalog: %w", err)
+	}
 
-		count++
-
-		if count%1000 == 0 {
-			e.logger.Info("Indexing progress",
-				logger.Int("vector_indexed", count),
-				logger.Int("errors", errors),
-				logger.Duration("elapsed", time.Since(startTime)),
-			)
+	for _, cfg := range configs {
+		c, err := e.newCollection(cfg)
+		if err != nil {
+			return err
 		}
-
-		// check for contect cancellation
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			return nil
+		if err := c.load(ctx); err != nil {
+			return err
 		}
-	})
+		e.collections[cfg.Name] = c
+	}
 
-	if err != nil {
-		e.logger.Error("Error indexing vectors", logger.Error("Error: ", err))
+	if err := e.replayWAL(); err != nil {
 		return err
 	}
 
 	e.logger.Info("Engine started successfully",
-		logger.Int("vectors_indexed", count),
-		logger.Int("errors", errors),
-		logger.Duration("startup_time", time.Since(startTime)))
+		logger.Int("collections", len(e.collections)),
+		logger.Int64("lsn", int64(e.wal.LastLSN())))
 	e.running = true
+	e.stop = make(chan struct{})
+	go e.runCheckpoints(e.stop)
 	return nil
 }
 
-func (e *Engine) Insert(vector types.Vector) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if !e.running {
-		return ErrEngineNotRunning
-	}
-
-	if len(vector.Embedding) != e.config.Index.Dimensions {
-		return ErrInvalidDimensions(
-			e.config.Index.Dimensions,
-			len(vector.Embedding))
+// defaultCollectionConfig derives the default collection from the index
+// section of the config file.
+func (e *Engine) defaultCollectionConfig() CollectionConfig {
+	return CollectionConfig{
+		Name:           DefaultCollection,
+		Dimensions:     e.config.Index.Dimensions,
+		Metric:         e.config.Index.Metric,
+		IndexType:      e.config.Index.Type,
+		M:              e.config.Index.M,
+		EfConstruction: e.config.Index.EfConstruction,
+		EfSearch:       e.config.Index.EfSearch,
+		NList:          e.config.Index.NList,
+		NProbe:         e.config.Index.NProbe,
+		PQSubvectors:   e.config.Index.PQSubvectors,
+		TrainSize:      e.config.Index.TrainSize,
+		Quantization:   e.config.Index.Quantization,
+		Rerank:         e.config.Index.Rerank,
 	}
+}
 
-	if err := e.store.Put(vector); err != nil {
-		return fmt.Errorf("failed to insert vector: %w", err)
+func (e *Engine) newCollection(cfg CollectionConfig) (*Collection, error) {
+	store := e.store
+	if cfg.Name != DefaultCollection {
+		store = e.store.WithPrefix(persistence.CollectionPrefix(cfg.Name))
 	}
+	return newCollection(cfg, store, e.wal, e.legacySnapshotPath(cfg.Name), e.logger)
+}
 
-	if err := e.index.Add(vector.ID, vector.Embedding); err != nil {
-		e.logger.Error("Failed to add to HNSW index, vector is presisted but not searchable",
-			logger.String("id", vector.ID),
-			logger.Error("Error: ", err),
-		)
+// CreateCollection defines a new named collection and makes it available
+// immediately.
+func (e *Engine) CreateCollection(cfg CollectionConfig) (*Collection, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 
-	e.logger.Info("Vector inserted successfully",
-		logger.String("id", vector.ID),
-		logger.Int("dimensions", len(vector.Embedding)))
-
-	return nil
-}
-
-func (e *Engine) Search(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	if !e.running {
 		return nil, ErrEngineNotRunning
 	}
+	if _, exists := e.collections[cfg.Name]; exists {
+		return nil, ErrCollectionExists(cfg.Name)
+	}
 
-	startTime := time.Now()
-
-	indexResults, err := e.index.Search(query.Embedding, params.K)
+	c, err := e.newCollection(cfg)
 	if err != nil {
-		e.logger.Error("Failed to search index", logger.Error("Error: ", err))
-		return nil, ErrSearchIndexFailed
+		return nil, err
 	}
-
-	indexTime := time.Since(startTime)
-
-	results := make([]types.SearchResult, 0, len(indexResults))
-	hydrateStart := time.Now()
-	for _, ir := range indexResults {
-		if float32(ir.Score) < params.Threshold {
-			continue
-		}
-
-		vector, err := e.store.Get(ir.ID)
-		if err != nil {
-			e.logger.Warn("Vector in index but not in storage (inconsistency)",
-				logger.String("id", ir.ID),
-				logger.Error("error", err))
-			continue
-		}
-
-		result := types.SearchResult{
-			Vector:   vector,
-			Distance: float32(ir.Distance),
-			Score:    float32(ir.Score),
-		}
-		if !params.IncludeVecs {
-			result.Vector.Embedding = nil
-		}
-		if !params.IncludeMeta {
-			result.Vector.Metadata = nil
-		}
-		results = append(results, result)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection: %w", err)
+	}
+	if err := e.store.SaveCatalogEntry(cfg.Name, data); err != nil {
+		return nil, fmt.Errorf("failed to save collection: %w", err)
 	}
-	hydrateTime := time.Since(hydrateStart)
-	totalTime := time.Since(startTime)
-
-	e.logger.Debug("Search completed",
-		logger.Int("results_returned", len(results)),
-		logger.Int("index_results", len(indexResults)),
-		logger.Duration("index_time", indexTime),
-		logger.Duration("hydrate_time", hydrateTime),
-		logger.Duration("total_time", totalTime))
 
-	return results, nil
+	e.collections[cfg.Name] = c
 
+	e.logger.Info("Collection created",
+		logger.String("collection", cfg.Name),
+		logger.Int("dimensions", cfg.Dimensions),
+		logger.String("metric", cfg.Metric),
+		logger.String("index_type", cfg.IndexType))
+	return c, nil
 }
 
-func (e *Engine) BatchInsert(vectors []types.Vector) error {
+// DropCollection deletes a named collection, its vectors and its snapshot.
+func (e *Engine) DropCollection(name string) error {
+	if name == DefaultCollection {
+		return ErrDropDefaultCollection
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	if !e.running {
 		return ErrEngineNotRunning
 	}
+	c, exists := e.collections[name]
+	if !exists {
+		return ErrCollectionNotFound(name)
+	}
 
-	startTime := time.Now()
-	successCount := 0
-	failedCount := 0
-
-	if err := e.store.BatchPut(vectors); err != nil {
-		e.logger.Error("Batch persists failed", logger.Error("error", err))
-		return fmt.Errorf("batch persist failed: %w", err)
+	// Flush the collection's pending records first, so none of them can be
+	// replayed into a new collection that reuses the name.
+	if err := e.checkpoint(); err != nil {
+		return fmt.Errorf("failed to checkpoint before drop: %w", err)
 	}
 
-	for _, vector := range vectors {
-		if len(vector.Embedding) != e.config.Index.Dimensions {
-			e.logger.Warn("Skipping vector with wrong dimensions",
-				logger.String("id", vector.ID))
-			failedCount++
-			continue
-		}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		if err := e.index.Add(vector.ID, vector.Embedding); err != nil {
-			e.logger.Error("Failed to index vector",
-				logger.String("id", vector.ID),
-				logger.Error("error", err))
-			failedCount++
-			continue
-		} else {
-			successCount++
-		}
+	if err := e.store.DeleteCatalogEntry(name); err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if err := c.store.DropAll(); err != nil {
+		return fmt.Errorf("failed to drop collection vectors: %w", err)
+	}
+	if err := os.Remove(c.legacySnapshotPath); err != nil && !os.IsNotExist(err) {
+		e.logger.Warn("Failed to remove collection snapshot",
+			logger.String("collection", name),
+			logger.Error("error", err))
 	}
-	e.logger.Info("Batch insert completed",
-		logger.Int("total", len(vectors)),
-		logger.Int("success", successCount),
-		logger.Int("failed", failedCount),
-		logger.Duration("duration", time.Since(startTime)))
+
+	c.closed = true
+	delete(e.collections, name)
+
+	e.logger.Info("Collection dropped",
+		logger.String("collection", name))
 	return nil
 }
 
-func (e *Engine) Get(id string) (types.Vector, bool) {
+// Collection returns the named collection.
+func (e *Engine) Collection(name string) (*Collection, error) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	if !e.running {
-		return types.Vector{}, false
+		return nil, ErrEngineNotRunning
 	}
-
-	vector, err := e.store.Get(id)
-	if err != nil {
-		e.logger.Debug("Vector not found",
-			logger.String("id", id),
-			logger.Error("error", err))
-		return types.Vector{}, false
+	c, exists := e.collections[name]
+	if !exists {
+		return nil, ErrCollectionNotFound(name)
 	}
-	return vector, true
+	return c, nil
 }
 
-func (e *Engine) Delete(id string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// Collections returns every collection sorted by name.
+func (e *Engine) Collections() []*Collection {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	if !e.running {
-		return ErrEngineNotRunning
+	return e.sortedCollections()
+}
+
+func (e *Engine) sortedCollections() []*Collection {
+	collections := make([]*Collection, 0, len(e.collections))
+	for _, c := range e.collections {
+		collections = append(collections, c)
 	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].config.Name < collections[j].config.Name
+	})
+	return collections
+}
 
-	if err := e.store.Delete(id); err != nil {
-		return fmt.Errorf("failed to delete from store: %w", err)
+func (e *Engine) Insert(vector types.Vector) error {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return err
 	}
+	_, err = c.Insert(vector)
+	return err
+}
 
-	if err := e.index.Remove(id); err != nil {
-		e.logger.Warn("Failed to remove from index",
-			logger.String("id", id),
-			logger.Error("error", err))
+// Upsert writes a vector to the default collection and reports whether it
+// was created. See Collection.Upsert.
+func (e *Engine) Upsert(vector types.Vector, ifAbsent bool) (bool, error) {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return false, err
 	}
+	_, created, err := c.Upsert(vector, ifAbsent)
+	return created, err
+}
 
-	e.logger.Info("Vector deleted successfully",
-		logger.String("id", id))
-	return nil
+// PatchMetadata merges metadata into a vector of the default collection.
+// See Collection.PatchMetadata.
+func (e *Engine) PatchMetadata(id string, patch map[string]interface{}) error {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.PatchMetadata(id, patch)
+	return err
 }
 
-func (e *Engine) Update(vector types.Vector) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Engine) Search(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return nil, err
+	}
+	return c.Search(query, params)
+}
 
-	if !e.running {
-		return ErrEngineNotRunning
+func (e *Engine) Recommend(query RecommendQuery, params SearchParams) ([]types.SearchResult, error) {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return nil, err
 	}
-	if _, err := e.store.Get(vector.ID); err != nil {
-		return ErrVectorNotFound
+	return c.Recommend(query, params)
+}
+
+func (e *Engine) BatchInsert(vectors []types.Vector) error {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return err
 	}
+	_, err = c.BatchInsert(vectors)
+	return err
+}
 
-	if err := e.store.Put(vector); err != nil {
-		return fmt.Errorf("failed to update vector: %w", err)
+func (e *Engine) Get(id string) (types.Vector, bool) {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return types.Vector{}, false
 	}
+	return c.Get(id)
+}
 
-	e.logger.Warn("Vector updated in storage but index not updated (HNSW limitation)",
-		logger.String("id", vector.ID))
-	return nil
+func (e *Engine) Delete(id string) error {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return err
+	}
+	_, err = c.Delete(id)
+	return err
+}
+
+func (e *Engine) Update(vector types.Vector) error {
+	c, err := e.Collection(DefaultCollection)
+	if err != nil {
+		return err
+	}
+	_, err = c.Update(vector)
+	return err
 }
 
 func (e *Engine) Stop() error {
@@ -314,12 +369,40 @@ func (e *Engine) Stop() error {
 
 	e.logger.Info("Stopping engine...")
 
+	close(e.stop)
+	e.background.Wait()
+
+	for name, c := range e.collections {
+		c.mu.Lock()
+		if err := c.saveSnapshot(); err != nil {
+			e.logger.Error("Failed to save index snapshot, next start will rebuild",
+				logger.String("collection", name),
+				logger.Error("error", err))
+		}
+		c.mu.Unlock()
+	}
+
+	if err := e.checkpoint(); err != nil {
+		e.logger.Error("Failed to checkpoint write-ahead log, next start will replay it",
+			logger.Error("error", err))
+	}
+	for _, c := range e.collections {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+	}
+	if err := e.wal.Close(); err != nil {
+		e.logger.Error("Failed to close write-ahead log",
+			logger.Error("error", err))
+	}
+
 	if err := e.store.Close(); err != nil {
 		e.logger.Error("Failed to close storage",
 			logger.Error("error", err))
 		return err
 	}
 
+	e.collections = make(map[string]*Collection)
 	e.running = false
 	e.logger.Info("Engine stopped successfully")
 	return nil
@@ -330,13 +413,18 @@ func (e *Engine) Stats() map[string]interface{} {
 	defer e.mu.RUnlock()
 
 	stats := map[string]interface{}{
-		"running":    e.running,
-		"dimensions": e.config.Index.Dimensions,
+		"running":     e.running,
+		"dimensions":  e.config.Index.Dimensions,
+		"collections": len(e.collections),
+		"wal_lsn":     e.wal.LastLSN(),
+		"wal_bytes":   e.wal.Size(),
 	}
 
-	// Add index stats
-	if e.index != nil {
-		indexStats := e.index.Stats()
+	// Add default collection index stats
+	if c, ok := e.collections[DefaultCollection]; ok {
+		c.mu.RLock()
+		indexStats := c.index.Stats()
+		c.mu.RUnlock()
 		for k, v := range indexStats {
 			stats["index_"+k] = v
 		}
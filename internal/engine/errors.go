@@ -6,15 +6,103 @@ import (
 )
 
 var (
-	ErrEngineNotRunning     = errors.New("engine is not running")
-	ErrStoreInitialization  = errors.New("failed to initialize vector store")
-	ErrIndexInitialization  = errors.New("failed to initialize index")
-	ErrEngineAlreadyRunning = errors.New("engine is already running")
-	ErrSearchIndexFailed    = errors.New("failed to search index")
-	ErrVectorNotFound       = errors.New("vector not found")
+	ErrEngineNotRunning      = errors.New("engine is not running")
+	ErrStoreInitialization   = errors.New("failed to initialize vector store")
+	ErrIndexInitialization   = errors.New("failed to initialize index")
+	ErrWALInitialization     = errors.New("failed to open write-ahead log")
+	ErrEngineAlreadyRunning  = errors.New("engine is already running")
+	ErrSearchIndexFailed     = errors.New("failed to search index")
+	ErrVectorNotFound        = errors.New("vector not found")
+	ErrVectorExists          = errors.New("vector already exists")
+	ErrNoSuchCollection      = errors.New("collection not found")
+	ErrDuplicateCollection   = errors.New("collection already exists")
+	ErrCollectionClosed      = errors.New("collection is closed")
+	ErrDropDefaultCollection = errors.New("the default collection cannot be dropped")
+	ErrOptimizeInProgress    = errors.New("an optimize job is already running")
+	ErrNoPositiveExamples    = errors.New("at least one positive example is required")
+	ErrNoSuchVector          = errors.New("named vector not configured")
+	ErrMultiVectorSearch     = errors.New("search not supported for multi-vectors")
+	ErrDimensionMismatch     = errors.New("invalid dimensions")
+	ErrInvalidSparse         = errors.New("invalid sparse vector")
+	ErrHybridMMR             = errors.New("mmr can't be combined with text or sparse queries")
 )
 
+// IsInvalidInput reports whether err was caused by the vector or query a
+// client sent, such as an embedding of the wrong dimension, rather than by
+// the collection.
+func IsInvalidInput(err error) bool {
+	return errors.Is(err, ErrDimensionMismatch) ||
+		errors.Is(err, ErrInvalidSparse) ||
+		errors.Is(err, ErrNoSuchVector) ||
+		errors.Is(err, ErrMultiVectorSearch) ||
+		errors.Is(err, ErrHybridMMR)
+}
+
 func ErrInvalidDimensions(expected, actual int) error {
-	return fmt.Errorf("invalid dimensions: expected %d, got %d",
-		expected, actual)
+	return fmt.Errorf("%w: expected %d, got %d",
+		ErrDimensionMismatch, expected, actual)
+}
+
+func ErrUnknownVector(name string) error {
+	return fmt.Errorf("%w: %s", ErrNoSuchVector, name)
+}
+
+func ErrUnknownMultiVector(name string) error {
+	return fmt.Errorf("%w: no multi-vector %s", ErrNoSuchVector, name)
+}
+
+func ErrUnsupportedMultiVector(name, search string) error {
+	return fmt.Errorf("%w: %s using %s", ErrMultiVectorSearch, search, name)
+}
+
+func ErrInvalidSparseVector(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSparse, reason)
+}
+
+func ErrCollectionNotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrNoSuchCollection, name)
+}
+
+func ErrCollectionExists(name string) error {
+	return fmt.Errorf("%w: %s", ErrDuplicateCollection, name)
+}
+
+func ErrInvalidCollectionName(name string) error {
+	return fmt.Errorf("invalid collection name %q: use 1-64 letters, digits, '-' or '_'", name)
+}
+
+func ErrUnsupportedMetric(metric string) error {
+	return fmt.Errorf("unsupported distance metric %q", metric)
+}
+
+func ErrWriteNotApplied(lsn uint64, err error) error {
+	return fmt.Errorf("write logged at LSN %d but not yet applied, it will be retried: %w", lsn, err)
+}
+
+func ErrVectorAlreadyExists(id string) error {
+	return fmt.Errorf("%w: %s", ErrVectorExists, id)
+}
+
+func ErrUnsupportedIndexType(indexType string) error {
+	return fmt.Errorf("unsupported index type %q", indexType)
+}
+
+func ErrOptimizeRunning(id string) error {
+	return fmt.Errorf("%w: %s", ErrOptimizeInProgress, id)
+}
+
+func ErrExampleNotFound(id string) error {
+	return fmt.Errorf("%w: example %s", ErrVectorNotFound, id)
+}
+
+func ErrUnsupportedStrategy(strategy string) error {
+	return fmt.Errorf("unsupported recommend strategy %q", strategy)
+}
+
+func ErrUnsupportedFusion(method string) error {
+	return fmt.Errorf("unsupported fusion method %q", method)
+}
+
+func ErrInvalidIndexParams(reason string) error {
+	return fmt.Errorf("invalid index parameters: %s", reason)
 }
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/persistence"
+	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// FusionMethod combines the rankings of a hybrid search.
+type FusionMethod string
+
+const (
+	// FusionRRF scores a result by the sum of 1/(60 + rank) over the
+	// rankings it appears in, which needs no score calibration
+	FusionRRF FusionMethod = "rrf"
+	// FusionWeighted scales every ranking's scores to [0, 1] and mixes them
+	// by Alpha
+	FusionWeighted FusionMethod = "weighted"
+)
+
+const (
+	// DefaultFusionAlpha weighs the dense ranking and the others equally.
+	DefaultFusionAlpha = 0.5
+	// rrfK damps the weight of the top ranks, as in the original paper.
+	rrfK = 60
+	// defaultHybridFetchFactor is how many candidates per result each
+	// ranking contributes.
+	defaultHybridFetchFactor = 4
+)
+
+// FusionParams tunes how a hybrid search combines its rankings.
+type FusionParams struct {
+	Method FusionMethod // FusionRRF when empty
+	// Alpha is the weight of the dense vector scores in weighted fusion;
+	// the keyword and sparse scores share 1 - Alpha
+	Alpha float32
+}
+
+// ranking is one of the result lists a hybrid search fuses, best first.
+type ranking struct {
+	hits   []persistence.Hit
+	weight float32 // Share of weighted fusion
+}
+
+// hybridSearch fuses up to three rankings: a dense vector search when the
+// query has an embedding or multi-vector tokens, BM25 over the vectors' text when params.Text is
+// set and a sparse dot-product search when the query has a sparse
+// embedding. A search with a single ranking keeps its raw scores. The
+// dense ranking applies params.Threshold; fused scores are not thresholded.
+func (c *Collection) hybridSearch(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
+	fusion := FusionParams{Method: FusionRRF}
+	if params.Fusion != nil {
+		fusion = *params.Fusion
+	}
+	if fusion.Method == "" {
+		fusion.Method = FusionRRF
+	}
+	if fusion.Method != FusionRRF && fusion.Method != FusionWeighted {
+		return nil, ErrUnsupportedFusion(string(fusion.Method))
+	}
+	if err := validateSparse(query.Sparse); err != nil {
+		return nil, err
+	}
+	if _, err := c.vectorConfig(params.Using); err != nil {
+		return nil, err
+	}
+
+	k := params.K
+	fetch := k * defaultHybridFetchFactor
+	text := params.Text
+	params.Text = ""
+	params.Fusion = nil
+
+	var rankings []ranking
+	found := make(map[string]*types.SearchResult)
+	if len(query.Embedding) > 0 || len(query.MultiVectors) > 0 {
+		dense := query
+		dense.Sparse = nil
+		denseParams := params
+		denseParams.K = fetch
+		results, err := c.Search(dense, denseParams)
+		if err != nil {
+			return nil, err
+		}
+		hits := make([]persistence.Hit, len(results))
+		for i := range results {
+			hits[i] = persistence.Hit{ID: results[i].Vector.ID, Score: float64(results[i].Score)}
+			found[hits[i].ID] = &results[i]
+		}
+		rankings = append(rankings, ranking{hits: hits, weight: fusion.Alpha})
+	}
+
+	var others []ranking
+	if text != "" {
+		hits, err := c.storeSearch(params, func(allow func(string) bool) ([]persistence.Hit, error) {
+			return c.store.SearchText(text, fetch, allow)
+		})
+		if err != nil {
+			return nil, err
+		}
+		others = append(others, ranking{hits: hits})
+	}
+	if query.Sparse != nil {
+		hits, err := c.storeSearch(params, func(allow func(string) bool) ([]persistence.Hit, error) {
+			return c.store.SearchSparse(query.Sparse, fetch, allow)
+		})
+		if err != nil {
+			return nil, err
+		}
+		others = append(others, ranking{hits: hits})
+	}
+	share := 1 - fusion.Alpha
+	if len(rankings) == 0 {
+		share = 1
+	}
+	for _, r := range others {
+		r.weight = share / float32(len(others))
+		rankings = append(rankings, r)
+	}
+
+	scores := make(map[string]float32)
+	for _, r := range rankings {
+		for i, h := range r.hits {
+			switch {
+			case len(rankings) == 1:
+				scores[h.ID] = float32(h.Score)
+			case fusion.Method == FusionRRF:
+				scores[h.ID] += 1 / float32(rrfK+i+1)
+			default:
+				scores[h.ID] += r.weight * normalized(h.Score, r.hits[0].Score, r.hits[len(r.hits)-1].Score)
+			}
+		}
+	}
+
+	ranked := make([]string, 0, len(scores))
+	for id := range scores {
+		ranked = append(ranked, id)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := scores[ranked[i]], scores[ranked[j]]
+		if a != b {
+			return a > b
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	results := make([]types.SearchResult, 0, min(k, len(ranked)))
+	for _, id := range ranked {
+		if len(results) == k {
+			break
+		}
+		result, ok := found[id]
+		if !ok {
+			// Not in the dense ranking
+			r, ok := c.hydrate(id, query.Embedding, params)
+			if !ok {
+				continue
+			}
+			result = &r
+		}
+		result.Score = scores[id]
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// storeSearch runs one of the store's own searches over the vectors
+// matching params.Filter.
+func (c *Collection) storeSearch(params SearchParams, search func(allow func(id string) bool) ([]persistence.Hit, error)) ([]persistence.Hit, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, ErrCollectionClosed
+	}
+
+	var allow func(id string) bool
+	if params.Filter != nil {
+		allow = func(id string) bool {
+			metadata, err := c.store.GetMetadata(id)
+			return err == nil && params.Filter.Match(metadata)
+		}
+	}
+	hits, err := search(allow)
+	if err != nil {
+		c.logger.Error("Failed to search store", logger.Error("error", err))
+		return nil, ErrSearchIndexFailed
+	}
+	return hits, nil
+}
+
+// hydrate loads a search result found without the index, with the distance
+// of its embedding called params.Using to the query if there is one. The
+// MaxSim of multi-vectors is left to the dense ranking.
+func (c *Collection) hydrate(id string, query []float32, params SearchParams) (types.SearchResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return types.SearchResult{}, false
+	}
+	vector, err := c.store.Get(id)
+	if err != nil {
+		c.logger.Warn("Text or sparse embedding indexed for a missing vector (inconsistency)",
+			logger.String("id", id),
+			logger.Error("error", err))
+		return types.SearchResult{}, false
+	}
+
+	result := types.SearchResult{Vector: vector}
+	if len(query) > 0 && !c.multiVector(params.Using) {
+		cfg, _ := c.vectorConfig(params.Using) // Checked by hybridSearch
+		metric := vectormath.Metric(cfg.Metric)
+		if result.Distance, err = metric.Distance(query, embeddingFor(vector, params.Using)); err != nil {
+			result.Distance = metric.MaxDistance()
+		}
+	}
+	if !params.IncludeVecs {
+		dropVectors(&result.Vector)
+	}
+	if !params.IncludeMeta {
+		result.Vector.Metadata = nil
+	}
+	return result, true
+}
+
+// normalized scales score from [worst, best] to [0, 1]. A ranking whose
+// scores are all equal maps to 1.
+func normalized(score, best, worst float64) float32 {
+	if best == worst {
+		return 1
+	}
+	return float32((score - worst) / (best - worst))
+}
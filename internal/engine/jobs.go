@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// maxFinishedJobs is how many finished optimize jobs are kept for status
+// queries; older ones are forgotten.
+const maxFinishedJobs = 20
+
+// JobState is the lifecycle of a background job.
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobCompleted JobState = "completed"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled" // The engine stopped first
+)
+
+// Steps of an optimize job, reported in JobStatus.Step.
+const (
+	StepRebuild = "rebuild"
+	StepRetrain = "retrain"
+	StepCompact = "compact"
+)
+
+// JobStatus is a point-in-time copy of an optimize job's progress.
+type JobStatus struct {
+	ID          string
+	State       JobState
+	Force       bool
+	Collections []string // Collections the job covers
+	Step        string   // What the job is doing now
+	Collection  string   // Collection the current step works on, if any
+	Indexed     int      // Vectors indexed so far by rebuilds
+	Total       int      // Vectors to rebuild, counted when the job started
+	Rebuilt     []string
+	Retrained   []string
+	Rewritten   int // Value log files rewritten by compaction
+	Error       string
+	StartedAt   time.Time
+	FinishedAt  time.Time // Zero while running
+}
+
+// Progress returns the fraction of the job done, from 0 to 1.
+func (s JobStatus) Progress() float64 {
+	switch {
+	case s.State != JobRunning:
+		return 1
+	case s.Total == 0 || s.Step == StepCompact:
+		return 0.99
+	default:
+		return min(float64(s.Indexed)/float64(s.Total), 0.99)
+	}
+}
+
+// optimizeJob is the mutable state behind a JobStatus.
+type optimizeJob struct {
+	mu     sync.Mutex
+	status JobStatus
+}
+
+func (j *optimizeJob) snapshot() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := j.status
+	s.Collections = append([]string(nil), s.Collections...)
+	s.Rebuilt = append([]string(nil), s.Rebuilt...)
+	s.Retrained = append([]string(nil), s.Retrained...)
+	return s
+}
+
+func (j *optimizeJob) update(fn func(s *JobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.status)
+}
+
+func (j *optimizeJob) finish(state JobState, err error) {
+	j.update(func(s *JobStatus) {
+		s.State = state
+		s.Step = ""
+		s.Collection = ""
+		s.FinishedAt = time.Now()
+		if err != nil {
+			s.Error = err.Error()
+		}
+	})
+}
+
+// Optimize starts a background job that, for each named collection or for
+// all of them when none are named, rebuilds the index from the store when
+// it carries tombstones (always when force is set), retrains indexes that
+// are fitted to the data, and finally compacts the store. Only one job runs
+// at a time. The returned status carries the ID to poll with OptimizeJob.
+func (e *Engine) Optimize(names []string, force bool) (JobStatus, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.running {
+		return JobStatus{}, ErrEngineNotRunning
+	}
+
+	var collections []*Collection
+	if len(names) == 0 {
+		collections = e.sortedCollections()
+	}
+	for _, name := range names {
+		c, ok := e.collections[name]
+		if !ok {
+			return JobStatus{}, ErrCollectionNotFound(name)
+		}
+		collections = append(collections, c)
+	}
+
+	e.jobsMu.Lock()
+	defer e.jobsMu.Unlock()
+
+	for _, job := range e.jobs {
+		if s := job.snapshot(); s.State == JobRunning {
+			return s, ErrOptimizeRunning(s.ID)
+		}
+	}
+
+	e.jobSeq++
+	job := &optimizeJob{status: JobStatus{
+		ID:        fmt.Sprintf("optimize-%d", e.jobSeq),
+		State:     JobRunning,
+		Force:     force,
+		StartedAt: time.Now(),
+	}}
+	for _, c := range collections {
+		job.status.Collections = append(job.status.Collections, c.config.Name)
+	}
+	e.jobs[job.status.ID] = job
+	e.pruneJobs()
+
+	e.background.Add(1)
+	go e.runOptimize(job, collections, force, e.stop)
+	return job.snapshot(), nil
+}
+
+// OptimizeJob returns the status of an optimize job.
+func (e *Engine) OptimizeJob(id string) (JobStatus, bool) {
+	e.jobsMu.Lock()
+	defer e.jobsMu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return job.snapshot(), true
+}
+
+// pruneJobs forgets the oldest finished jobs beyond maxFinishedJobs. The
+// caller holds jobsMu.
+func (e *Engine) pruneJobs() {
+	var finished []JobStatus
+	for _, job := range e.jobs {
+		if s := job.snapshot(); s.State != JobRunning {
+			finished = append(finished, s)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].StartedAt.Before(finished[j].StartedAt) })
+	for _, s := range finished[:len(finished)-maxFinishedJobs] {
+		delete(e.jobs, s.ID)
+	}
+}
+
+func (e *Engine) runOptimize(job *optimizeJob, collections []*Collection, force bool, stop chan struct{}) {
+	defer e.background.Done()
+
+	start := time.Now()
+	var rebuild []*Collection
+	total := 0
+	for _, c := range collections {
+		c.mu.RLock()
+		size, tombstones := 0, 0
+		for _, s := range c.spaces() {
+			size += s.index.Size()
+			tombstones += indexTombstones(s.index)
+		}
+		if force || tombstones > 0 {
+			rebuild = append(rebuild, c)
+			total += size
+		}
+		c.mu.RUnlock()
+	}
+	job.update(func(s *JobStatus) { s.Total = total })
+
+	for _, c := range rebuild {
+		name := c.config.Name
+		job.update(func(s *JobStatus) { s.Step, s.Collection = StepRebuild, name })
+
+		ok, err := c.Rebuild(stop, func() {
+			job.update(func(s *JobStatus) { s.Indexed++ })
+		})
+		if errors.Is(err, ErrCollectionClosed) {
+			continue // Dropped while the job ran
+		}
+		if err != nil {
+			e.failOptimize(job, name, err)
+			return
+		}
+		if !ok {
+			job.finish(JobCancelled, nil)
+			return
+		}
+		job.update(func(s *JobStatus) { s.Rebuilt = append(s.Rebuilt, name) })
+	}
+
+	for _, c := range collections {
+		if stopped(stop) {
+			job.finish(JobCancelled, nil)
+			return
+		}
+		name := c.config.Name
+		job.update(func(s *JobStatus) { s.Step, s.Collection = StepRetrain, name })
+
+		retrained, err := c.Retrain()
+		if errors.Is(err, ErrCollectionClosed) {
+			continue
+		}
+		if err != nil {
+			e.failOptimize(job, name, err)
+			return
+		}
+		if retrained {
+			job.update(func(s *JobStatus) { s.Retrained = append(s.Retrained, name) })
+		}
+	}
+
+	if stopped(stop) {
+		job.finish(JobCancelled, nil)
+		return
+	}
+	// Compaction comes last: it waits for rebuilds anyway, as it could drop
+	// the deletions they have yet to catch up with
+	job.update(func(s *JobStatus) { s.Step, s.Collection = StepCompact, "" })
+	rewritten, err := e.store.Compact()
+	job.update(func(s *JobStatus) { s.Rewritten = rewritten })
+	if err != nil {
+		e.failOptimize(job, "", err)
+		return
+	}
+
+	job.finish(JobCompleted, nil)
+	s := job.snapshot()
+	e.logger.Info("Optimization completed",
+		logger.String("job", s.ID),
+		logger.Int("collections_rebuilt", len(s.Rebuilt)),
+		logger.Int("collections_retrained", len(s.Retrained)),
+		logger.Int("vlog_files_rewritten", s.Rewritten),
+		logger.Duration("duration", time.Since(start)))
+}
+
+func (e *Engine) failOptimize(job *optimizeJob, collection string, err error) {
+	e.logger.Error("Optimization failed",
+		logger.String("job", job.snapshot().ID),
+		logger.String("collection", collection),
+		logger.Error("error", err))
+	job.finish(JobFailed, err)
+}
+
+func stopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// DefaultMMRLambda weighs relevance and diversity equally.
+const DefaultMMRLambda = 0.5
+
+// defaultMMRFetchFactor sets FetchK to this many candidates per result when
+// it isn't given.
+const defaultMMRFetchFactor = 4
+
+// MMRParams re-ranks search results by maximal marginal relevance, trading
+// relevance to the query against similarity to the results already picked.
+type MMRParams struct {
+	// Lambda weighs relevance against diversity: 1 is a plain search, 0
+	// only looks for results unlike each other
+	Lambda float32
+	// FetchK is how many candidates are fetched to pick K from; 4K when 0
+	FetchK int
+}
+
+// diversifiedSearch fetches params.MMR.FetchK candidates and greedily picks
+// K of them, each time taking the candidate that maximizes
+//
+//	Lambda * score(query, c) - (1 - Lambda) * max score(c, picked)
+//
+// Results keep their score against the query but come in pick order. Both
+// scores come from the metric, so hybrid queries, whose fused scores are on
+// another scale, are rejected.
+func (c *Collection) diversifiedSearch(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
+	if c.multiVector(params.Using) {
+		return nil, ErrUnsupportedMultiVector(params.Using, "mmr")
+	}
+	if params.Text != "" || query.Sparse != nil {
+		return nil, ErrHybridMMR
+	}
+	mmr := *params.MMR
+	k, includeVecs := params.K, params.IncludeVecs
+	params.MMR = nil
+	params.IncludeVecs = true
+	params.K = mmr.FetchK
+	if params.K <= 0 {
+		params.K = k * defaultMMRFetchFactor
+	}
+	params.K = max(params.K, k)
+
+	candidates, err := c.Search(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.vectorConfig(params.Using)
+	if err != nil {
+		return nil, err
+	}
+	metric := vectormath.Metric(cfg.Metric)
+	// maxSim[i] is the highest score of candidate i against any pick so far
+	maxSim := make([]float32, len(candidates))
+	picked := make([]bool, len(candidates))
+	results := make([]types.SearchResult, 0, min(k, len(candidates)))
+	for len(results) < k && len(results) < len(candidates) {
+		best, bestValue := -1, float32(0)
+		for i, candidate := range candidates {
+			if picked[i] {
+				continue
+			}
+			value := mmr.Lambda * candidate.Score
+			if len(results) > 0 {
+				value -= (1 - mmr.Lambda) * maxSim[i]
+			}
+			if best < 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+
+		picked[best] = true
+		chosen := candidates[best]
+		for i, candidate := range candidates {
+			if picked[i] {
+				continue
+			}
+			dist, err := metric.Distance(embeddingFor(candidate.Vector, params.Using), embeddingFor(chosen.Vector, params.Using))
+			if err != nil {
+				dist = metric.MaxDistance()
+			}
+			if sim := metric.Score(dist); len(results) == 0 || sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+
+		if !includeVecs {
+			dropVectors(&chosen.Vector)
+		}
+		results = append(results, chosen)
+	}
+	return results, nil
+}
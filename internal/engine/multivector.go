@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ishaan29/vectorDB/internal/index"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// defaultMultiVectorFetchFactor is how many candidates per result a
+// multi-vector search rescores with MaxSim.
+const defaultMultiVectorFetchFactor = 4
+
+// tokenIndex indexes the tokens of multi-vectors, such as ColBERT token
+// embeddings, in an index of any type, each under "<id>\x00<n>". It takes
+// a multi-vector as its tokens laid end to end, so the collection handles
+// it like any other embedding, and is searched with a query's tokens laid
+// out the same way.
+type tokenIndex struct {
+	index.VectorIndex
+	dimensions int
+
+	mu     sync.RWMutex
+	tokens map[string]int // Tokens indexed per vector
+}
+
+func newTokenIndex(idx index.VectorIndex, dimensions int) *tokenIndex {
+	return &tokenIndex{VectorIndex: idx, dimensions: dimensions, tokens: make(map[string]int)}
+}
+
+func tokenID(id string, n int) string {
+	return id + "\x00" + strconv.Itoa(n)
+}
+
+// splitTokenID is the reverse of tokenID.
+func splitTokenID(token string) (string, int) {
+	i := strings.LastIndexByte(token, 0)
+	if i < 0 {
+		return token, 0
+	}
+	n, _ := strconv.Atoi(token[i+1:])
+	return token[:i], n
+}
+
+// Add replaces the tokens of a vector.
+func (t *tokenIndex) Add(id string, embedding []float32) error {
+	if len(embedding) == 0 || len(embedding)%t.dimensions != 0 {
+		return index.ErrDimensionMismatch(t.dimensions, len(embedding))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	had := t.tokens[id]
+	n := len(embedding) / t.dimensions
+	for i := 0; i < n; i++ {
+		if err := t.VectorIndex.Add(tokenID(id, i), embedding[i*t.dimensions:(i+1)*t.dimensions]); err != nil {
+			// Remember every token that may be left, so Remove finds them
+			t.tokens[id] = max(had, i)
+			return err
+		}
+	}
+	for i := n; i < had; i++ {
+		t.VectorIndex.Remove(tokenID(id, i))
+	}
+	t.tokens[id] = n
+	return nil
+}
+
+func (t *tokenIndex) Remove(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n, ok := t.tokens[id]
+	if !ok {
+		return index.ErrVectorNotInIndex(id)
+	}
+	for i := 0; i < n; i++ {
+		t.VectorIndex.Remove(tokenID(id, i))
+	}
+	delete(t.tokens, id)
+	return nil
+}
+
+// SearchWithOptions looks up the nearest k tokens of every query token and
+// ranks the vectors they belong to by an estimate of their MaxSim: the sum,
+// over the query tokens, of the best score among the vector's tokens that
+// were found. Exact scores need all of a vector's tokens, which the index
+// doesn't keep; see Collection.multiVectorSearch.
+func (t *tokenIndex) SearchWithOptions(query []float32, k int, opts index.SearchOptions) ([]index.SearchResult, error) {
+	if len(query) == 0 || len(query)%t.dimensions != 0 {
+		return nil, index.ErrDimensionMismatch(t.dimensions, len(query))
+	}
+	if allow := opts.Allow; allow != nil {
+		// Every token of a vector asks about the same ID
+		allowed := make(map[string]bool)
+		opts.Allow = func(token string) bool {
+			id, _ := splitTokenID(token)
+			ok, seen := allowed[id]
+			if !seen {
+				ok = allow(id)
+				allowed[id] = ok
+			}
+			return ok
+		}
+	}
+
+	n := len(query) / t.dimensions
+	estimates := make(map[string]float64)
+	for q := 0; q < n; q++ {
+		hits, err := t.VectorIndex.SearchWithOptions(query[q*t.dimensions:(q+1)*t.dimensions], k, opts)
+		if err != nil {
+			return nil, err
+		}
+		best := make(map[string]float64, len(hits))
+		for _, hit := range hits {
+			id, _ := splitTokenID(hit.ID)
+			if score, ok := best[id]; !ok || hit.Score > score {
+				best[id] = hit.Score
+			}
+		}
+		for id, score := range best {
+			estimates[id] += score
+		}
+	}
+
+	results := make([]index.SearchResult, 0, len(estimates))
+	for id, score := range estimates {
+		results = append(results, index.SearchResult{ID: id, Distance: -score, Score: score})
+	}
+	sortResults(results)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Size returns the number of vectors with tokens in the index.
+func (t *tokenIndex) Size() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.tokens)
+}
+
+func (t *tokenIndex) IDs() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	ids := make([]string, 0, len(t.tokens))
+	for id := range t.tokens {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Load restores the token index and counts the tokens of each vector.
+func (t *tokenIndex) Load(r io.Reader) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.VectorIndex.Load(r); err != nil {
+		return err
+	}
+	t.tokens = make(map[string]int)
+	for _, token := range t.VectorIndex.IDs() {
+		id, n := splitTokenID(token)
+		t.tokens[id] = max(t.tokens[id], n+1)
+	}
+	return nil
+}
+
+func (t *tokenIndex) Stats() map[string]interface{} {
+	stats := t.VectorIndex.Stats()
+	stats["tokens"] = t.VectorIndex.Size()
+	stats["vectors"] = t.Size()
+	return stats
+}
+
+// Train fits the token index to the tokens of sampled multi-vectors.
+func (t *tokenIndex) Train(samples [][]float32) error {
+	var tokens [][]float32
+	for _, sample := range samples {
+		tokens = append(tokens, splitTokens(sample, t.dimensions)...)
+	}
+	return t.VectorIndex.Train(tokens)
+}
+
+// flattenTokens lays a multi-vector's tokens end to end, or returns nil if
+// it has none.
+func flattenTokens(tokens [][]float32) []float32 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	flat := make([]float32, 0, len(tokens)*len(tokens[0]))
+	for _, token := range tokens {
+		flat = append(flat, token...)
+	}
+	return flat
+}
+
+// splitTokens is the reverse of flattenTokens.
+func splitTokens(flat []float32, dimensions int) [][]float32 {
+	tokens := make([][]float32, 0, len(flat)/dimensions)
+	for i := 0; i+dimensions <= len(flat); i += dimensions {
+		tokens = append(tokens, flat[i:i+dimensions])
+	}
+	return tokens
+}
+
+// maxSim scores a document against a query by late interaction: for each
+// query token, its best score against any document token, summed.
+func maxSim(metric vectormath.Metric, query, document [][]float32) float32 {
+	var total float32
+	for _, q := range query {
+		var best float32
+		for i, d := range document {
+			dist, err := metric.Distance(q, d)
+			if err != nil {
+				dist = metric.MaxDistance()
+			}
+			if score := metric.Score(dist); i == 0 || score > best {
+				best = score
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// checkTokens checks that a multi-vector has tokens, all of the space's
+// dimension.
+func checkTokens(s *vectorSpace, tokens [][]float32) error {
+	if len(tokens) == 0 {
+		return ErrInvalidDimensions(s.config.Dimensions, 0)
+	}
+	for _, token := range tokens {
+		if len(token) != s.config.Dimensions {
+			return ErrInvalidDimensions(s.config.Dimensions, len(token))
+		}
+	}
+	return nil
+}
+
+// multiVectorSearch scores the vectors of a multi-vector space by MaxSim
+// against the query's tokens. Candidates come from the token index, or
+// from every stored multi-vector for an exact search, and are scored from
+// the tokens in the store. The distance is the negated score. The caller
+// holds the read lock.
+func (c *Collection) multiVectorSearch(s *vectorSpace, query [][]float32, k int, exact bool, opts index.SearchOptions) ([]index.SearchResult, error) {
+	metric := vectormath.Metric(s.config.Metric)
+	if exact {
+		if k < 1 {
+			return []index.SearchResult{}, nil
+		}
+		pq := make(resultHeap, 0, k+1)
+		err := c.store.IterateMultiVectors(s.name, func(id string, tokens [][]float32) error {
+			score := maxSim(metric, query, tokens)
+			if pq.Len() >= k && score <= pq[0].Score {
+				return nil
+			}
+			if opts.Allow != nil && !opts.Allow(id) {
+				return nil
+			}
+			heap.Push(&pq, types.SearchResult{Vector: types.Vector{ID: id}, Score: score})
+			if pq.Len() > k {
+				heap.Pop(&pq)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		results := make([]index.SearchResult, pq.Len())
+		for i := len(results) - 1; i >= 0; i-- {
+			r := heap.Pop(&pq).(types.SearchResult)
+			results[i] = index.SearchResult{ID: r.Vector.ID, Distance: -float64(r.Score), Score: float64(r.Score)}
+		}
+		sortResults(results) // Ties by ID, as the index search orders them
+		return results, nil
+	}
+
+	factor := max(s.config.rerankFactor(), defaultMultiVectorFetchFactor)
+	candidates, err := s.index.SearchWithOptions(flattenTokens(query), k*factor, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]index.SearchResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		tokens, err := c.store.GetMultiVector(candidate.ID, s.name)
+		if err == nil && tokens == nil {
+			err = ErrUnknownVector(s.name)
+		}
+		if err != nil {
+			c.logger.Warn("Vector in index but not in storage (inconsistency)",
+				logger.String("id", candidate.ID),
+				logger.Error("error", err))
+			continue
+		}
+		score := float64(maxSim(metric, query, tokens))
+		results = append(results, index.SearchResult{ID: candidate.ID, Distance: -score, Score: score})
+	}
+	sortResults(results)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// iterateEmbeddings walks a space's stored embeddings, a multi-vector's
+// tokens laid end to end.
+func (c *Collection) iterateEmbeddings(s *vectorSpace, fn func(id string, embedding []float32) error) error {
+	if !s.multi {
+		return c.store.IterateNamedEmbeddings(s.name, fn)
+	}
+	return c.store.IterateMultiVectors(s.name, func(id string, tokens [][]float32) error {
+		return fn(id, flattenTokens(tokens))
+	})
+}
+
+// sortResults orders results best first, ties by ID.
+func sortResults(results []index.SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ishaan29/vectorDB/internal/index"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+// VectorConfig describes a named vector of a collection. Like the
+// collection's own embedding, each named vector has its own dimension,
+// metric and index; the metadata stays shared. A multi-vector holds a bag
+// of embeddings of that dimension per vector, such as ColBERT token
+// embeddings, and is searched by MaxSim.
+type VectorConfig struct {
+	Dimensions     int    `json:"dimensions"`
+	Metric         string `json:"metric"`
+	IndexType      string `json:"index_type,omitempty"`
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+	EfSearch       int    `json:"ef_search,omitempty"`
+	NList          int    `json:"nlist,omitempty"`
+	NProbe         int    `json:"nprobe,omitempty"`
+	PQSubvectors   int    `json:"pq_subvectors,omitempty"`
+	TrainSize      int    `json:"train_size,omitempty"`
+	Quantization   string `json:"quantization,omitempty"`
+	Rerank         int    `json:"rerank,omitempty"`
+	Multivector    bool   `json:"multivector,omitempty"`
+}
+
+// named returns the named vector's settings in the shape the index and
+// search code take, under the vector's name.
+func (v VectorConfig) named(name string) CollectionConfig {
+	return CollectionConfig{
+		Name:           name,
+		Dimensions:     v.Dimensions,
+		Metric:         v.Metric,
+		IndexType:      v.IndexType,
+		M:              v.M,
+		EfConstruction: v.EfConstruction,
+		EfSearch:       v.EfSearch,
+		NList:          v.NList,
+		NProbe:         v.NProbe,
+		PQSubvectors:   v.PQSubvectors,
+		TrainSize:      v.TrainSize,
+		Quantization:   v.Quantization,
+		Rerank:         v.Rerank,
+	}
+}
+
+// vectorConfigOf is the reverse of VectorConfig.named.
+func vectorConfigOf(cfg CollectionConfig) VectorConfig {
+	return VectorConfig{
+		Dimensions:     cfg.Dimensions,
+		Metric:         cfg.Metric,
+		IndexType:      cfg.IndexType,
+		M:              cfg.M,
+		EfConstruction: cfg.EfConstruction,
+		EfSearch:       cfg.EfSearch,
+		NList:          cfg.NList,
+		NProbe:         cfg.NProbe,
+		PQSubvectors:   cfg.PQSubvectors,
+		TrainSize:      cfg.TrainSize,
+		Quantization:   cfg.Quantization,
+		Rerank:         cfg.Rerank,
+	}
+}
+
+// validateVectors checks and normalizes the named vectors like the
+// collection's own settings. Names follow the rules of collection names.
+func (cfg *CollectionConfig) validateVectors() error {
+	for name, v := range cfg.Vectors {
+		named := v.named(name)
+		if err := named.validate(); err != nil {
+			return fmt.Errorf("vector %q: %w", name, err)
+		}
+		normalized := vectorConfigOf(named)
+		normalized.Multivector = v.Multivector
+		cfg.Vectors[name] = normalized
+	}
+	return nil
+}
+
+// vectorSpace is one embedding of a collection's vectors with its index:
+// the collection's own, or a named vector.
+type vectorSpace struct {
+	name   string           // "" for the collection's own embedding
+	config CollectionConfig // The named vector's settings under its name
+	multi  bool             // Indexed token by token in a tokenIndex
+	index  index.VectorIndex
+}
+
+// newIndex builds an empty index for the space.
+func (s *vectorSpace) newIndex(log logger.Logger) (index.VectorIndex, error) {
+	idx, err := newIndex(s.config, log)
+	if err != nil || !s.multi {
+		return idx, err
+	}
+	return newTokenIndex(idx, s.config.Dimensions), nil
+}
+
+// embedding picks the space's embedding out of a vector, nil if it has
+// none. A multi-vector's tokens come laid end to end.
+func (s *vectorSpace) embedding(vector types.Vector) []float32 {
+	if s.multi {
+		return flattenTokens(vector.MultiVectors[s.name])
+	}
+	return embeddingFor(vector, s.name)
+}
+
+// fits reports whether an embedding of the space has its dimension, or a
+// multiple of it for a multi-vector's tokens.
+func (s *vectorSpace) fits(embedding []float32) bool {
+	if s.multi {
+		return len(embedding) > 0 && len(embedding)%s.config.Dimensions == 0
+	}
+	return len(embedding) == s.config.Dimensions
+}
+
+// snapshotName is the name the space's index is saved under in the store.
+func (s *vectorSpace) snapshotName() string {
+	if s.name == "" {
+		return s.config.IndexType
+	}
+	return "vectors/" + s.name + "/" + s.config.IndexType
+}
+
+// embeddingFor picks the embedding called using out of a vector; the
+// empty name is its own.
+func embeddingFor(vector types.Vector, using string) []float32 {
+	if using == "" {
+		return vector.Embedding
+	}
+	return vector.Vectors[using]
+}
+
+// vectorConfig returns the settings of the embedding called using. It
+// needs no lock, since they never change once the collection exists.
+func (c *Collection) vectorConfig(using string) (CollectionConfig, error) {
+	if using == "" {
+		return c.config, nil
+	}
+	space, ok := c.named[using]
+	if !ok {
+		return CollectionConfig{}, ErrUnknownVector(using)
+	}
+	return space.config, nil
+}
+
+// multiVector reports whether the embedding called using is a
+// multi-vector. Like vectorConfig, it needs no lock.
+func (c *Collection) multiVector(using string) bool {
+	space, ok := c.named[using]
+	return ok && space.multi
+}
+
+// space returns the embedding called using with its index. The caller
+// holds the lock.
+func (c *Collection) space(using string) (*vectorSpace, error) {
+	if using == "" {
+		return &vectorSpace{config: c.config, index: c.index}, nil
+	}
+	space, ok := c.named[using]
+	if !ok {
+		return nil, ErrUnknownVector(using)
+	}
+	return space, nil
+}
+
+// spaces returns every embedding of the collection with its index, its
+// own first and then the named ones by name. The caller holds the lock.
+func (c *Collection) spaces() []*vectorSpace {
+	spaces := []*vectorSpace{{config: c.config, index: c.index}}
+	names := make([]string, 0, len(c.named))
+	for name := range c.named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spaces = append(spaces, c.named[name])
+	}
+	return spaces
+}
+
+// setIndex swaps in a new index for a space. The caller holds the write
+// lock.
+func (c *Collection) setIndex(s *vectorSpace, idx index.VectorIndex) {
+	if s.name == "" {
+		c.index = idx
+	}
+	s.index = idx
+}
+
+// checkVector checks a vector against the collection's settings before it
+// is logged: its own embedding, the named ones it carries and every token
+// of its multi-vectors must have their configured dimensions. A vector may
+// leave out named vectors and multi-vectors.
+func (c *Collection) checkVector(vector types.Vector) error {
+	if len(vector.Embedding) != c.config.Dimensions {
+		return ErrInvalidDimensions(c.config.Dimensions, len(vector.Embedding))
+	}
+	for name, embedding := range vector.Vectors {
+		space, ok := c.named[name]
+		if !ok || space.multi {
+			return ErrUnknownVector(name)
+		}
+		if len(embedding) != space.config.Dimensions {
+			return fmt.Errorf("vector %q: %w", name, ErrInvalidDimensions(space.config.Dimensions, len(embedding)))
+		}
+	}
+	for name, tokens := range vector.MultiVectors {
+		space, ok := c.named[name]
+		if !ok || !space.multi {
+			return ErrUnknownMultiVector(name)
+		}
+		if err := checkTokens(space, tokens); err != nil {
+			return fmt.Errorf("multi-vector %q: %w", name, err)
+		}
+	}
+	return validateSparse(vector.Sparse)
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/index"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/persistence"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+var errRebuildStopped = errors.New("rebuild stopped")
+
+const (
+	// rebuildLockedCatchUp is how many writes a rebuild may have left to
+	// replay when it takes the write lock for the swap.
+	rebuildLockedCatchUp = 100
+	// maxRebuildCatchUps bounds the catch-ups without the lock, for writes
+	// that come in faster than they are replayed.
+	maxRebuildCatchUps = 10
+)
+
+// Rebuild builds fresh indexes from the stored embeddings and swaps them
+// in, leaving behind the tombstones and drifted structure the old ones
+// gathered from updates and removals. Writes keep going to the old indexes
+// while the new ones are built; the ones that land meanwhile are replayed
+// into them, without the lock until few are left and then under the write
+// lock just before the swap, so no write is lost. The store holds back
+// compaction until then, which could drop the deletions to replay.
+// progress is called for every vector indexed. Rebuild reports false if
+// stop was closed before it finished, in which case the old indexes stay.
+func (c *Collection) Rebuild(stop <-chan struct{}, progress func()) (bool, error) {
+	release := c.store.HoldChanges()
+	defer release()
+
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return false, ErrCollectionClosed
+	}
+	since := c.store.Version()
+	spaces := c.spaces()
+	c.mu.RUnlock()
+
+	start := time.Now()
+	fresh := make([]index.VectorIndex, len(spaces))
+	for i, s := range spaces {
+		idx, err := s.newIndex(c.logger)
+		if err != nil {
+			return false, err
+		}
+		err = c.iterateEmbeddings(s, func(id string, embedding []float32) error {
+			select {
+			case <-stop:
+				return errRebuildStopped
+			default:
+			}
+			if !s.fits(embedding) {
+				return nil
+			}
+			if err := idx.Add(id, embedding); err != nil {
+				c.logger.Warn("Failed to index vector during rebuild",
+					logger.String("id", id),
+					logger.String("vector", s.name),
+					logger.Error("error", err))
+				return nil
+			}
+			progress()
+			return nil
+		})
+		if err == errRebuildStopped {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		fresh[i] = idx
+	}
+
+	// Catch up with the writes made since the build started. Each round
+	// replays those made during the one before, so the rounds get shorter
+	// as long as writes come in slower than they are replayed.
+	caughtUp := 0
+	for round := 0; round < maxRebuildCatchUps; round++ {
+		select {
+		case <-stop:
+			return false, nil
+		default:
+		}
+		next := c.store.Version()
+		n, err := catchUp(c.store, spaces, fresh, since)
+		if err != nil {
+			return false, err
+		}
+		caughtUp += n
+		since = next
+		if n <= rebuildLockedCatchUp {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false, ErrCollectionClosed
+	}
+
+	n, err := catchUp(c.store, spaces, fresh, since)
+	if err != nil {
+		return false, err
+	}
+	caughtUp += n
+
+	tombstones := 0
+	for i, s := range spaces {
+		tombstones += indexTombstones(s.index)
+		c.setIndex(s, fresh[i])
+	}
+	c.logger.Info("Rebuilt index",
+		logger.String("index_type", c.config.IndexType),
+		logger.Int("vectors", fresh[0].Size()),
+		logger.Int("named_vectors", len(spaces)-1),
+		logger.Int("writes_caught_up", caughtUp),
+		logger.Int("tombstones_dropped", tombstones),
+		logger.Duration("duration", time.Since(start)))
+	return true, nil
+}
+
+// catchUp replays into indexes being rebuilt the writes made after the
+// given store version: it re-adds what changed and drops what was deleted.
+// It returns the number of vectors replayed.
+func catchUp(store *persistence.BadgerStore, spaces []*vectorSpace, fresh []index.VectorIndex, since uint64) (int, error) {
+	n := 0
+	err := store.IterateChanges(since, func(vector types.Vector, deleted bool) error {
+		n++
+		for i, s := range spaces {
+			if deleted {
+				fresh[i].Remove(vector.ID)
+				continue
+			}
+			embedding := s.embedding(vector)
+			if !s.fits(embedding) {
+				fresh[i].Remove(vector.ID)
+				continue
+			}
+			if err := fresh[i].Add(vector.ID, embedding); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Retrain fits the indexes that are trained on the data, such as IVF-Flat,
+// to a random sample of the stored embeddings. It reports false when none
+// of them takes training. The sample and the fit run without the
+// collection lock, leaving it to the index to keep serving meanwhile.
+func (c *Collection) Retrain() (bool, error) {
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return false, ErrCollectionClosed
+	}
+	spaces := c.spaces()
+	c.mu.RUnlock()
+
+	retrained := false
+	for _, s := range spaces {
+		n := s.index.TrainSize()
+		if n == 0 {
+			continue
+		}
+
+		start := time.Now()
+		samples, err := c.sampleEmbeddings(s, n)
+		if err != nil {
+			return false, err
+		}
+		if err := s.index.Train(samples); err != nil {
+			return false, err
+		}
+		retrained = true
+
+		c.logger.Info("Retrained index",
+			logger.String("index_type", s.config.IndexType),
+			logger.String("vector", s.name),
+			logger.Int("samples", len(samples)),
+			logger.Duration("duration", time.Since(start)))
+	}
+	return retrained, nil
+}
+
+// sampleEmbeddings draws up to n of a space's stored embeddings uniformly
+// at random in a single pass over the store (reservoir sampling).
+func (c *Collection) sampleEmbeddings(s *vectorSpace, n int) ([][]float32, error) {
+	samples := make([][]float32, 0, n)
+	seen := 0
+	err := c.iterateEmbeddings(s, func(id string, embedding []float32) error {
+		if !s.fits(embedding) {
+			return nil
+		}
+		seen++
+		if len(samples) < n {
+			samples = append(samples, embedding)
+		} else if i := rand.Intn(seen); i < n {
+			samples[i] = embedding
+		}
+		return nil
+	})
+	return samples, err
+}
+
+// indexTombstones returns the number of removed vectors an index still
+// carries, for the index types that keep them.
+func indexTombstones(idx index.VectorIndex) int {
+	tombstones, _ := idx.Stats()["tombstones"].(int)
+	return tombstones
+}
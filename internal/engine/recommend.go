@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// RecommendStrategy decides how example vectors are turned into a search.
+type RecommendStrategy string
+
+const (
+	// RecommendAverage searches once with the mean of the positives pushed
+	// away from the mean of the negatives
+	RecommendAverage RecommendStrategy = "average_vector"
+	// RecommendBestScore searches around every positive and scores each
+	// candidate by its closest example. Candidates closer to a positive keep
+	// their score; those closer to a negative rank after all of them and
+	// score their similarity to it negated, so the closer the lower
+	RecommendBestScore RecommendStrategy = "best_score"
+)
+
+// bestScoreCandidates is how many candidates per result the best score
+// strategy gathers around each positive example.
+const bestScoreCandidates = 4
+
+// RecommendQuery names stored vectors to search towards and away from.
+type RecommendQuery struct {
+	Positive []string
+	Negative []string
+	Strategy RecommendStrategy // RecommendAverage when empty
+}
+
+// Recommend finds the vectors most like the positive examples and least
+// like the negative ones. The examples themselves are never returned.
+// params applies as it does to Search, with K counting recommendations.
+func (c *Collection) Recommend(query RecommendQuery, params SearchParams) ([]types.SearchResult, error) {
+	if len(query.Positive) == 0 {
+		return nil, ErrNoPositiveExamples
+	}
+	if c.multiVector(params.Using) {
+		return nil, ErrUnsupportedMultiVector(params.Using, "recommend")
+	}
+	positive, err := c.examples(query.Positive, params.Using)
+	if err != nil {
+		return nil, err
+	}
+	negative, err := c.examples(query.Negative, params.Using)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[string]bool, len(query.Positive)+len(query.Negative))
+	for _, id := range query.Positive {
+		exclude[id] = true
+	}
+	for _, id := range query.Negative {
+		exclude[id] = true
+	}
+
+	switch query.Strategy {
+	case "", RecommendAverage:
+		return c.recommendAverage(positive, negative, exclude, params)
+	case RecommendBestScore:
+		return c.recommendBestScore(positive, negative, exclude, params)
+	default:
+		return nil, ErrUnsupportedStrategy(string(query.Strategy))
+	}
+}
+
+// examples looks up the embeddings called using of the given IDs.
+func (c *Collection) examples(ids []string, using string) ([][]float32, error) {
+	if _, err := c.vectorConfig(using); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(ids))
+	for i, id := range ids {
+		vector, ok := c.Get(id)
+		embedding := embeddingFor(vector, using)
+		if !ok || embedding == nil {
+			return nil, ErrExampleNotFound(id)
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+func (c *Collection) recommendAverage(positive, negative [][]float32, exclude map[string]bool, params SearchParams) ([]types.SearchResult, error) {
+	target := mean(positive)
+	if len(negative) > 0 {
+		avoid := mean(negative)
+		for d := range target {
+			target[d] += target[d] - avoid[d]
+		}
+	}
+
+	k := params.K
+	params.K += len(exclude)
+	results, err := c.Search(types.Vector{Embedding: target}, params)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		if !exclude[r.Vector.ID] && len(kept) < k {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
+
+func (c *Collection) recommendBestScore(positive, negative [][]float32, exclude map[string]bool, params SearchParams) ([]types.SearchResult, error) {
+	k, threshold, includeVecs := params.K, params.Threshold, params.IncludeVecs
+	params.K = k*bestScoreCandidates + len(exclude)
+	params.Threshold = nil // Applied to the combined score below
+	params.IncludeVecs = true
+
+	candidates := make(map[string]types.SearchResult)
+	for _, p := range positive {
+		results, err := c.Search(types.Vector{Embedding: p}, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if !exclude[r.Vector.ID] {
+				candidates[r.Vector.ID] = r
+			}
+		}
+	}
+
+	cfg, err := c.vectorConfig(params.Using)
+	if err != nil {
+		return nil, err
+	}
+	metric := vectormath.Metric(cfg.Metric)
+	results := make([]types.SearchResult, 0, len(candidates))
+	penalized := make(map[string]bool)
+	for _, r := range candidates {
+		embedding := embeddingFor(r.Vector, params.Using)
+		bestPositive, nearest := closestExample(metric, embedding, positive)
+		bestNegative, _ := closestExample(metric, embedding, negative)
+
+		r.Distance = nearest
+		r.Score = bestPositive
+		if len(negative) > 0 && bestNegative > bestPositive {
+			// Negated similarities can be positive, e.g. for dot products,
+			// so the ranking doesn't rely on their sign
+			r.Score = -bestNegative
+			penalized[r.Vector.ID] = true
+		}
+		if threshold != nil && r.Score < *threshold {
+			continue
+		}
+		if !includeVecs {
+			dropVectors(&r.Vector)
+		}
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if pi, pj := penalized[results[i].Vector.ID], penalized[results[j].Vector.ID]; pi != pj {
+			return pj
+		}
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Vector.ID < results[j].Vector.ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// closestExample returns the best score of v against the examples and the
+// matching distance.
+func closestExample(metric vectormath.Metric, v []float32, examples [][]float32) (score, distance float32) {
+	for i, e := range examples {
+		dist, err := metric.Distance(v, e)
+		if err != nil {
+			dist = metric.MaxDistance()
+		}
+		if s := metric.Score(dist); i == 0 || s > score {
+			score, distance = s, dist
+		}
+	}
+	return score, distance
+}
+
+func mean(vectors [][]float32) []float32 {
+	m := make([]float32, len(vectors[0]))
+	for _, v := range vectors {
+		for d, x := range v {
+			m[d] += x
+		}
+	}
+	for d := range m {
+		m[d] /= float32(len(vectors))
+	}
+	return m
+}
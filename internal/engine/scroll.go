@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/filter"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+// DefaultScrollLimit is the page size of a scroll that doesn't set one.
+const DefaultScrollLimit = 100
+
+// errPageFull stops the walk of the store once a page is known to have a
+// successor.
+var errPageFull = errors.New("page full")
+
+// ScrollParams selects one page of a collection's vectors.
+type ScrollParams struct {
+	Cursor      string         // ID of the last vector of the previous page; the first page when empty
+	Limit       int            // Vectors per page; DefaultScrollLimit when 0
+	Filter      *filter.Filter // Metadata filter; nil lists every vector
+	IncludeVecs bool           // Include the embeddings, text and sparse vectors besides the metadata
+}
+
+// Scroll returns a page of the collection's vectors in ID order and the
+// cursor of the next page, which is empty after the last one. Every page is
+// read on its own, so a scroll through the whole collection sees each
+// vector that exists throughout it exactly once while writes go on; ones
+// written or deleted meanwhile may or may not show up.
+func (c *Collection) Scroll(params ScrollParams) ([]types.Vector, string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, "", ErrCollectionClosed
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultScrollLimit
+	}
+	start := time.Now()
+
+	page := make([]types.Vector, 0, min(limit, DefaultScrollLimit))
+	more := false
+	err := c.store.IterateAfter(params.Cursor, params.IncludeVecs, func(vector types.Vector) error {
+		if params.Filter != nil && !params.Filter.Match(vector.Metadata) {
+			return nil
+		}
+		if len(page) == limit {
+			more = true
+			return errPageFull
+		}
+		page = append(page, vector)
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		c.logger.Error("Failed to scroll vectors", logger.Error("error", err))
+		return nil, "", err
+	}
+
+	next := ""
+	if more {
+		next = page[len(page)-1].ID
+	}
+	c.logger.Debug("Scroll page read",
+		logger.Int("vectors", len(page)),
+		logger.Bool("filtered", params.Filter != nil),
+		logger.Bool("last", !more),
+		logger.Duration("duration", time.Since(start)))
+	return page, next, nil
+}
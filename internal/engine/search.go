@@ -1,14 +1,32 @@
 package engine
 
 import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/index"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/filter"
 	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
 )
 
 type SearchParams struct {
-	K           int     // Number of results to return
-	Threshold   float32 // Distance threshold
-	IncludeVecs bool    // Include vectors in results
-	IncludeMeta bool    // Include metadata in results
+	K           int            // Number of results to return
+	Threshold   *float32       // Minimum score of a result; nil keeps every result
+	IncludeVecs bool           // Include vectors in results
+	IncludeMeta bool           // Include metadata in results
+	Filter      *filter.Filter // Metadata filter applied during search
+	Ef          int            // HNSW search effort for this query; 0 uses the collection's ef_search
+	NProbe      int            // IVF lists scanned for this query; 0 uses the collection's nprobe
+	Exact       bool           // Compare against every stored embedding instead of using the index
+	MMR         *MMRParams     // Diversify the results by maximal marginal relevance; nil disables
+	Text        string         // Keyword query scored with BM25 and fused with the other rankings, if any
+	Fusion      *FusionParams  // How dense, keyword and sparse rankings are combined; RRF when nil
+	Using       string         // Named vector or multi-vector searched against; the vectors' own embedding when empty
 }
 
 type resultHeap []types.SearchResult
@@ -25,74 +43,149 @@ func (h *resultHeap) Pop() interface{} {
 	return x
 }
 
-// Search performs a k-nearest neighbor search
-// func (e *Engine) Search(query types.Vector, params SearchParams) ([]types.SearchResult, error) {
-// 	e.mu.RLock()
-// 	defer e.mu.RUnlock()
-
-// 	if !e.running {
-// 		return nil, ErrEngineNotRunning
-// 	}
-
-// 	if len(query.Embedding) != e.config.Index.Dimensions {
-// 		return nil, fmt.Errorf("invalid dimensions: expected %d, got %d",
-// 			e.config.Index.Dimensions, len(query.Embedding))
-// 	}
-
-// 	// Initialize priority queue for top-k results
-// 	pq := make(resultHeap, 0, params.K)
-// 	heap.Init(&pq)
-
-// 	foundAny := false
-// 	// Perform brute-force search
-// 	for _, vec := range e.store {
-// 		similarity, err := vectormath.CosineSimilarity(query.Embedding, vec.Embedding)
-// 		if err != nil {
-// 			e.logger.Warn("Failed to calculate similarity",
-// 				logger.Error("error", err),
-// 				logger.String("vector_id", vec.ID))
-// 			continue
-// 		}
-
-// 		if similarity < params.Threshold {
-// 			continue
-// 		}
-
-// 		foundAny = true
-// 		result := types.SearchResult{
-// 			Vector:   vec,
-// 			Distance: float32(1 - similarity),
-// 			Score:    float32(similarity),
-// 		}
-
-// 		if !params.IncludeVecs {
-// 			// Only include ID if vectors are not requested
-// 			result.Vector = types.Vector{
-// 				ID: vec.ID,
-// 			}
-// 		}
-
-// 		if !params.IncludeMeta {
-// 			result.Vector.Metadata = nil
-// 		}
-
-// 		if pq.Len() < params.K {
-// 			heap.Push(&pq, result)
-// 		} else if pq[0].Score < similarity {
-// 			heap.Pop(&pq)
-// 			heap.Push(&pq, result)
-// 		}
-// 	}
-
-// 	if !foundAny {
-// 		return []types.SearchResult{}, nil
-// 	}
-
-// 	// Convert heap to sorted slice
-// 	results := make([]types.SearchResult, pq.Len())
-// 	for i := len(results) - 1; i >= 0; i-- {
-// 		results[i] = heap.Pop(&pq).(types.SearchResult)
-// 	}
-
-// 	return results, nil
-// }
+// dropVectors clears the parts of a result that IncludeVecs asks for: its
+// embeddings and multi-vectors and the text and sparse vector they were
+// indexed from.
+func dropVectors(vector *types.Vector) {
+	vector.Embedding = nil
+	vector.Vectors = nil
+	vector.MultiVectors = nil
+	vector.Text = ""
+	vector.Sparse = nil
+}
+
+// exactSearch compares the query with every stored embedding of a space.
+// It is slow, but its results are exact, so approximate results can be
+// checked against it. The caller holds the read lock.
+func (c *Collection) exactSearch(s *vectorSpace, query []float32, k int, allow func(id string) bool) ([]index.SearchResult, error) {
+	if k < 1 {
+		return []index.SearchResult{}, nil
+	}
+	metric := vectormath.Metric(s.config.Metric)
+
+	pq := make(resultHeap, 0, k+1)
+	err := c.store.IterateNamedEmbeddings(s.name, func(id string, embedding []float32) error {
+		if len(embedding) != len(query) {
+			return nil
+		}
+		dist, err := metric.Distance(query, embedding)
+		if err != nil {
+			dist = metric.MaxDistance()
+		}
+		score := metric.Score(dist)
+
+		// Only vectors that would make the top k are checked against the
+		// filter, which has to read their metadata
+		if pq.Len() >= k && score <= pq[0].Score {
+			return nil
+		}
+		if allow != nil && !allow(id) {
+			return nil
+		}
+
+		heap.Push(&pq, types.SearchResult{
+			Vector:   types.Vector{ID: id},
+			Distance: dist,
+			Score:    score,
+		})
+		if pq.Len() > k {
+			heap.Pop(&pq)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Best match first
+	results := make([]index.SearchResult, pq.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		r := heap.Pop(&pq).(types.SearchResult)
+		results[i] = index.SearchResult{
+			ID:       r.Vector.ID,
+			Distance: float64(r.Distance),
+			Score:    float64(r.Score),
+		}
+	}
+	return results, nil
+}
+
+// rerankedSearch asks a space's index for factor candidates per result and
+// orders them by their exact distance, computed from the embeddings in the
+// store. It makes up for indexes whose distances are estimates. The caller
+// holds the read lock.
+func (c *Collection) rerankedSearch(s *vectorSpace, query []float32, k, factor int, opts index.SearchOptions) ([]index.SearchResult, error) {
+	candidates, err := s.index.SearchWithOptions(query, k*factor, opts)
+	if err != nil {
+		return nil, err
+	}
+	metric := vectormath.Metric(s.config.Metric)
+
+	results := make([]index.SearchResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		embedding, err := c.store.GetNamedEmbedding(candidate.ID, s.name)
+		if err == nil && embedding == nil {
+			err = ErrUnknownVector(s.name)
+		}
+		if err != nil {
+			c.logger.Warn("Vector in index but not in storage (inconsistency)",
+				logger.String("id", candidate.ID),
+				logger.Error("error", err))
+			continue
+		}
+		dist, err := metric.Distance(query, embedding)
+		if err != nil {
+			dist = metric.MaxDistance()
+		}
+		results = append(results, index.SearchResult{
+			ID:       candidate.ID,
+			Distance: float64(dist),
+			Score:    float64(metric.Score(dist)),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// BatchQuery is one query of a batch search.
+type BatchQuery struct {
+	Query  types.Vector
+	Params SearchParams
+}
+
+// BatchResult is the outcome of one query of a batch search.
+type BatchResult struct {
+	Results []types.SearchResult
+	Took    time.Duration
+	Err     error
+}
+
+// SearchBatch runs the queries concurrently, at most GOMAXPROCS at a time,
+// and returns their results in the same order. A failed query doesn't
+// affect the others.
+func (c *Collection) SearchBatch(queries []BatchQuery) []BatchResult {
+	results := make([]BatchResult, len(queries))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), len(queries)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				start := time.Now()
+				found, err := c.Search(queries[i].Query, queries[i].Params)
+				results[i] = BatchResult{Results: found, Took: time.Since(start), Err: err}
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
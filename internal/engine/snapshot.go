@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// snapshotFileName is where versions before the i: namespace kept the
+// default collection's graph.
+const snapshotFileName = "hnsw.snapshot"
+
+var snapshotMagic = [4]byte{'V', 'D', 'B', 'S'}
+
+// snapshotHeader precedes the serialized index and records the store
+// version the graph was consistent with when it was written.
+type snapshotHeader struct {
+	Magic        [4]byte
+	StoreVersion uint64
+}
+
+// legacySnapshotPath returns where older versions kept a collection's
+// graph. It is only read when the store holds no snapshot yet.
+func (e *Engine) legacySnapshotPath(name string) string {
+	if name == DefaultCollection {
+		if e.config.Index.SnapshotPath != "" {
+			return e.config.Index.SnapshotPath
+		}
+		return filepath.Join(e.config.Badger.Path, snapshotFileName)
+	}
+	return filepath.Join(e.config.Badger.Path, "hnsw-"+name+".snapshot")
+}
+
+// Snapshot saves every collection's index graph to the store so the next
+// start only has to replay vectors written after it.
+func (e *Engine) Snapshot() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.running {
+		return ErrEngineNotRunning
+	}
+	for _, c := range e.collections {
+		if err := c.Snapshot(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot saves the collection's index graphs to the store.
+func (c *Collection) Snapshot() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCollectionClosed
+	}
+	return c.saveSnapshot()
+}
+
+// saveSnapshot must be called with the write lock held so no writes land
+// between reading the store version and serializing the graph.
+func (c *Collection) saveSnapshot() error {
+	start := time.Now()
+
+	header := snapshotHeader{Magic: snapshotMagic, StoreVersion: c.store.Version()}
+	for _, s := range c.spaces() {
+		err := c.store.SaveIndexData(s.snapshotName(), func(w io.Writer) error {
+			if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+				return fmt.Errorf("failed to write snapshot header: %w", err)
+			}
+			return s.index.Save(w)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+	}
+
+	// The graph now lives in the store, so a file left by an older
+	// version would only go stale
+	if err := os.Remove(c.legacySnapshotPath); err == nil {
+		c.logger.Info("Removed legacy index snapshot file",
+			logger.String("path", c.legacySnapshotPath))
+	}
+
+	c.logger.Info("Index snapshot saved",
+		logger.Int("vectors", c.index.Size()),
+		logger.Int("named_vectors", len(c.named)),
+		logger.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// loadSnapshot restores a space's index from the store, where it is kept
+// under the name of its type, or from the file an older version wrote. It returns the store version the snapshot covers,
+// or false if there is no usable snapshot and the index has to be rebuilt
+// from scratch.
+func (c *Collection) loadSnapshot(s *vectorSpace) (uint64, bool) {
+	log := c.logger
+	if s.name != "" {
+		log = log.With(logger.String("vector", s.name))
+	}
+	r, found, err := c.store.LoadIndexData(s.snapshotName())
+	if err != nil {
+		log.Warn("Failed to read index snapshot, rebuilding",
+			logger.Error("error", err))
+		return 0, false
+	}
+	source := "store"
+	// Only the collection's own HNSW graph predates the store
+	if !found && (s.name != "" || s.config.IndexType != IndexHNSW) {
+		return 0, false
+	}
+	if !found {
+		f, err := os.Open(c.legacySnapshotPath)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn("Failed to open legacy index snapshot, rebuilding",
+					logger.String("path", c.legacySnapshotPath),
+					logger.Error("error", err))
+			}
+			return 0, false
+		}
+		defer f.Close()
+		r = f
+		source = c.legacySnapshotPath
+	}
+
+	var header snapshotHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil || header.Magic != snapshotMagic {
+		log.Warn("Invalid index snapshot header, rebuilding",
+			logger.String("source", source))
+		return 0, false
+	}
+
+	if header.StoreVersion > c.store.Version() {
+		// The store is older than the snapshot (e.g. restored from backup)
+		log.Warn("Index snapshot is ahead of the store, rebuilding",
+			logger.String("source", source))
+		return 0, false
+	}
+
+	if err := s.index.Load(r); err != nil {
+		log.Warn("Failed to load index snapshot, rebuilding",
+			logger.String("source", source),
+			logger.Error("error", err))
+		return 0, false
+	}
+
+	log.Info("Index snapshot loaded",
+		logger.String("source", source),
+		logger.Int("vectors", s.index.Size()))
+	return header.StoreVersion, true
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/persistence"
+)
+
+// replayWAL applies every logged record that hasn't reached the store yet,
+// e.g. because the process crashed between logging and applying it. Each
+// collection keeps its own applied LSN, so records are only applied once.
+// The caller must keep the collections from being written to concurrently.
+func (e *Engine) replayWAL() error {
+	start := time.Now()
+
+	applied := make(map[string]uint64, len(e.collections))
+	var highest uint64
+	for name, c := range e.collections {
+		lsn, err := c.store.AppliedLSN()
+		if err != nil {
+			return fmt.Errorf("failed to read applied LSN of %s: %w", name, err)
+		}
+		applied[name] = lsn
+		if lsn > highest {
+			highest = lsn
+		}
+	}
+
+	// The log was removed or replaced; keep numbering past what the store
+	// has already seen so new records aren't mistaken for applied ones.
+	if highest > e.wal.LastLSN() {
+		e.logger.Warn("Write-ahead log is behind the store, advancing it",
+			logger.Int64("wal_lsn", int64(e.wal.LastLSN())),
+			logger.Int64("applied_lsn", int64(highest)))
+		if err := e.wal.Truncate(highest); err != nil {
+			return err
+		}
+	}
+
+	replayed := 0
+	skipped := 0
+	err := e.wal.Replay(0, func(rec *persistence.WALRecord) error {
+		c, ok := e.collections[rec.Collection]
+		if !ok {
+			skipped++
+			return nil
+		}
+		if rec.LSN <= applied[rec.Collection] {
+			return nil
+		}
+		if err := c.replay(rec); err != nil {
+			return fmt.Errorf("failed to replay LSN %d: %w", rec.LSN, err)
+		}
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if replayed > 0 || skipped > 0 {
+		e.logger.Info("Write-ahead log replayed",
+			logger.Int("records_replayed", replayed),
+			logger.Int("records_skipped", skipped),
+			logger.Duration("duration", time.Since(start)))
+	}
+	return nil
+}
+
+// Checkpoint makes every logged write durable in the store and truncates
+// the write-ahead log.
+func (e *Engine) Checkpoint() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.running {
+		return ErrEngineNotRunning
+	}
+	return e.checkpoint()
+}
+
+// checkpoint must be called with e.mu held. It locks every collection so
+// no write is between being logged and being applied, retries writes that
+// failed to apply, syncs the store and only then drops the log.
+func (e *Engine) checkpoint() error {
+	for _, c := range e.collections {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	lsn := e.wal.LastLSN()
+	for _, c := range e.collections {
+		if err := c.applyPending(); err != nil {
+			return err
+		}
+	}
+	if err := e.store.Sync(); err != nil {
+		return fmt.Errorf("failed to sync store: %w", err)
+	}
+	if err := e.wal.Truncate(lsn); err != nil {
+		return err
+	}
+
+	e.logger.Debug("Write-ahead log checkpointed",
+		logger.Int64("lsn", int64(lsn)))
+	return nil
+}
+
+// runCheckpoints truncates the log whenever it grows past the configured
+// size, until stop is closed.
+func (e *Engine) runCheckpoints(stop chan struct{}) {
+	threshold := e.config.WAL.CheckpointSize
+	if threshold <= 0 {
+		threshold = defaultCheckpointSize
+	}
+
+	ticker := time.NewTicker(checkpointCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if e.wal.Size() < threshold {
+			continue
+		}
+		if err := e.Checkpoint(); err != nil && err != ErrEngineNotRunning {
+			e.logger.Warn("Write-ahead log checkpoint failed",
+				logger.Error("error", err))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package index
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// candidate is a graph node paired with its distance to the current query.
+type candidate struct {
+	slot uint32
+	dist float32
+}
+
+// candidateQueue is a container/heap priority queue of candidates. It pops
+// the closest candidate first, or the farthest when farthestFirst is set.
+type candidateQueue struct {
+	items         []candidate
+	farthestFirst bool
+}
+
+func (q candidateQueue) Len() int { return len(q.items) }
+func (q candidateQueue) Less(i, j int) bool {
+	if q.farthestFirst {
+		return q.items[i].dist > q.items[j].dist
+	}
+	return q.items[i].dist < q.items[j].dist
+}
+func (q candidateQueue) Swap(i, j int)       { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *candidateQueue) Push(x interface{}) { q.items = append(q.items, x.(candidate)) }
+func (q *candidateQueue) Pop() interface{} {
+	old := q.items
+	n := len(old)
+	x := old[n-1]
+	q.items = old[0 : n-1]
+	return x
+}
+
+func (q *candidateQueue) peek() candidate { return q.items[0] }
+
+// sorted returns the queued candidates by ascending distance.
+func (q *candidateQueue) sorted() []candidate {
+	out := make([]candidate, len(q.items))
+	copy(out, q.items)
+	sort.Slice(out, func(i, j int) bool { return out[i].dist < out[j].dist })
+	return out
+}
+
+// scored is a vector ID paired with its distance to the current query.
+type scored struct {
+	id   string
+	dist float32
+}
+
+// topK keeps the k closest vectors offered to it. The filter is only
+// consulted for vectors that would make the cut, because it may have to
+// read their metadata.
+type topK struct {
+	k     int
+	allow func(id string) bool
+	items []scored // Max-heap on dist, so the farthest kept is items[0]
+}
+
+func newTopK(k int, allow func(id string) bool) *topK {
+	return &topK{k: k, allow: allow, items: make([]scored, 0, k+1)}
+}
+
+func (t *topK) Len() int           { return len(t.items) }
+func (t *topK) Less(i, j int) bool { return t.items[i].dist > t.items[j].dist }
+func (t *topK) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topK) Push(x interface{}) { t.items = append(t.items, x.(scored)) }
+func (t *topK) Pop() interface{} {
+	old := t.items
+	n := len(old)
+	x := old[n-1]
+	t.items = old[0 : n-1]
+	return x
+}
+
+func (t *topK) offer(id string, dist float32) {
+	if len(t.items) >= t.k && dist >= t.items[0].dist {
+		return
+	}
+	if t.allow != nil && !t.allow(id) {
+		return
+	}
+	heap.Push(t, scored{id: id, dist: dist})
+	if len(t.items) > t.k {
+		heap.Pop(t)
+	}
+}
+
+// results returns the kept vectors by ascending distance, scored under
+// metric.
+func (t *topK) results(metric vectormath.Metric) []SearchResult {
+	sort.Slice(t.items, func(i, j int) bool { return t.items[i].dist < t.items[j].dist })
+	results := make([]SearchResult, len(t.items))
+	for i, s := range t.items {
+		results[i] = SearchResult{
+			ID:       s.id,
+			Distance: float64(s.dist),
+			Score:    float64(metric.Score(s.dist)),
+		}
+	}
+	return results
+}
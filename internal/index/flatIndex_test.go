@@ -0,0 +1,136 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+func TestFlatIndex_Exact(t *testing.T) {
+	for _, metric := range []vectormath.Metric{vectormath.Cosine, vectormath.Euclidean, vectormath.Manhattan, vectormath.DotProduct} {
+		t.Run(string(metric), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			vectors := clusteredVectors(rng, 10000, 16)
+
+			// Enough vectors for the scan to be split across workers
+			idx := NewFlatIndex(FlatConfig{Dimensions: 16, Metric: metric, Workers: 4}, nil)
+			for id, v := range vectors {
+				if err := idx.Add(id, v); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+
+			for q := 0; q < 20; q++ {
+				query := vectors[fmt.Sprintf("v%d", rng.Intn(len(vectors)))]
+				want := exactNearest(vectors, query, 10, metric)
+				results, err := idx.SearchWithOptions(query, 10, SearchOptions{})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if len(results) != 10 {
+					t.Fatalf("Expected 10 results, got %d", len(results))
+				}
+				for i, r := range results {
+					if mustDistance(metric, query, vectors[r.ID]) != mustDistance(metric, query, vectors[want[i]]) {
+						t.Fatalf("Expected %s at rank %d, got %s", want[i], i, r.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFlatIndex_ParallelFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := clusteredVectors(rng, 10000, 8)
+
+	serial := NewFlatIndex(FlatConfig{Dimensions: 8, Workers: 1}, nil)
+	parallel := NewFlatIndex(FlatConfig{Dimensions: 8, Workers: 4}, nil)
+	for id, v := range vectors {
+		serial.Add(id, v)
+		parallel.Add(id, v)
+	}
+
+	// The filter keeps unsynchronized state, like the engine's does
+	seen := make(map[string]bool)
+	allow := func(id string) bool {
+		seen[id] = true
+		return strings.HasSuffix(id, "7")
+	}
+	query := vectors["v42"]
+	want, _ := serial.SearchWithOptions(query, 20, SearchOptions{Allow: allow})
+	got, _ := parallel.SearchWithOptions(query, 20, SearchOptions{Allow: allow})
+	if len(got) != 20 {
+		t.Fatalf("Expected 20 results, got %d", len(got))
+	}
+	for i := range want {
+		if !strings.HasSuffix(got[i].ID, "7") {
+			t.Fatalf("Filtered out vector %s returned", got[i].ID)
+		}
+		if got[i].Distance != want[i].Distance {
+			t.Fatalf("Parallel scan differs at rank %d: %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFlatIndex_RemoveAndReplace(t *testing.T) {
+	idx := NewFlatIndex(FlatConfig{Dimensions: 2, Metric: vectormath.Euclidean}, nil)
+	idx.Add("a", []float32{0, 0})
+	idx.Add("b", []float32{1, 0})
+	idx.Add("c", []float32{5, 5})
+
+	if err := idx.Remove("a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := idx.Remove("a"); err == nil {
+		t.Errorf("Expected removing a missing vector to fail")
+	}
+	idx.Add("c", []float32{0, 0.5})
+	if err := idx.Add("d", []float32{1}); err == nil {
+		t.Errorf("Expected a dimension mismatch")
+	}
+
+	results, _ := idx.SearchWithOptions([]float32{0, 0}, 5, SearchOptions{})
+	if idx.Size() != 2 || len(results) != 2 || results[0].ID != "c" || results[1].ID != "b" {
+		t.Errorf("Expected [c b] after remove and replace, got %v", results)
+	}
+}
+
+func TestFlatIndex_SaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := clusteredVectors(rng, 300, 16)
+
+	idx := NewFlatIndex(FlatConfig{Dimensions: 16}, nil)
+	for id, v := range vectors {
+		idx.Add(id, v)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	restored := NewFlatIndex(FlatConfig{Dimensions: 16}, nil)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if restored.Size() != idx.Size() {
+		t.Fatalf("Expected %d vectors, got %d", idx.Size(), restored.Size())
+	}
+
+	query := vectors["v7"]
+	want, _ := idx.SearchWithOptions(query, 5, SearchOptions{})
+	got, _ := restored.SearchWithOptions(query, 5, SearchOptions{})
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("Restored index returned %v, want %v", got, want)
+	}
+
+	mismatched := NewFlatIndex(FlatConfig{Dimensions: 16, Metric: vectormath.Euclidean}, nil)
+	idx.Save(&buf)
+	if err := mismatched.Load(&buf); err == nil {
+		t.Errorf("Expected loading into a different metric to fail")
+	}
+}
@@ -0,0 +1,104 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+const flatSnapshotVersion uint8 = 1
+
+var flatSnapshotMagic = [4]byte{'F', 'L', 'A', 'T'}
+
+// flatSnapshotHeader is the fixed-size preamble of a serialized flat index.
+// It is followed by the IDs and then all embeddings in the same order.
+type flatSnapshotHeader struct {
+	Magic   [4]byte
+	Version uint8
+	Dim     uint32
+	Metric  [16]byte
+	Count   uint32
+}
+
+// Save serializes the IDs and embeddings.
+func (f *FlatIndex) Save(w io.Writer) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	header := flatSnapshotHeader{
+		Magic:   flatSnapshotMagic,
+		Version: flatSnapshotVersion,
+		Dim:     uint32(f.dim),
+		Count:   uint32(len(f.ids)),
+	}
+	copy(header.Metric[:], f.metric)
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+
+	for _, id := range f.ids {
+		if err := writeString(bw, id); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+	if err := writeFloats(bw, f.vectors); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	return nil
+}
+
+// Load replaces the index with one previously written by Save. The
+// snapshot must have been taken from an index with the same dimensions and
+// metric.
+func (f *FlatIndex) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	var header flatSnapshotHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return ErrSnapshotRead(err)
+	}
+	if header.Magic != flatSnapshotMagic {
+		return ErrSnapshotRead(fmt.Errorf("bad magic %q", header.Magic[:]))
+	}
+	if header.Version != flatSnapshotVersion {
+		return ErrSnapshotRead(fmt.Errorf("unsupported version %d", header.Version))
+	}
+	if int(header.Dim) != f.dim {
+		return ErrDimensionMismatch(f.dim, int(header.Dim))
+	}
+	if metric := vectormath.Metric(bytes.TrimRight(header.Metric[:], "\x00")); metric != f.metric {
+		return ErrMetricMismatch(f.metric, metric)
+	}
+
+	ids := make([]string, header.Count)
+	pos := make(map[string]int, header.Count)
+	for p := range ids {
+		id, err := readString(br)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		ids[p] = id
+		pos[id] = p
+	}
+	vectors, err := readFloats(br, int(header.Count)*f.dim)
+	if err != nil {
+		return ErrSnapshotRead(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ids = ids
+	f.vectors = vectors
+	f.pos = pos
+	return nil
+}
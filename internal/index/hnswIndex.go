@@ -1,180 +1,301 @@
 package index
 
 import (
-	"fmt"
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
 	"sync"
+	"time"
 
-	"github.com/fogfish/hnsw"
 	"github.com/ishaan29/vectorDB/internal/logger"
-	"github.com/ishaan29/vectorDB/pkg/types"
 	"github.com/ishaan29/vectorDB/pkg/vectormath"
 )
 
-// CosineSurface implements vector.Surface for cosine distance
-type CosineSurface struct{}
+const (
+	defaultM              = 16  // Number of bi-directional links
+	defaultEfConstruction = 200 // Size of the dynamic candidate list
+	defaultEfSearch       = 50  // Default search effort
+	maxEfSearch           = 1000
+)
 
-// Distance calculates cosine distance between two vectors
-func (CosineSurface) Distance(a, b types.Vector) float32 {
-	dist, err := vectormath.CosineDistance(a.Embedding, b.Embedding)
-	if err != nil {
-		// Return maximum distance on error
-		return 2.0
-	}
-	return dist
+// hnswNode is a single vertex of the graph. Removed nodes stay in the slot
+// table as tombstones so that slot numbers held by other nodes stay valid.
+// A node keeps either its full embedding or, once the graph's quantizer is
+// calibrated, only the quantized code and a fingerprint of the embedding.
+type hnswNode struct {
+	id        string
+	embedding []float32
+	code      []byte
+	sum       uint64 // Fingerprint of the embedding; 0 when unknown
+	level     int
+	neighbors [][]uint32 // neighbors[l] holds the links on layer l
+	deleted   bool
 }
 
-// Equal checks if two vectors are equal by ID
-func (CosineSurface) Equal(a, b types.Vector) bool {
-	return a.ID == b.ID
+// HNSWIndex is an in-memory Hierarchical Navigable Small World graph that
+// supports true removal: deleted nodes are unlinked and their neighbours'
+// lists are repaired, so they are never returned or traversed again.
+type HNSWIndex struct {
+	mu             sync.RWMutex
+	dim            int
+	logger         logger.Logger
+	m              int
+	m0             int
+	efConstruction int
+	efSearch       int
+	metric         vectormath.Metric
+	levelMult      float64
+	rng            *rand.Rand
+	nodes          []*hnswNode
+	ids            map[string]uint32 // Live vectors by ID
+	entryPoint     uint32
+	maxLevel       int // -1 while the graph is empty
+	tombstones     int
+
+	quantization    Quantization
+	calibrationSize int
+	quantizer       quantizer // nil until calibrated
+	scratch         sync.Pool // Decode buffers for quantized distances
 }
 
-// HNSWIndex wraps the fogfish HNSW implementation
-type HNSWIndex struct {
-	mu       sync.RWMutex
-	index    *hnsw.HNSW[types.Vector]
-	dim      int
-	logger   logger.Logger
-	vectors  map[string]types.Vector // Track vectors by ID
-	efSearch int
+// HNSWConfig holds the graph parameters; zero values select the defaults.
+type HNSWConfig struct {
+	Dimensions     int
+	Metric         vectormath.Metric // Cosine when empty
+	M              int               // Number of bi-directional links
+	EfConstruction int               // Size of the dynamic candidate list
+	EfSearch       int               // Search effort
+	// Quantization compresses the in-memory embeddings once
+	// CalibrationSize vectors have been added. Distances become estimates,
+	// so callers should rescore results against the original vectors.
+	Quantization    Quantization
+	CalibrationSize int
 }
 
 func NewHNSWIndex(dimensions int, log logger.Logger) *HNSWIndex {
-	m := 16               // Number of bi-directional links
-	efConstruction := 200 // Size of the dynamic candidate list
-
-	// Create the distance function (Surface)
-	surface := CosineSurface{}
+	return NewHNSWIndexWithConfig(HNSWConfig{Dimensions: dimensions}, log)
+}
 
-	// Create HNSW index
-	index := hnsw.New[types.Vector](
-		surface,
-		hnsw.WithM(m),
-		hnsw.WithEfConstruction(efConstruction),
-	)
+func NewHNSWIndexWithConfig(cfg HNSWConfig, log logger.Logger) *HNSWIndex {
+	if cfg.M <= 1 {
+		cfg.M = defaultM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = defaultEfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = defaultEfSearch
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = vectormath.Cosine
+	}
+	if cfg.Quantization == "" {
+		cfg.Quantization = QuantizationNone
+	}
+	if cfg.CalibrationSize <= 0 {
+		cfg.CalibrationSize = defaultCalibrationSize
+	}
 
-	return &HNSWIndex{
-		index:    index,
-		dim:      dimensions,
-		logger:   log,
-		vectors:  make(map[string]types.Vector),
-		efSearch: 50, // Default search effort
+	h := &HNSWIndex{
+		dim:             cfg.Dimensions,
+		logger:          log,
+		m:               cfg.M,
+		m0:              cfg.M * 2,
+		efConstruction:  cfg.EfConstruction,
+		efSearch:        cfg.EfSearch,
+		metric:          cfg.Metric,
+		levelMult:       1 / math.Log(float64(cfg.M)),
+		rng:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		ids:             make(map[string]uint32),
+		maxLevel:        -1,
+		quantization:    cfg.Quantization,
+		calibrationSize: cfg.CalibrationSize,
+	}
+	h.scratch.New = func() interface{} {
+		buf := make([]float32, h.dim)
+		return &buf
 	}
+	return h
 }
 
+// Add inserts a vector into the graph. If the ID is already indexed with a
+// different embedding the old node is removed and the new one re-inserted.
 func (h *HNSWIndex) Add(id string, embedding []float32) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	// Validate dimensions
 	if len(embedding) != h.dim {
-		return fmt.Errorf("dimension mismatch: expected %d, got %d",
-			h.dim, len(embedding))
+		return ErrDimensionMismatch(h.dim, len(embedding))
 	}
 
-	// Check if already exists
-	if _, exists := h.vectors[id]; exists {
+	if slot, exists := h.ids[id]; exists {
+		if h.sameEmbedding(h.nodes[slot], embedding) {
+			if h.logger != nil {
+				h.logger.Debug("Vector already in index with same embedding, skipping",
+					logger.String("id", id))
+			}
+			return nil
+		}
+		h.removeSlot(slot)
 		if h.logger != nil {
-			h.logger.Debug("Vector already in index, skipping",
+			h.logger.Debug("Re-inserting updated vector into HNSW index",
 				logger.String("id", id))
 		}
-		return nil
-	}
-
-	// Create vector
-	vector := types.Vector{
-		ID:        id,
-		Embedding: embedding,
-		// Metadata is not stored in index
 	}
 
-	// Insert into HNSW (not Add!)
-	h.index.Insert(vector)
-
-	// Track vector
-	h.vectors[id] = vector
+	emb := make([]float32, len(embedding))
+	copy(emb, embedding)
+	h.insert(id, emb)
+	h.maybeCalibrate()
 
 	if h.logger != nil {
 		h.logger.Debug("Inserted vector into HNSW index",
 			logger.String("id", id),
-			logger.Int("total_vectors", len(h.vectors)))
+			logger.Int("total_vectors", len(h.ids)))
 	}
 
 	return nil
 }
 
+// maybeCalibrate fits the quantizer to the live vectors once enough of them
+// have been added, then swaps every embedding for its code.
+func (h *HNSWIndex) maybeCalibrate() {
+	if h.quantization == QuantizationNone || h.quantizer != nil || len(h.ids) < h.calibrationSize {
+		return
+	}
+
+	samples := make([][]float32, 0, len(h.ids))
+	for _, slot := range h.ids {
+		samples = append(samples, h.nodes[slot].embedding)
+	}
+	h.quantizer = trainQuantizer(h.quantization, samples, h.dim)
+	for _, slot := range h.ids {
+		node := h.nodes[slot]
+		node.code = h.quantizer.encode(node.embedding)
+		node.sum = embeddingSum(node.embedding)
+		node.embedding = nil
+	}
+
+	if h.logger != nil {
+		h.logger.Info("Calibrated HNSW quantizer",
+			logger.String("quantization", string(h.quantization)),
+			logger.Int("samples", len(samples)))
+	}
+}
+
 func (h *HNSWIndex) Search(query []float32, k int) ([]SearchResult, error) {
+	return h.SearchFiltered(query, k, nil)
+}
+
+// SearchFiltered returns the k nearest vectors accepted by allow. The filter
+// is applied while walking the graph, so rejected nodes are still traversed
+// but never take a result slot. A nil allow accepts everything.
+func (h *HNSWIndex) SearchFiltered(query []float32, k int, allow func(id string) bool) ([]SearchResult, error) {
+	return h.SearchWithEf(query, k, 0, allow)
+}
+
+// SearchWithOptions implements VectorIndex.
+func (h *HNSWIndex) SearchWithOptions(query []float32, k int, opts SearchOptions) ([]SearchResult, error) {
+	return h.SearchWithEf(query, k, opts.Ef, opts.Allow)
+}
+
+// SearchWithEf is SearchFiltered with the search effort for this query
+// only; an ef of 0 uses the index's configured effort. Larger values trade
+// latency for recall.
+func (h *HNSWIndex) SearchWithEf(query []float32, k, ef int, allow func(id string) bool) ([]SearchResult, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	// Validate dimensions
 	if len(query) != h.dim {
-		return nil, fmt.Errorf("query dimension mismatch: expected %d, got %d",
-			h.dim, len(query))
+		return nil, ErrDimensionMismatch(h.dim, len(query))
 	}
 
 	// Handle empty index
-	if len(h.vectors) == 0 {
+	if len(h.ids) == 0 || k < 1 {
 		return []SearchResult{}, nil
 	}
 
 	// Adjust k if we have fewer vectors
-	if k > len(h.vectors) {
-		k = len(h.vectors)
+	if k > len(h.ids) {
+		k = len(h.ids)
 	}
 
-	// Create query vector
-	queryVector := types.Vector{
-		ID:        "", // Anonymous query
-		Embedding: query,
+	if ef <= 0 {
+		ef = h.efSearch
+	}
+	if ef > maxEfSearch {
+		ef = maxEfSearch
+	}
+	if ef < k {
+		ef = k
 	}
 
-	// Search returns []Vector directly
-	neighbors := h.index.Search(queryVector, k, h.efSearch)
+	ep := h.descend(query, 0)
+	candidates := h.searchLayer(query, ep, ef, 0, allow)
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
 
 	// Convert to our result format
-	results := make([]SearchResult, 0, len(neighbors))
-	for _, vec := range neighbors {
-		// Calculate distance and similarity
-		distance, _ := vectormath.CosineDistance(query, vec.Embedding)
-		similarity, _ := vectormath.CosineSimilarity(query, vec.Embedding)
-
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
 		results = append(results, SearchResult{
-			ID:       vec.ID,
-			Distance: float64(distance),
-			Score:    float64(similarity),
+			ID:       h.nodes[c.slot].id,
+			Distance: float64(c.dist),
+			Score:    float64(h.metric.Score(c.dist)),
 		})
 	}
 
 	if h.logger != nil {
 		h.logger.Debug("Search completed",
 			logger.Int("results", len(results)),
-			logger.Int("requested_k", k))
+			logger.Int("requested_k", k),
+			logger.Int("ef", ef))
 	}
 
 	return results, nil
 }
 
+// Remove unlinks a vector from the graph and repairs the neighbour lists
+// that pointed at it.
 func (h *HNSWIndex) Remove(id string) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// HNSW doesn't support removal, just remove from tracking
-	if _, exists := h.vectors[id]; exists {
-		delete(h.vectors, id)
-		if h.logger != nil {
-			h.logger.Warn("Removed from tracking (node remains in HNSW graph)",
-				logger.String("id", id))
-		}
-		return nil
+	slot, exists := h.ids[id]
+	if !exists {
+		return ErrVectorNotInIndex(id)
 	}
 
-	return fmt.Errorf("vector %s not found in index", id)
+	h.removeSlot(slot)
+
+	if h.logger != nil {
+		h.logger.Debug("Removed vector from HNSW index",
+			logger.String("id", id),
+			logger.Int("total_vectors", len(h.ids)))
+	}
+	return nil
 }
 
+// Train is a no-op: the graph is built incrementally and its quantizer
+// calibrates itself from the vectors added.
+func (h *HNSWIndex) Train(samples [][]float32) error {
+	return nil
+}
+
+// TrainSize is 0 as the graph needs no training.
+func (h *HNSWIndex) TrainSize() int {
+	return 0
+}
+
+// Size returns the number of live vectors in the index.
 func (h *HNSWIndex) Size() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.index.Size() // Use the actual index size
+	return len(h.ids)
 }
 
 func (h *HNSWIndex) SetSearchEf(ef int) {
@@ -184,8 +305,8 @@ func (h *HNSWIndex) SetSearchEf(ef int) {
 	if ef < 1 {
 		ef = 1
 	}
-	if ef > 1000 {
-		ef = 1000
+	if ef > maxEfSearch {
+		ef = maxEfSearch
 	}
 
 	h.efSearch = ef
@@ -201,9 +322,375 @@ func (h *HNSWIndex) Stats() map[string]interface{} {
 	defer h.mu.RUnlock()
 
 	return map[string]interface{}{
-		"vectors":    h.index.Size(),
-		"dimensions": h.dim,
-		"ef_search":  h.efSearch,
-		"levels":     h.index.Level(),
+		"type":         TypeHNSW,
+		"vectors":      len(h.ids),
+		"dimensions":   h.dim,
+		"ef_search":    h.efSearch,
+		"metric":       string(h.metric),
+		"levels":       h.maxLevel + 1,
+		"tombstones":   h.tombstones,
+		"quantization": string(h.quantization),
+		"quantized":    h.quantizer != nil,
+	}
+}
+
+func (h *HNSWIndex) insert(id string, embedding []float32) {
+	level := h.randomLevel()
+	slot := uint32(len(h.nodes))
+	node := &hnswNode{
+		id:        id,
+		level:     level,
+		neighbors: make([][]uint32, level+1),
+	}
+	if h.quantizer != nil {
+		node.code = h.quantizer.encode(embedding)
+		node.sum = embeddingSum(embedding)
+	} else {
+		node.embedding = embedding
+	}
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = slot
+
+	if h.maxLevel < 0 {
+		h.entryPoint = slot
+		h.maxLevel = level
+		return
+	}
+
+	ep := h.descend(embedding, level)
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(embedding, ep, h.efConstruction, l, nil)
+		node.neighbors[l] = h.selectNeighbors(candidates, h.maxNeighbors(l))
+		for _, n := range node.neighbors[l] {
+			h.connect(n, slot, l)
+		}
+		ep = candidates[0]
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entryPoint = slot
+	}
+}
+
+func (h *HNSWIndex) removeSlot(slot uint32) {
+	node := h.nodes[slot]
+	delete(h.ids, node.id)
+	node.deleted = true
+	h.tombstones++
+
+	if len(h.ids) == 0 {
+		h.maxLevel = -1
+		h.clearNode(node)
+		return
+	}
+	if slot == h.entryPoint {
+		h.electEntryPoint()
+	}
+
+	// Nodes linking to the removed one are almost always among its own
+	// neighbours or its nearest nodes, so search around it on every layer.
+	buf := h.scratch.Get().(*[]float32)
+	defer h.scratch.Put(buf)
+	embedding := h.vectorOf(node, *buf)
+	ep := h.descend(embedding, node.level)
+	for l := min(node.level, h.maxLevel); l >= 0; l-- {
+		nearby := h.searchLayer(embedding, ep, h.efConstruction, l, nil)
+		ep = nearby[0]
+
+		affected := make(map[uint32]struct{}, len(nearby)+len(node.neighbors[l]))
+		for _, c := range nearby {
+			affected[c.slot] = struct{}{}
+		}
+		for _, n := range node.neighbors[l] {
+			affected[n] = struct{}{}
+		}
+
+		for n := range affected {
+			if h.nodes[n].deleted || !containsSlot(h.nodes[n].neighbors[l], slot) {
+				continue
+			}
+			h.repairNeighbors(n, node, l)
+		}
+	}
+
+	h.clearNode(node)
+}
+
+// repairNeighbors replaces the link to a removed node with the best
+// candidates from both neighbour lists.
+func (h *HNSWIndex) repairNeighbors(slot uint32, removed *hnswNode, level int) {
+	node := h.nodes[slot]
+	buf := h.scratch.Get().(*[]float32)
+	defer h.scratch.Put(buf)
+	embedding := h.vectorOf(node, *buf)
+	seen := map[uint32]struct{}{slot: {}}
+	candidates := make([]candidate, 0, len(node.neighbors[level])+len(removed.neighbors[level]))
+
+	for _, list := range [][]uint32{node.neighbors[level], removed.neighbors[level]} {
+		for _, n := range list {
+			if _, ok := seen[n]; ok || h.nodes[n].deleted {
+				continue
+			}
+			seen[n] = struct{}{}
+			candidates = append(candidates, candidate{
+				slot: n,
+				dist: h.nodeDistance(embedding, h.nodes[n]),
+			})
+		}
+	}
+	sortCandidates(candidates)
+	node.neighbors[level] = h.selectNeighbors(candidates, h.maxNeighbors(level))
+}
+
+func (h *HNSWIndex) clearNode(node *hnswNode) {
+	node.embedding = nil
+	node.code = nil
+	node.neighbors = nil
+}
+
+// electEntryPoint picks the live node with the highest level.
+func (h *HNSWIndex) electEntryPoint() {
+	h.maxLevel = -1
+	for slot, node := range h.nodes {
+		if !node.deleted && node.level > h.maxLevel {
+			h.maxLevel = node.level
+			h.entryPoint = uint32(slot)
+		}
+	}
+}
+
+// descend walks greedily from the entry point down to the given level and
+// returns the closest node found there.
+func (h *HNSWIndex) descend(query []float32, level int) candidate {
+	ep := candidate{
+		slot: h.entryPoint,
+		dist: h.nodeDistance(query, h.nodes[h.entryPoint]),
+	}
+	for l := h.maxLevel; l > level; l-- {
+		for changed := true; changed; {
+			changed = false
+			for _, n := range h.nodes[ep.slot].neighbors[l] {
+				if h.nodes[n].deleted {
+					continue
+				}
+				if d := h.nodeDistance(query, h.nodes[n]); d < ep.dist {
+					ep = candidate{slot: n, dist: d}
+					changed = true
+				}
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef live nodes closest to query on the given
+// layer, sorted by ascending distance. Nodes rejected by allow are walked
+// through but not returned; the walk continues until ef accepted nodes are
+// found or the reachable part of the layer is exhausted.
+func (h *HNSWIndex) searchLayer(query []float32, ep candidate, ef, level int, allow func(id string) bool) []candidate {
+	accepts := func(slot uint32) bool {
+		return allow == nil || allow(h.nodes[slot].id)
+	}
+
+	visited := map[uint32]struct{}{ep.slot: {}}
+	pending := &candidateQueue{}
+	found := &candidateQueue{farthestFirst: true}
+	heap.Push(pending, ep)
+	if accepts(ep.slot) {
+		heap.Push(found, ep)
+	}
+
+	for pending.Len() > 0 {
+		c := heap.Pop(pending).(candidate)
+		if found.Len() >= ef && c.dist > found.peek().dist {
+			break
+		}
+
+		for _, n := range h.nodes[c.slot].neighbors[level] {
+			if _, ok := visited[n]; ok {
+				continue
+			}
+			visited[n] = struct{}{}
+			if h.nodes[n].deleted {
+				continue
+			}
+
+			d := h.nodeDistance(query, h.nodes[n])
+			if found.Len() < ef || d < found.peek().dist {
+				heap.Push(pending, candidate{slot: n, dist: d})
+				if !accepts(n) {
+					continue
+				}
+				heap.Push(found, candidate{slot: n, dist: d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	return found.sorted()
+}
+
+// selectNeighbors applies the HNSW neighbour selection heuristic to
+// candidates sorted by ascending distance, keeping pruned ones as filler.
+func (h *HNSWIndex) selectNeighbors(candidates []candidate, m int) []uint32 {
+	selected := make([]uint32, 0, m)
+	var pruned []uint32
+	buf := h.scratch.Get().(*[]float32)
+	defer h.scratch.Put(buf)
+
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		keep := true
+		var embedding []float32
+		for _, s := range selected {
+			if embedding == nil {
+				embedding = h.vectorOf(h.nodes[c.slot], *buf)
+			}
+			if h.nodeDistance(embedding, h.nodes[s]) < c.dist {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.slot)
+		} else {
+			pruned = append(pruned, c.slot)
+		}
+	}
+
+	for _, p := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+// connect adds a link from src to dst, shrinking src's list if it overflows.
+func (h *HNSWIndex) connect(src, dst uint32, level int) {
+	node := h.nodes[src]
+	node.neighbors[level] = append(node.neighbors[level], dst)
+
+	m := h.maxNeighbors(level)
+	if len(node.neighbors[level]) <= m {
+		return
+	}
+
+	buf := h.scratch.Get().(*[]float32)
+	defer h.scratch.Put(buf)
+	embedding := h.vectorOf(node, *buf)
+	candidates := make([]candidate, 0, len(node.neighbors[level]))
+	for _, n := range node.neighbors[level] {
+		if h.nodes[n].deleted {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			slot: n,
+			dist: h.nodeDistance(embedding, h.nodes[n]),
+		})
+	}
+	sortCandidates(candidates)
+	node.neighbors[level] = h.selectNeighbors(candidates, m)
+}
+
+func (h *HNSWIndex) maxNeighbors(level int) int {
+	if level == 0 {
+		return h.m0
+	}
+	return h.m
+}
+
+func (h *HNSWIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+// distance returns the distance under the index metric, or the maximum
+// distance if it is undefined (e.g. cosine with a zero vector).
+func (h *HNSWIndex) distance(a, b []float32) float32 {
+	dist, err := h.metric.Distance(a, b)
+	if err != nil {
+		return h.metric.MaxDistance()
+	}
+	return dist
+}
+
+// nodeDistance returns the distance from query to a node, decoding the
+// node's code when the graph is quantized.
+func (h *HNSWIndex) nodeDistance(query []float32, node *hnswNode) float32 {
+	if node.code == nil {
+		return h.distance(query, node.embedding)
+	}
+	buf := h.scratch.Get().(*[]float32)
+	h.quantizer.decode(node.code, *buf)
+	dist := h.distance(query, *buf)
+	h.scratch.Put(buf)
+	return dist
+}
+
+// vectorOf returns a node's embedding, or its decoded approximation in buf
+// when the graph is quantized.
+func (h *HNSWIndex) vectorOf(node *hnswNode, buf []float32) []float32 {
+	if node.code == nil {
+		return node.embedding
+	}
+	h.quantizer.decode(node.code, buf)
+	return buf
+}
+
+// sameEmbedding reports whether a node already holds embedding. Quantized
+// nodes compare the fingerprint of the embedding they were encoded from, not
+// their code: an update close enough to encode identically still changes
+// the vector. Nodes loaded from version 3 snapshots don't know theirs and
+// never match.
+func (h *HNSWIndex) sameEmbedding(node *hnswNode, embedding []float32) bool {
+	if node.code == nil {
+		return equalEmbeddings(node.embedding, embedding)
+	}
+	return node.sum != 0 && node.sum == embeddingSum(embedding)
+}
+
+func sortCandidates(candidates []candidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+}
+
+func containsSlot(slots []uint32, slot uint32) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddingSum returns the 64-bit FNV-1a hash of an embedding's bits.
+func embeddingSum(embedding []float32) uint64 {
+	sum := uint64(14695981039346656037)
+	for _, v := range embedding {
+		bits := math.Float32bits(v)
+		for i := 0; i < 4; i++ {
+			sum ^= uint64(byte(bits >> (8 * i)))
+			sum *= 1099511628211
+		}
+	}
+	return sum
+}
+
+func equalEmbeddings(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
+	return true
 }
@@ -1,7 +1,14 @@
 package index
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
 )
 
 func TestHNSWIndex_Add(t *testing.T) {
@@ -27,3 +34,386 @@ func TestHNSWIndex_Add(t *testing.T) {
 		t.Errorf("Expected v1 as first result, got %s", results[0].ID)
 	}
 }
+
+func TestHNSWIndex_Remove(t *testing.T) {
+	idx := NewHNSWIndex(3, nil)
+
+	idx.Add("v1", []float32{1, 0, 0})
+	idx.Add("v2", []float32{0.9, 0.1, 0})
+	idx.Add("v3", []float32{0, 0, 1})
+
+	if err := idx.Remove("v1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	results, err := idx.Search([]float32{1, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.ID == "v1" {
+			t.Errorf("Removed vector v1 returned by search")
+		}
+	}
+
+	if err := idx.Remove("v1"); err == nil {
+		t.Errorf("Expected error removing v1 twice")
+	}
+	if idx.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", idx.Size())
+	}
+}
+
+func TestHNSWIndex_Update(t *testing.T) {
+	idx := NewHNSWIndex(3, nil)
+
+	idx.Add("v1", []float32{1, 0, 0})
+	idx.Add("v2", []float32{0, 1, 0})
+
+	// Re-adding with a new embedding moves v1 next to the z axis
+	idx.Add("v1", []float32{0, 0, 1})
+
+	results, err := idx.Search([]float32{0, 0, 1}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].ID != "v1" {
+		t.Fatalf("Expected updated v1 as nearest, got %v", results)
+	}
+	if results[0].Score < 0.99 {
+		t.Errorf("Expected score close to 1, got %f", results[0].Score)
+	}
+	if idx.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", idx.Size())
+	}
+}
+
+func TestHNSWIndex_RemoveKeepsGraphSearchable(t *testing.T) {
+	idx := NewHNSWIndex(8, nil)
+	rng := rand.New(rand.NewSource(1))
+
+	embeddings := make(map[string][]float32)
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("v%d", i)
+		emb := make([]float32, 8)
+		for j := range emb {
+			emb[j] = rng.Float32() - 0.5
+		}
+		embeddings[id] = emb
+		if err := idx.Add(id, emb); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Remove every other vector, including whichever one is the entry point
+	for i := 0; i < 500; i += 2 {
+		if err := idx.Remove(fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+
+	found := 0
+	for i := 1; i < 500; i += 2 {
+		id := fmt.Sprintf("v%d", i)
+		results, err := idx.Search(embeddings[id], 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) == 1 && results[0].ID == id {
+			found++
+		}
+	}
+
+	if found < 240 {
+		t.Errorf("Expected remaining vectors to find themselves, got %d/250", found)
+	}
+}
+
+func TestHNSWIndex_SaveLoad(t *testing.T) {
+	idx := NewHNSWIndex(3, nil)
+
+	idx.Add("v1", []float32{1, 0, 0})
+	idx.Add("v2", []float32{0, 1, 0})
+	idx.Add("v3", []float32{0, 0, 1})
+	idx.Remove("v2")
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	snapshot := buf.Bytes()
+
+	loaded := NewHNSWIndex(3, nil)
+	if err := loaded.Load(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if loaded.Size() != 2 {
+		t.Errorf("Expected 2 vectors after load, got %d", loaded.Size())
+	}
+
+	results, err := loaded.Search([]float32{0, 0, 1}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "v3" {
+		t.Errorf("Expected v3 as first result, got %v", results)
+	}
+
+	if err := NewHNSWIndex(4, nil).Load(bytes.NewReader(snapshot)); err == nil {
+		t.Errorf("Expected dimension mismatch loading into 4-dim index")
+	}
+}
+
+func TestHNSWIndex_LoadCorrupt(t *testing.T) {
+	idx := NewHNSWIndex(3, nil)
+	for i := 0; i < 50; i++ {
+		idx.Add(fmt.Sprintf("v%d", i), []float32{float32(i), 1, float32(i % 7)})
+	}
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	// The first node follows the header and the quantization header
+	node := binary.Size(hnswSnapshotHeader{}) + binary.Size(hnswQuantizationHeader{})
+	idLen := int(binary.LittleEndian.Uint32(snapshot[node:]))
+	patch := func(offset int, v uint32) []byte {
+		corrupt := append([]byte(nil), snapshot...)
+		binary.LittleEndian.PutUint32(corrupt[offset:], v)
+		return corrupt
+	}
+	withHeader := func(edit func(*hnswSnapshotHeader)) []byte {
+		var header hnswSnapshotHeader
+		binary.Read(bytes.NewReader(snapshot), binary.LittleEndian, &header)
+		edit(&header)
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, header)
+		return append(b.Bytes(), snapshot[b.Len():]...)
+	}
+
+	cases := map[string][]byte{
+		"id length":   patch(node, 1<<31),
+		"node level":  patch(node+4+idLen, 1000),
+		"max level":   withHeader(func(h *hnswSnapshotHeader) { h.MaxLevel = 1000 }),
+		"entry point": withHeader(func(h *hnswSnapshotHeader) { h.EntryPoint = 1 << 30 }),
+		"node count":  withHeader(func(h *hnswSnapshotHeader) { h.Nodes = 1 << 31 }),
+		"links":       withHeader(func(h *hnswSnapshotHeader) { h.M, h.M0 = 1, 1 }),
+	}
+	for name, corrupt := range cases {
+		if err := NewHNSWIndex(3, nil).Load(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("%s: expected the corrupt snapshot to fail to load", name)
+		}
+	}
+	for n := 0; n < len(snapshot); n++ {
+		if err := NewHNSWIndex(3, nil).Load(bytes.NewReader(snapshot[:n])); err == nil {
+			t.Fatalf("Expected a snapshot cut at %d bytes to fail to load", n)
+		}
+	}
+}
+
+func TestHNSWIndex_SearchFiltered(t *testing.T) {
+	idx := NewHNSWIndex(2, nil)
+	for i := 0; i < 100; i++ {
+		idx.Add(fmt.Sprintf("v%d", i), []float32{1, float32(i) / 100})
+	}
+
+	// Only odd IDs are accepted, and the nearest vectors are all even
+	odd := func(id string) bool {
+		var n int
+		fmt.Sscanf(id, "v%d", &n)
+		return n%2 == 1
+	}
+
+	results, err := idx.SearchFiltered([]float32{1, 0}, 5, odd)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 filtered results, got %d", len(results))
+	}
+	for _, r := range results {
+		if !odd(r.ID) {
+			t.Errorf("Filtered search returned rejected vector %s", r.ID)
+		}
+	}
+}
+
+func TestHNSWIndex_SearchWithEf(t *testing.T) {
+	idx := NewHNSWIndexWithConfig(HNSWConfig{Dimensions: 8, EfSearch: 10}, nil)
+	rng := rand.New(rand.NewSource(1))
+	embeddings := make(map[string][]float32)
+	for i := 0; i < 300; i++ {
+		emb := make([]float32, 8)
+		for j := range emb {
+			emb[j] = rng.Float32()
+		}
+		id := fmt.Sprintf("v%d", i)
+		embeddings[id] = emb
+		idx.Add(id, emb)
+	}
+
+	query := embeddings["v7"]
+	results, err := idx.SearchWithEf(query, 10, maxEfSearch, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// With ef above the graph size the search visits every node, so it
+	// must agree with a brute-force scan
+	var want []string
+	for id, emb := range embeddings {
+		d, _ := vectormath.Cosine.Distance(query, emb)
+		if d <= float32(results[len(results)-1].Distance) {
+			want = append(want, id)
+		}
+	}
+	if len(results) != 10 || len(want) != 10 {
+		t.Fatalf("Expected the exact top 10, got %d results and %d within range", len(results), len(want))
+	}
+
+	if ef := idx.Stats()["ef_search"]; ef != 10 {
+		t.Errorf("Expected per-query ef to leave ef_search at 10, got %v", ef)
+	}
+}
+
+func TestHNSWIndex_Metrics(t *testing.T) {
+	tests := []struct {
+		metric vectormath.Metric
+		want   string
+		score  float64
+	}{
+		// v2 points the same way as the query but is far away
+		{vectormath.Cosine, "v2", 1},
+		{vectormath.Euclidean, "v1", 1 / (1 + math.Sqrt(0.5))},
+		{vectormath.Manhattan, "v1", 1 / (1 + 1.0)},
+		{vectormath.DotProduct, "v2", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			idx := NewHNSWIndexWithConfig(HNSWConfig{Dimensions: 2, Metric: tt.metric}, nil)
+			idx.Add("v1", []float32{0.5, 0.5})
+			idx.Add("v2", []float32{10, 0})
+
+			results, err := idx.Search([]float32{1, 0}, 1)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != 1 || results[0].ID != tt.want {
+				t.Fatalf("Expected %s as nearest, got %v", tt.want, results)
+			}
+			if math.Abs(results[0].Score-tt.score) > 1e-4 {
+				t.Errorf("Expected score %f, got %f", tt.score, results[0].Score)
+			}
+		})
+	}
+}
+
+func TestHNSWIndex_Quantization(t *testing.T) {
+	tests := []struct {
+		quantization Quantization
+		k            int
+	}{
+		{QuantizationInt8, 10},
+		// One bit per dimension can barely tell vectors of the same cluster
+		// apart, so it only finds the neighbourhood and callers rescore a
+		// long candidate list
+		{QuantizationBinary, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.quantization), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(4))
+			vectors := clusteredVectors(rng, 1000, 32)
+
+			cfg := HNSWConfig{Dimensions: 32, Quantization: tt.quantization, CalibrationSize: 500}
+			idx := NewHNSWIndexWithConfig(cfg, nil)
+			for id, v := range vectors {
+				if err := idx.Add(id, v); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+			if idx.quantizer == nil {
+				t.Fatalf("Expected the quantizer to be calibrated")
+			}
+			for _, slot := range idx.ids {
+				if node := idx.nodes[slot]; node.embedding != nil || len(node.code) != idx.quantizer.codeSize() {
+					t.Fatalf("Expected %s to keep only its code", node.id)
+				}
+			}
+
+			hits := 0
+			for q := 0; q < 50; q++ {
+				query := vectors[fmt.Sprintf("v%d", rng.Intn(len(vectors)))]
+				want := exactNearest(vectors, query, 1, vectormath.Cosine)[0]
+				results, err := idx.Search(query, tt.k)
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				for _, r := range results {
+					if r.ID == want {
+						hits++
+						break
+					}
+				}
+			}
+			if hits < 45 {
+				t.Errorf("Expected recall@%d of at least 0.9, got %d/50", tt.k, hits)
+			}
+
+			// Re-adding a vector unchanged is skipped, while an update too
+			// small to change its code still replaces it
+			tombstones := idx.tombstones
+			idx.Add("v3", vectors["v3"])
+			if idx.tombstones != tombstones {
+				t.Errorf("Expected an unchanged vector to be skipped")
+			}
+			nudged := append([]float32(nil), vectors["v3"]...)
+			nudged[0] += 1e-6
+			idx.Add("v3", nudged)
+			if idx.tombstones != tombstones+1 {
+				t.Errorf("Expected a nudged vector to be re-inserted")
+			}
+			vectors["v3"] = nudged
+
+			var buf bytes.Buffer
+			if err := idx.Save(&buf); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			snapshot := buf.Bytes()
+
+			restored := NewHNSWIndexWithConfig(cfg, nil)
+			if err := restored.Load(bytes.NewReader(snapshot)); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			query := vectors["v7"]
+			want, _ := idx.Search(query, 5)
+			got, _ := restored.Search(query, 5)
+			if fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("Restored index returned %v, want %v", got, want)
+			}
+
+			// After a restart the WAL replays puts the snapshot already
+			// holds; they must not churn the graph
+			for id, v := range vectors {
+				restored.Add(id, v)
+			}
+			if restored.tombstones != 0 {
+				t.Errorf("Expected replayed vectors to be skipped, got %d re-inserted", restored.tombstones)
+			}
+
+			if err := NewHNSWIndex(32, nil).Load(bytes.NewReader(snapshot)); err == nil {
+				t.Errorf("Expected loading into an unquantized index to fail")
+			}
+		})
+	}
+}
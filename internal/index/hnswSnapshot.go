@@ -0,0 +1,385 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// Version 3 added the quantization section and version 4 the fingerprint
+// of every quantized node's embedding. Version 2 snapshots are still read
+// as unquantized graphs, and the quantized nodes of version 3 ones as
+// having no fingerprint.
+const hnswSnapshotVersion uint8 = 4
+
+var hnswSnapshotMagic = [4]byte{'H', 'N', 'S', 'W'}
+
+// Bounds on what a snapshot may claim, so that a corrupt one fails to load
+// instead of allocating gigabytes or building a graph that can't be walked.
+const (
+	maxSnapshotIDLen = 1 << 16
+	maxSnapshotLevel = 64 // Random levels never get near it
+	maxSnapshotLinks = 1 << 12
+)
+
+// hnswSnapshotHeader is the fixed-size preamble of a serialized graph.
+type hnswSnapshotHeader struct {
+	Magic          [4]byte
+	Version        uint8
+	Dim            uint32
+	M              uint32
+	M0             uint32
+	EfConstruction uint32
+	EfSearch       uint32
+	LevelMult      float64
+	Metric         [16]byte
+	Nodes          uint32
+	EntryPoint     uint32
+	MaxLevel       int32
+}
+
+// hnswQuantizationHeader follows the header from version 3 on. When the
+// quantizer is calibrated its parameters come next and every node carries
+// its code and fingerprint instead of the embedding.
+type hnswQuantizationHeader struct {
+	Kind       [8]byte
+	Calibrated uint8
+}
+
+// Save serializes the graph: parameters, entry point and every live node
+// with its neighbour lists. Tombstones are dropped and slots renumbered, so
+// a saved and reloaded graph is also compacted.
+func (h *HNSWIndex) Save(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	remap := make(map[uint32]uint32, len(h.ids))
+	live := make([]*hnswNode, 0, len(h.ids))
+	for slot, node := range h.nodes {
+		if node.deleted {
+			continue
+		}
+		remap[uint32(slot)] = uint32(len(live))
+		live = append(live, node)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := hnswSnapshotHeader{
+		Magic:          hnswSnapshotMagic,
+		Version:        hnswSnapshotVersion,
+		Dim:            uint32(h.dim),
+		M:              uint32(h.m),
+		M0:             uint32(h.m0),
+		EfConstruction: uint32(h.efConstruction),
+		EfSearch:       uint32(h.efSearch),
+		LevelMult:      h.levelMult,
+		Nodes:          uint32(len(live)),
+		EntryPoint:     remap[h.entryPoint],
+		MaxLevel:       int32(h.maxLevel),
+	}
+	copy(header.Metric[:], h.metric)
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+
+	var quantization hnswQuantizationHeader
+	copy(quantization.Kind[:], h.quantization)
+	if h.quantizer != nil {
+		quantization.Calibrated = 1
+	}
+	if err := binary.Write(bw, binary.LittleEndian, quantization); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	if h.quantizer != nil {
+		if err := h.quantizer.save(bw); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+
+	for _, node := range live {
+		if err := writeSnapshotNode(bw, node, remap); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	return nil
+}
+
+// Load replaces the graph with one previously written by Save. The snapshot
+// must have been taken from an index with the same dimensions, metric and
+// quantization.
+func (h *HNSWIndex) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	var header hnswSnapshotHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return ErrSnapshotRead(err)
+	}
+	if header.Magic != hnswSnapshotMagic {
+		return ErrSnapshotRead(fmt.Errorf("bad magic %q", header.Magic[:]))
+	}
+	if header.Version < 2 || header.Version > hnswSnapshotVersion {
+		return ErrSnapshotRead(fmt.Errorf("unsupported version %d", header.Version))
+	}
+	if int(header.Dim) != h.dim {
+		return ErrDimensionMismatch(h.dim, int(header.Dim))
+	}
+	if metric := vectormath.Metric(bytes.TrimRight(header.Metric[:], "\x00")); metric != h.metric {
+		return ErrMetricMismatch(h.metric, metric)
+	}
+	if err := header.validate(); err != nil {
+		return ErrSnapshotRead(err)
+	}
+
+	kind := QuantizationNone
+	var q quantizer
+	if header.Version >= 3 {
+		var quantization hnswQuantizationHeader
+		if err := binary.Read(br, binary.LittleEndian, &quantization); err != nil {
+			return ErrSnapshotRead(err)
+		}
+		kind = Quantization(bytes.TrimRight(quantization.Kind[:], "\x00"))
+		if kind == h.quantization && quantization.Calibrated == 1 {
+			var err error
+			if q, err = loadQuantizer(kind, br, h.dim); err != nil {
+				return ErrSnapshotRead(err)
+			}
+		}
+	}
+	if kind != h.quantization {
+		return ErrQuantizationMismatch(h.quantization, kind)
+	}
+	codeSize := 0
+	if q != nil {
+		codeSize = q.codeSize()
+	}
+
+	// The node count is only trusted as far as there are nodes to read
+	nodes := make([]*hnswNode, 0, min(header.Nodes, 1<<16))
+	ids := make(map[string]uint32, min(header.Nodes, 1<<16))
+	for i := uint32(0); i < header.Nodes; i++ {
+		node, err := readSnapshotNode(br, &header, h.dim, codeSize)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		if _, ok := ids[node.id]; ok {
+			return ErrSnapshotRead(fmt.Errorf("duplicate ID %q", node.id))
+		}
+		nodes = append(nodes, node)
+		ids[node.id] = i
+	}
+	if err := checkSnapshotGraph(nodes, &header); err != nil {
+		return ErrSnapshotRead(err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.m = int(header.M)
+	h.m0 = int(header.M0)
+	h.efConstruction = int(header.EfConstruction)
+	h.efSearch = int(header.EfSearch)
+	h.levelMult = header.LevelMult
+	h.nodes = nodes
+	h.ids = ids
+	h.entryPoint = header.EntryPoint
+	h.maxLevel = int(header.MaxLevel)
+	h.tombstones = 0
+	h.quantizer = q
+	if len(nodes) == 0 {
+		h.maxLevel = -1
+	}
+	h.maybeCalibrate()
+	return nil
+}
+
+// IDs returns the IDs of all live vectors in the index.
+func (h *HNSWIndex) IDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.ids))
+	for id := range h.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func writeSnapshotNode(w io.Writer, node *hnswNode, remap map[uint32]uint32) error {
+	if err := writeUint32(w, uint32(len(node.id))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, node.id); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(node.level)); err != nil {
+		return err
+	}
+	if node.code != nil {
+		if _, err := w.Write(node.code); err != nil {
+			return err
+		}
+		var sum [8]byte
+		binary.LittleEndian.PutUint64(sum[:], node.sum)
+		if _, err := w.Write(sum[:]); err != nil {
+			return err
+		}
+	}
+	for _, v := range node.embedding {
+		if err := writeUint32(w, math.Float32bits(v)); err != nil {
+			return err
+		}
+	}
+	for _, links := range node.neighbors {
+		live := make([]uint32, 0, len(links))
+		for _, n := range links {
+			if slot, ok := remap[n]; ok {
+				live = append(live, slot)
+			}
+		}
+		if err := writeUint32(w, uint32(len(live))); err != nil {
+			return err
+		}
+		for _, n := range live {
+			if err := writeUint32(w, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the graph parameters of a header read from a snapshot.
+func (header *hnswSnapshotHeader) validate() error {
+	if header.M == 0 || header.M > maxSnapshotLinks || header.M0 == 0 || header.M0 > maxSnapshotLinks {
+		return fmt.Errorf("links per node %d/%d out of range", header.M, header.M0)
+	}
+	if !(header.LevelMult > 0 && header.LevelMult <= 1/math.Ln2) {
+		return fmt.Errorf("level multiplier %v out of range", header.LevelMult)
+	}
+	if header.MaxLevel > maxSnapshotLevel || header.MaxLevel < 0 && (header.MaxLevel != -1 || header.Nodes > 0) {
+		return fmt.Errorf("max level %d out of range", header.MaxLevel)
+	}
+	return nil
+}
+
+// checkSnapshotGraph checks that the graph read from a snapshot can be
+// walked: the entry point sits on the top level and every link on a level
+// leads to a node that has it.
+func checkSnapshotGraph(nodes []*hnswNode, header *hnswSnapshotHeader) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	if header.EntryPoint >= uint32(len(nodes)) {
+		return fmt.Errorf("entry point %d out of range", header.EntryPoint)
+	}
+	if nodes[header.EntryPoint].level != int(header.MaxLevel) {
+		return fmt.Errorf("entry point on level %d, not %d", nodes[header.EntryPoint].level, header.MaxLevel)
+	}
+	for _, node := range nodes {
+		for l, links := range node.neighbors {
+			for _, n := range links {
+				if nodes[n].level < l {
+					return fmt.Errorf("link from %q to %q on level %d", node.id, nodes[n].id, l)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readSnapshotNode reads a node written by writeSnapshotNode, checking it
+// against the header. A non-zero codeSize means the node holds a quantized
+// code rather than its embedding; one holding its embedding gets its
+// fingerprint back from it.
+func readSnapshotNode(r io.Reader, header *hnswSnapshotHeader, dim, codeSize int) (*hnswNode, error) {
+	idLen, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if idLen > maxSnapshotIDLen {
+		return nil, fmt.Errorf("ID length %d out of range", idLen)
+	}
+	id := make([]byte, idLen)
+	if _, err := io.ReadFull(r, id); err != nil {
+		return nil, err
+	}
+	level, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(level) > int64(header.MaxLevel) {
+		return nil, fmt.Errorf("node level %d above max level %d", level, header.MaxLevel)
+	}
+
+	node := &hnswNode{id: string(id), level: int(level)}
+	if codeSize > 0 {
+		node.code = make([]byte, codeSize)
+		if _, err := io.ReadFull(r, node.code); err != nil {
+			return nil, err
+		}
+		if header.Version >= 4 {
+			var sum [8]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return nil, err
+			}
+			node.sum = binary.LittleEndian.Uint64(sum[:])
+		}
+	} else {
+		if node.embedding, err = readFloats(r, dim); err != nil {
+			return nil, err
+		}
+		node.sum = embeddingSum(node.embedding)
+	}
+
+	neighbors := make([][]uint32, level+1)
+	for l := range neighbors {
+		count, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		limit := header.M
+		if l == 0 {
+			limit = header.M0
+		}
+		if count > limit {
+			return nil, fmt.Errorf("%d links on level %d, more than %d", count, l, limit)
+		}
+		links := make([]uint32, count)
+		for i := range links {
+			if links[i], err = readUint32(r); err != nil {
+				return nil, err
+			}
+			if links[i] >= header.Nodes {
+				return nil, fmt.Errorf("neighbor %d out of range", links[i])
+			}
+		}
+		neighbors[l] = links
+	}
+
+	node.neighbors = neighbors
+	return node, nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
@@ -1,7 +1,35 @@
 package index
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
 
 func ErrDimensionMismatch(dim int, embeddingLen int) error {
 	return fmt.Errorf("dimension mismatch: expected %d, got %d", dim, embeddingLen)
 }
+
+func ErrVectorNotInIndex(id string) error {
+	return fmt.Errorf("vector %s not found in index", id)
+}
+
+func ErrSnapshotWrite(err error) error {
+	return fmt.Errorf("failed to write index snapshot: %w", err)
+}
+
+func ErrSnapshotRead(err error) error {
+	return fmt.Errorf("failed to read index snapshot: %w", err)
+}
+
+func ErrMetricMismatch(expected, actual vectormath.Metric) error {
+	return fmt.Errorf("metric mismatch: expected %s, got %s", expected, actual)
+}
+
+func ErrUnsupportedQuantization(quantization string) error {
+	return fmt.Errorf("unsupported quantization %q", quantization)
+}
+
+func ErrQuantizationMismatch(expected, actual Quantization) error {
+	return fmt.Errorf("quantization mismatch: expected %s, got %s", expected, actual)
+}
@@ -1,7 +1,35 @@
 package index
 
+import "io"
+
+// VectorIndex is the contract every index type fulfils; collections only
+// ever see this interface, and types are plugged in through Register.
+// Implementations are safe for concurrent use.
 type VectorIndex interface {
+	// Add inserts a vector, replacing any vector with the same ID
 	Add(id string, embedding []float32) error
+	Remove(id string) error
+	SearchWithOptions(query []float32, k int, opts SearchOptions) ([]SearchResult, error)
+	Size() int
+	IDs() []string
+	// Save and Load serialize the index; Load rejects snapshots taken with
+	// a different dimension or metric
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+	Stats() map[string]interface{}
+	// Train fits the index to a sample of the data, such as one drawn from
+	// the store, and TrainSize is how many samples it wants. Indexes that
+	// can't be (re)trained report a TrainSize of 0 and ignore Train.
+	Train(samples [][]float32) error
+	TrainSize() int
+}
+
+// SearchOptions tunes a single query; zero values use the index's own
+// configuration, and options an index doesn't have are ignored.
+type SearchOptions struct {
+	Ef     int                  // HNSW candidate list size
+	NProbe int                  // IVF lists scanned
+	Allow  func(id string) bool // Filter applied during the search; nil accepts everything
 }
 
 type SearchResult struct {
@@ -0,0 +1,359 @@
+package index
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// IVFFlatConfig holds the parameters of an IVF-Flat index; zero values
+// select the defaults.
+type IVFFlatConfig struct {
+	Dimensions int
+	Metric     vectormath.Metric // Cosine when empty
+	NList      int               // Clusters
+	NProbe     int               // Clusters scanned per query
+	TrainSize  int               // Vectors added before the index trains itself
+}
+
+// flatList is one cluster: the IDs assigned to it and their embeddings,
+// dim floats per vector in the same order.
+type flatList struct {
+	ids     []string
+	vectors []float32
+}
+
+// IVFFlatIndex is an inverted file index that keeps full embeddings.
+// Vectors are assigned to their nearest k-means centroid and queries scan
+// the nprobe closest lists with exact distances, so it sits between brute
+// force and HNSW: cheap to build and update, with recall set by nprobe.
+//
+// Until it is trained all vectors sit in a single list and every search is
+// exact. The index trains itself on its own vectors once TrainSize have been
+// added, and Train refits the centroids on any sample, such as one drawn
+// from the store after the data has drifted.
+type IVFFlatIndex struct {
+	mu        sync.RWMutex
+	dim       int
+	logger    logger.Logger
+	metric    vectormath.Metric
+	nlist     int
+	nprobe    int
+	trainSize int
+	rng       *rand.Rand
+
+	centroids [][]float32 // nil until trained
+	lists     []flatList
+	ids       map[string]ivfLocation
+
+	training sync.Mutex          // Held by Train for the whole fit
+	changed  map[string]struct{} // IDs written while Train fits; nil otherwise
+}
+
+func NewIVFFlatIndex(cfg IVFFlatConfig, log logger.Logger) *IVFFlatIndex {
+	if cfg.NList <= 0 {
+		cfg.NList = defaultNList
+	}
+	if cfg.NProbe <= 0 {
+		cfg.NProbe = defaultNProbe
+	}
+	if cfg.TrainSize <= 0 {
+		cfg.TrainSize = cfg.NList * trainSamplesPerList
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = vectormath.Cosine
+	}
+
+	return &IVFFlatIndex{
+		dim:       cfg.Dimensions,
+		logger:    log,
+		metric:    cfg.Metric,
+		nlist:     cfg.NList,
+		nprobe:    cfg.NProbe,
+		trainSize: cfg.TrainSize,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		lists:     make([]flatList, 1),
+		ids:       make(map[string]ivfLocation),
+	}
+}
+
+// Add stores a vector in its nearest list, replacing any vector with the
+// same ID. The add that reaches TrainSize on an untrained index trains it.
+func (x *IVFFlatIndex) Add(id string, embedding []float32) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if len(embedding) != x.dim {
+		return ErrDimensionMismatch(x.dim, len(embedding))
+	}
+
+	x.remove(id)
+	x.insert(id, prepareVector(embedding, x.metric))
+	x.markChanged(id)
+	if x.centroids == nil && len(x.ids) >= x.trainSize {
+		x.train(x.sample(x.trainSize))
+	}
+	return nil
+}
+
+func (x *IVFFlatIndex) Remove(id string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.remove(id) {
+		return ErrVectorNotInIndex(id)
+	}
+	x.markChanged(id)
+	return nil
+}
+
+// Train fits nlist centroids to samples with k-means and reassigns every
+// vector in the index to its nearest one. Both run on a copy of the vectors
+// without the lock, so writes and searches go on meanwhile; the vectors
+// written during the fit are reassigned when the new lists are swapped in.
+// Training on no samples leaves the index unchanged.
+func (x *IVFFlatIndex) Train(samples [][]float32) error {
+	prepared := make([][]float32, len(samples))
+	for i, s := range samples {
+		if len(s) != x.dim {
+			return ErrDimensionMismatch(x.dim, len(s))
+		}
+		prepared[i] = prepareVector(s, x.metric)
+	}
+	if len(prepared) == 0 {
+		return nil
+	}
+
+	x.training.Lock()
+	defer x.training.Unlock()
+	start := time.Now()
+
+	x.mu.Lock()
+	ids := make([]string, 0, len(x.ids))
+	vectors := make([]float32, 0, len(x.ids)*x.dim)
+	for _, l := range x.lists {
+		ids = append(ids, l.ids...)
+		vectors = append(vectors, l.vectors...)
+	}
+	x.changed = make(map[string]struct{})
+	rng := rand.New(rand.NewSource(x.rng.Int63()))
+	x.mu.Unlock()
+
+	centroids := kmeans(prepared, x.nlist, kmeansIterations, rng)
+	assigned := make([]int, len(ids))
+	for i := range ids {
+		assigned[i], _ = nearestCentroid(vectors[i*x.dim:(i+1)*x.dim], centroids)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	old, oldIDs, changed := x.lists, x.ids, x.changed
+	x.changed = nil
+	x.centroids = centroids
+	x.lists = make([]flatList, len(centroids))
+	x.ids = make(map[string]ivfLocation, len(oldIDs))
+	for i, id := range ids {
+		if _, ok := changed[id]; !ok {
+			x.insertInto(assigned[i], id, vectors[i*x.dim:(i+1)*x.dim])
+		}
+	}
+	// The ones written during the fit are placed as they are now
+	for id := range changed {
+		if loc, ok := oldIDs[id]; ok {
+			x.insert(id, old[loc.list].vectors[loc.pos*x.dim:(loc.pos+1)*x.dim])
+		}
+	}
+
+	if x.logger != nil {
+		x.logger.Info("IVF-Flat index retrained",
+			logger.Int("samples", len(prepared)),
+			logger.Int("lists", len(x.centroids)),
+			logger.Int("vectors", len(x.ids)),
+			logger.Int("written_meanwhile", len(changed)),
+			logger.Duration("duration", time.Since(start)))
+	}
+	return nil
+}
+
+// markChanged records a write made while Train fits. The caller holds the
+// write lock.
+func (x *IVFFlatIndex) markChanged(id string) {
+	if x.changed != nil {
+		x.changed[id] = struct{}{}
+	}
+}
+
+// TrainSize returns the number of samples the index wants to be trained on.
+func (x *IVFFlatIndex) TrainSize() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.trainSize
+}
+
+// SearchWithOptions returns the k nearest vectors accepted by opts.Allow
+// among the opts.NProbe lists closest to the query. Distances are exact.
+func (x *IVFFlatIndex) SearchWithOptions(query []float32, k int, opts SearchOptions) ([]SearchResult, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if len(query) != x.dim {
+		return nil, ErrDimensionMismatch(x.dim, len(query))
+	}
+	if len(x.ids) == 0 || k < 1 {
+		return []SearchResult{}, nil
+	}
+
+	q := prepareVector(query, x.metric)
+	lists := []int{0}
+	if x.centroids != nil {
+		nprobe := opts.NProbe
+		if nprobe <= 0 {
+			nprobe = x.nprobe
+		}
+		lists = nearestLists(q, x.centroids, nprobe, x.metric)
+	}
+
+	top := newTopK(k, opts.Allow)
+	for _, list := range lists {
+		l := &x.lists[list]
+		for pos, id := range l.ids {
+			top.offer(id, x.distance(q, l.vectors[pos*x.dim:(pos+1)*x.dim]))
+		}
+	}
+
+	results := top.results(x.metric)
+	if x.logger != nil {
+		x.logger.Debug("Search completed",
+			logger.Int("results", len(results)),
+			logger.Int("requested_k", k),
+			logger.Int("lists_scanned", len(lists)))
+	}
+	return results, nil
+}
+
+// Size returns the number of vectors in the index.
+func (x *IVFFlatIndex) Size() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.ids)
+}
+
+// IDs returns the IDs of all vectors in the index.
+func (x *IVFFlatIndex) IDs() []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	ids := make([]string, 0, len(x.ids))
+	for id := range x.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (x *IVFFlatIndex) Stats() map[string]interface{} {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return map[string]interface{}{
+		"type":       TypeIVFFlat,
+		"vectors":    len(x.ids),
+		"dimensions": x.dim,
+		"metric":     string(x.metric),
+		"nlist":      x.nlist,
+		"nprobe":     x.nprobe,
+		"trained":    x.centroids != nil,
+		"lists":      len(x.lists),
+	}
+}
+
+func (x *IVFFlatIndex) distance(a, b []float32) float32 {
+	dist, err := x.metric.Distance(a, b)
+	if err != nil {
+		return x.metric.MaxDistance()
+	}
+	return dist
+}
+
+// train replaces the centroids with ones fitted to prepared samples and
+// redistributes the vectors. The caller holds the write lock.
+func (x *IVFFlatIndex) train(samples [][]float32) {
+	start := time.Now()
+
+	old := x.lists
+	x.centroids = kmeans(samples, x.nlist, kmeansIterations, x.rng)
+	x.lists = make([]flatList, len(x.centroids))
+	x.ids = make(map[string]ivfLocation, len(x.ids))
+	for _, l := range old {
+		for pos, id := range l.ids {
+			x.insert(id, l.vectors[pos*x.dim:(pos+1)*x.dim])
+		}
+	}
+
+	if x.logger != nil {
+		x.logger.Info("IVF-Flat index trained",
+			logger.Int("samples", len(samples)),
+			logger.Int("lists", len(x.centroids)),
+			logger.Int("vectors", len(x.ids)),
+			logger.Duration("duration", time.Since(start)))
+	}
+}
+
+// sample returns up to n of the indexed vectors, chosen at random. The
+// caller holds the lock.
+func (x *IVFFlatIndex) sample(n int) [][]float32 {
+	all := make([][]float32, 0, len(x.ids))
+	for _, l := range x.lists {
+		for pos := range l.ids {
+			all = append(all, l.vectors[pos*x.dim:(pos+1)*x.dim])
+		}
+	}
+	if len(all) <= n {
+		return all
+	}
+	x.rng.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+	return all[:n]
+}
+
+// insert appends a prepared vector to its nearest list. The caller holds
+// the write lock.
+func (x *IVFFlatIndex) insert(id string, v []float32) {
+	list := 0
+	if x.centroids != nil {
+		list, _ = nearestCentroid(v, x.centroids)
+	}
+	x.insertInto(list, id, v)
+}
+
+// insertInto appends a prepared vector to the given list. The caller holds
+// the write lock.
+func (x *IVFFlatIndex) insertInto(list int, id string, v []float32) {
+	l := &x.lists[list]
+	x.ids[id] = ivfLocation{list: list, pos: len(l.ids)}
+	l.ids = append(l.ids, id)
+	l.vectors = append(l.vectors, v...)
+}
+
+// remove drops a vector from its list, moving the list's last vector into
+// its place. The caller holds the write lock.
+func (x *IVFFlatIndex) remove(id string) bool {
+	loc, ok := x.ids[id]
+	if !ok {
+		return false
+	}
+	l := &x.lists[loc.list]
+	last := len(l.ids) - 1
+	if loc.pos != last {
+		moved := l.ids[last]
+		l.ids[loc.pos] = moved
+		copy(l.vectors[loc.pos*x.dim:(loc.pos+1)*x.dim], l.vectors[last*x.dim:])
+		x.ids[moved] = loc
+	}
+	l.ids = l.ids[:last]
+	l.vectors = l.vectors[:last*x.dim]
+	delete(x.ids, id)
+	return true
+}
@@ -0,0 +1,201 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+func TestIVFFlatIndex_Recall(t *testing.T) {
+	for _, metric := range []vectormath.Metric{vectormath.Cosine, vectormath.Euclidean, vectormath.Manhattan, vectormath.DotProduct} {
+		t.Run(string(metric), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			vectors := clusteredVectors(rng, 2000, 32)
+
+			idx := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 32, Metric: metric, NList: 16, NProbe: 4, TrainSize: 1000}, nil)
+			for id, v := range vectors {
+				if err := idx.Add(id, v); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+			if idx.centroids == nil || idx.Size() != len(vectors) {
+				t.Fatalf("Expected a trained index with %d vectors, got trained=%v size=%d",
+					len(vectors), idx.centroids != nil, idx.Size())
+			}
+
+			// Scanning every list is exact whatever the metric
+			for q := 0; q < 20; q++ {
+				query := vectors[fmt.Sprintf("v%d", rng.Intn(len(vectors)))]
+				want := exactNearest(vectors, query, 10, metric)
+				results, err := idx.SearchWithOptions(query, 10, SearchOptions{NProbe: 16})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				for i, r := range results {
+					// Compare distances rather than IDs, which may tie
+					if mustDistance(metric, query, vectors[r.ID]) != mustDistance(metric, query, vectors[want[i]]) {
+						t.Fatalf("Expected %s at rank %d, got %s", want[i], i, r.ID)
+					}
+				}
+			}
+
+			if metric == vectormath.DotProduct {
+				// The largest inner product is rarely in the query's own cluster
+				return
+			}
+			hits := 0
+			for q := 0; q < 50; q++ {
+				query := vectors[fmt.Sprintf("v%d", rng.Intn(len(vectors)))]
+				want := exactNearest(vectors, query, 1, metric)[0]
+				results, _ := idx.SearchWithOptions(query, 1, SearchOptions{})
+				if len(results) == 1 && results[0].ID == want {
+					hits++
+				}
+			}
+			if hits < 45 {
+				t.Errorf("Expected recall@1 of at least 0.9 with nprobe 4, got %d/50", hits)
+			}
+		})
+	}
+}
+
+func mustDistance(metric vectormath.Metric, a, b []float32) float32 {
+	d, _ := metric.Distance(a, b)
+	return d
+}
+
+func TestIVFFlatIndex_Train(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := clusteredVectors(rng, 500, 16)
+
+	idx := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 16, Metric: vectormath.Euclidean, NList: 8, TrainSize: 1000}, nil)
+	for id, v := range vectors {
+		idx.Add(id, v)
+	}
+	if idx.centroids != nil || len(idx.lists) != 1 {
+		t.Fatalf("Expected an untrained index below the train size")
+	}
+	results, _ := idx.SearchWithOptions(vectors["v3"], 1, SearchOptions{})
+	if len(results) != 1 || results[0].ID != "v3" || results[0].Distance != 0 {
+		t.Errorf("Expected an exact match before training, got %v", results)
+	}
+
+	samples := make([][]float32, 0, 100)
+	for i := 0; i < 100; i++ {
+		samples = append(samples, vectors[fmt.Sprintf("v%d", i)])
+	}
+	if err := idx.Train(samples); err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	if err := idx.Train([][]float32{{1, 2}}); err == nil {
+		t.Errorf("Expected training on samples of the wrong dimension to fail")
+	}
+	if len(idx.centroids) != 8 || idx.Size() != len(vectors) {
+		t.Fatalf("Expected 8 lists holding %d vectors, got %d lists and %d vectors",
+			len(vectors), len(idx.centroids), idx.Size())
+	}
+
+	// Every vector was reassigned to its nearest centroid
+	for id, loc := range idx.ids {
+		if nearest, _ := nearestCentroid(vectors[id], idx.centroids); nearest != loc.list {
+			t.Fatalf("Expected %s in list %d, found it in %d", id, nearest, loc.list)
+		}
+	}
+
+	idx.Remove("v3")
+	results, _ = idx.SearchWithOptions(vectors["v3"], 500, SearchOptions{NProbe: 8})
+	for _, r := range results {
+		if r.ID == "v3" {
+			t.Errorf("Removed vector returned")
+		}
+	}
+}
+
+func TestIVFFlatIndex_TrainWhileWriting(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := clusteredVectors(rng, 2000, 16)
+
+	idx := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 16, Metric: vectormath.Euclidean, NList: 16, TrainSize: 5000}, nil)
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("v%d", i)
+		idx.Add(id, vectors[id])
+	}
+	samples := make([][]float32, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, vectors[fmt.Sprintf("v%d", i)])
+	}
+
+	// Writes and searches go on while the centroids are fitted; wherever
+	// they land in the fit, they must survive the swap
+	done := make(chan error)
+	go func() { done <- idx.Train(samples) }()
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("v%d", 1000+i)
+		idx.Add(id, vectors[id])
+		if i < 100 {
+			idx.Remove(fmt.Sprintf("v%d", i))
+		}
+		idx.SearchWithOptions(vectors[id], 1, SearchOptions{})
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+
+	if len(idx.centroids) != 16 || idx.Size() != 1900 {
+		t.Fatalf("Expected 16 lists holding 1900 vectors, got %d lists and %d vectors", len(idx.centroids), idx.Size())
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := idx.ids[fmt.Sprintf("v%d", i)]; ok {
+			t.Fatalf("Expected v%d removed during training to stay removed", i)
+		}
+	}
+	for id, loc := range idx.ids {
+		l := idx.lists[loc.list]
+		if l.ids[loc.pos] != id || !equalEmbeddings(l.vectors[loc.pos*16:(loc.pos+1)*16], vectors[id]) {
+			t.Fatalf("Expected %s stored at its location", id)
+		}
+		if nearest, _ := nearestCentroid(vectors[id], idx.centroids); nearest != loc.list {
+			t.Fatalf("Expected %s in list %d, found it in %d", id, nearest, loc.list)
+		}
+	}
+}
+
+func TestIVFFlatIndex_SaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := clusteredVectors(rng, 300, 16)
+
+	idx := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 16, NList: 4, TrainSize: 250}, nil)
+	for id, v := range vectors {
+		idx.Add(id, v)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 16}, nil)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if restored.Size() != idx.Size() || len(restored.centroids) != 4 {
+		t.Fatalf("Expected %d vectors in 4 lists, got size=%d lists=%d",
+			idx.Size(), restored.Size(), len(restored.centroids))
+	}
+
+	query := vectors["v7"]
+	want, _ := idx.SearchWithOptions(query, 5, SearchOptions{})
+	got, _ := restored.SearchWithOptions(query, 5, SearchOptions{})
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("Restored index returned %v, want %v", got, want)
+	}
+
+	mismatched := NewIVFFlatIndex(IVFFlatConfig{Dimensions: 16, Metric: vectormath.Euclidean}, nil)
+	idx.Save(&buf)
+	if err := mismatched.Load(&buf); err == nil {
+		t.Errorf("Expected loading into a different metric to fail")
+	}
+}
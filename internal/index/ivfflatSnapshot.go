@@ -0,0 +1,148 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+const ivfflatSnapshotVersion uint8 = 1
+
+var ivfflatSnapshotMagic = [4]byte{'I', 'V', 'F', 'F'}
+
+// ivfflatSnapshotHeader is the fixed-size preamble of a serialized IVF-Flat
+// index. It is followed by the centroids and every list with its IDs and
+// embeddings.
+type ivfflatSnapshotHeader struct {
+	Magic     [4]byte
+	Version   uint8
+	Dim       uint32
+	Metric    [16]byte
+	NList     uint32
+	NProbe    uint32
+	TrainSize uint32
+	Trained   uint8
+	Lists     uint32
+}
+
+// Save serializes the centroids and the vectors of every list.
+func (x *IVFFlatIndex) Save(w io.Writer) error {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	header := ivfflatSnapshotHeader{
+		Magic:     ivfflatSnapshotMagic,
+		Version:   ivfflatSnapshotVersion,
+		Dim:       uint32(x.dim),
+		NList:     uint32(x.nlist),
+		NProbe:    uint32(x.nprobe),
+		TrainSize: uint32(x.trainSize),
+		Lists:     uint32(len(x.lists)),
+	}
+	if x.centroids != nil {
+		header.Trained = 1
+	}
+	copy(header.Metric[:], x.metric)
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+
+	for _, c := range x.centroids {
+		if err := writeFloats(bw, c); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+	for _, l := range x.lists {
+		if err := writeUint32(bw, uint32(len(l.ids))); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+		for _, id := range l.ids {
+			if err := writeString(bw, id); err != nil {
+				return ErrSnapshotWrite(err)
+			}
+		}
+		if err := writeFloats(bw, l.vectors); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	return nil
+}
+
+// Load replaces the index with one previously written by Save. The
+// snapshot must have been taken from an index with the same dimensions and
+// metric.
+func (x *IVFFlatIndex) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	var header ivfflatSnapshotHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return ErrSnapshotRead(err)
+	}
+	if header.Magic != ivfflatSnapshotMagic {
+		return ErrSnapshotRead(fmt.Errorf("bad magic %q", header.Magic[:]))
+	}
+	if header.Version != ivfflatSnapshotVersion {
+		return ErrSnapshotRead(fmt.Errorf("unsupported version %d", header.Version))
+	}
+	if int(header.Dim) != x.dim {
+		return ErrDimensionMismatch(x.dim, int(header.Dim))
+	}
+	if metric := vectormath.Metric(bytes.TrimRight(header.Metric[:], "\x00")); metric != x.metric {
+		return ErrMetricMismatch(x.metric, metric)
+	}
+	if header.Lists == 0 || (header.Trained == 0 && header.Lists != 1) {
+		return ErrSnapshotRead(fmt.Errorf("invalid list count %d", header.Lists))
+	}
+
+	var centroids [][]float32
+	if header.Trained == 1 {
+		centroids = make([][]float32, header.Lists)
+		for i := range centroids {
+			c, err := readFloats(br, x.dim)
+			if err != nil {
+				return ErrSnapshotRead(err)
+			}
+			centroids[i] = c
+		}
+	}
+
+	lists := make([]flatList, header.Lists)
+	ids := make(map[string]ivfLocation)
+	for i := range lists {
+		count, err := readUint32(br)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		l := flatList{ids: make([]string, count)}
+		for pos := range l.ids {
+			if l.ids[pos], err = readString(br); err != nil {
+				return ErrSnapshotRead(err)
+			}
+			ids[l.ids[pos]] = ivfLocation{list: i, pos: pos}
+		}
+		if l.vectors, err = readFloats(br, int(count)*x.dim); err != nil {
+			return ErrSnapshotRead(err)
+		}
+		lists[i] = l
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.nlist = int(header.NList)
+	x.nprobe = int(header.NProbe)
+	x.trainSize = int(header.TrainSize)
+	x.centroids = centroids
+	x.lists = lists
+	x.ids = ids
+	return nil
+}
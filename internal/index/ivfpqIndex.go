@@ -0,0 +1,539 @@
+package index
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+const (
+	defaultNList        = 256 // Coarse clusters
+	defaultNProbe       = 8   // Clusters scanned per query
+	defaultSubvectorDim = 8   // Dimensions per PQ subvector
+	pqCodebookSize      = 256 // Codewords per subvector, so each code is one byte
+	trainSamplesPerList = 64  // Vectors buffered per coarse cluster before training
+	kmeansIterations    = 20
+)
+
+// IVFPQConfig holds the parameters of an IVF-PQ index; zero values select
+// the defaults.
+type IVFPQConfig struct {
+	Dimensions int
+	Metric     vectormath.Metric // Cosine when empty
+	NList      int               // Coarse clusters
+	NProbe     int               // Clusters scanned per query
+	Subvectors int               // PQ subquantizers, must divide Dimensions
+	TrainSize  int               // Vectors buffered before training
+}
+
+// ivfList is one coarse cluster: the IDs assigned to it and their PQ codes,
+// Subvectors bytes per vector in the same order.
+type ivfList struct {
+	ids   []string
+	codes []byte
+}
+
+type ivfLocation struct {
+	list int
+	pos  int
+}
+
+// IVFPQIndex is an inverted file index with product quantization. Vectors
+// are assigned to their nearest coarse centroid and only the residual's PQ
+// code, one byte per subvector, is kept, so memory per vector is a small
+// fraction of the float32 embedding. Queries scan the nprobe closest lists
+// using asymmetric distance computation: the query stays exact and is
+// compared with the quantized vectors through per-subvector lookup tables.
+//
+// The quantizers are trained on the first TrainSize vectors. Until then
+// those vectors are kept as they are and searched exactly.
+type IVFPQIndex struct {
+	mu         sync.RWMutex
+	dim        int
+	logger     logger.Logger
+	metric     vectormath.Metric
+	nlist      int
+	nprobe     int
+	subvectors int
+	subDim     int
+	trainSize  int
+	rng        *rand.Rand
+
+	trained   bool
+	centroids [][]float32
+	codebooks [][]float32 // codebooks[j] holds ksub codewords of subDim floats
+	ksub      int
+	lists     []ivfList
+	ids       map[string]ivfLocation
+
+	buffer map[string][]float32 // Vectors added before training
+}
+
+func NewIVFPQIndex(cfg IVFPQConfig, log logger.Logger) *IVFPQIndex {
+	if cfg.NList <= 0 {
+		cfg.NList = defaultNList
+	}
+	if cfg.NProbe <= 0 {
+		cfg.NProbe = defaultNProbe
+	}
+	if cfg.Subvectors <= 0 || cfg.Dimensions%cfg.Subvectors != 0 {
+		cfg.Subvectors = defaultSubvectors(cfg.Dimensions)
+	}
+	if cfg.TrainSize <= 0 {
+		cfg.TrainSize = cfg.NList * trainSamplesPerList
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = vectormath.Cosine
+	}
+
+	return &IVFPQIndex{
+		dim:        cfg.Dimensions,
+		logger:     log,
+		metric:     cfg.Metric,
+		nlist:      cfg.NList,
+		nprobe:     cfg.NProbe,
+		subvectors: cfg.Subvectors,
+		subDim:     cfg.Dimensions / cfg.Subvectors,
+		trainSize:  cfg.TrainSize,
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		ids:        make(map[string]ivfLocation),
+		buffer:     make(map[string][]float32),
+	}
+}
+
+// defaultSubvectors splits dim into subvectors of up to defaultSubvectorDim
+// dimensions.
+func defaultSubvectors(dim int) int {
+	for width := defaultSubvectorDim; width > 1; width-- {
+		if dim%width == 0 {
+			return dim / width
+		}
+	}
+	return dim
+}
+
+// Add encodes a vector into its list, replacing any vector with the same
+// ID. Before the index is trained the vector is buffered instead, and the
+// add that fills the buffer trains the index.
+func (x *IVFPQIndex) Add(id string, embedding []float32) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if len(embedding) != x.dim {
+		return ErrDimensionMismatch(x.dim, len(embedding))
+	}
+
+	x.remove(id)
+	v := x.prepare(embedding)
+	if x.trained {
+		x.encode(id, v)
+		return nil
+	}
+
+	x.buffer[id] = v
+	if len(x.buffer) >= x.trainSize {
+		x.train(x.bufferSamples())
+	}
+	return nil
+}
+
+// Train fits the quantizers to samples and encodes the buffered vectors,
+// without waiting for TrainSize of them to be added. Once trained the
+// index only keeps codes, so it can't be retrained: rebuild it instead.
+func (x *IVFPQIndex) Train(samples [][]float32) error {
+	prepared := make([][]float32, len(samples))
+	for i, s := range samples {
+		if len(s) != x.dim {
+			return ErrDimensionMismatch(x.dim, len(s))
+		}
+		prepared[i] = x.prepare(s)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.trained || len(prepared) == 0 {
+		return nil
+	}
+	x.train(prepared)
+	return nil
+}
+
+// TrainSize returns the number of samples the untrained index wants, and 0
+// once it is trained.
+func (x *IVFPQIndex) TrainSize() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if x.trained {
+		return 0
+	}
+	return x.trainSize
+}
+
+func (x *IVFPQIndex) Remove(id string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.remove(id) {
+		return ErrVectorNotInIndex(id)
+	}
+	return nil
+}
+
+// SearchWithOptions returns the k nearest vectors accepted by opts.Allow,
+// scanning opts.NProbe lists. Distances are estimated from the PQ codes;
+// re-rank the results with exact distances when precision matters.
+func (x *IVFPQIndex) SearchWithOptions(query []float32, k int, opts SearchOptions) ([]SearchResult, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if len(query) != x.dim {
+		return nil, ErrDimensionMismatch(x.dim, len(query))
+	}
+	if x.size() == 0 || k < 1 {
+		return []SearchResult{}, nil
+	}
+
+	q := x.prepare(query)
+	top := newTopK(k, opts.Allow)
+	for id, v := range x.buffer {
+		top.offer(id, x.distance(q, v))
+	}
+	if x.trained {
+		nprobe := opts.NProbe
+		if nprobe <= 0 {
+			nprobe = x.nprobe
+		}
+		x.scan(q, nprobe, top)
+	}
+
+	results := top.results(x.metric)
+	if x.logger != nil {
+		x.logger.Debug("Search completed",
+			logger.Int("results", len(results)),
+			logger.Int("requested_k", k),
+			logger.Bool("trained", x.trained))
+	}
+	return results, nil
+}
+
+// Size returns the number of vectors in the index.
+func (x *IVFPQIndex) Size() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.size()
+}
+
+func (x *IVFPQIndex) size() int {
+	return len(x.ids) + len(x.buffer)
+}
+
+// IDs returns the IDs of all vectors in the index.
+func (x *IVFPQIndex) IDs() []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	ids := make([]string, 0, x.size())
+	for id := range x.ids {
+		ids = append(ids, id)
+	}
+	for id := range x.buffer {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (x *IVFPQIndex) Stats() map[string]interface{} {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return map[string]interface{}{
+		"type":             TypeIVFPQ,
+		"vectors":          x.size(),
+		"dimensions":       x.dim,
+		"metric":           string(x.metric),
+		"nlist":            x.nlist,
+		"nprobe":           x.nprobe,
+		"subvectors":       x.subvectors,
+		"trained":          x.trained,
+		"buffered":         len(x.buffer),
+		"bytes_per_vector": x.subvectors,
+	}
+}
+
+// prepare copies an embedding, normalizing it for cosine so that cosine
+// distance can be computed from euclidean distance.
+func (x *IVFPQIndex) prepare(embedding []float32) []float32 {
+	return prepareVector(embedding, x.metric)
+}
+
+// prepareVector copies an embedding, normalizing it for cosine. Clustering
+// unit vectors by euclidean distance groups them by angle.
+func prepareVector(embedding []float32, metric vectormath.Metric) []float32 {
+	v := make([]float32, len(embedding))
+	copy(v, embedding)
+	if metric != vectormath.Cosine {
+		return v
+	}
+
+	var norm float32
+	for _, f := range v {
+		norm += f * f
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = float32(math.Sqrt(float64(norm)))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// innerProduct reports whether the metric is computed from inner products
+// rather than from the difference of the vectors.
+func (x *IVFPQIndex) innerProduct() bool {
+	return x.metric == vectormath.DotProduct
+}
+
+// distance is the exact distance between prepared vectors.
+func (x *IVFPQIndex) distance(a, b []float32) float32 {
+	dist, err := x.metric.Distance(a, b)
+	if err != nil {
+		return x.metric.MaxDistance()
+	}
+	return dist
+}
+
+// bufferSamples returns the buffered vectors in ID order, so training on
+// the same data is deterministic. The caller holds the lock.
+func (x *IVFPQIndex) bufferSamples() [][]float32 {
+	ids := x.bufferIDs()
+	samples := make([][]float32, len(ids))
+	for i, id := range ids {
+		samples[i] = x.buffer[id]
+	}
+	return samples
+}
+
+func (x *IVFPQIndex) bufferIDs() []string {
+	ids := make([]string, 0, len(x.buffer))
+	for id := range x.buffer {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// train fits the coarse quantizer and the PQ codebooks on prepared samples
+// and encodes the buffered vectors. The caller holds the write lock.
+func (x *IVFPQIndex) train(samples [][]float32) {
+	start := time.Now()
+
+	x.centroids = kmeans(samples, x.nlist, kmeansIterations, x.rng)
+
+	residuals := make([][]float32, len(samples))
+	for i, s := range samples {
+		c, _ := nearestCentroid(s, x.centroids)
+		residuals[i] = subtract(s, x.centroids[c])
+	}
+
+	x.codebooks = make([][]float32, x.subvectors)
+	subs := make([][]float32, len(residuals))
+	for j := range x.codebooks {
+		for i, r := range residuals {
+			subs[i] = r[j*x.subDim : (j+1)*x.subDim]
+		}
+		codewords := kmeans(subs, pqCodebookSize, kmeansIterations, x.rng)
+		x.ksub = len(codewords)
+		x.codebooks[j] = make([]float32, 0, len(codewords)*x.subDim)
+		for _, cw := range codewords {
+			x.codebooks[j] = append(x.codebooks[j], cw...)
+		}
+	}
+
+	x.lists = make([]ivfList, len(x.centroids))
+	x.trained = true
+	for _, id := range x.bufferIDs() {
+		x.encode(id, x.buffer[id])
+	}
+	x.buffer = make(map[string][]float32)
+
+	if x.logger != nil {
+		x.logger.Info("IVF-PQ index trained",
+			logger.Int("samples", len(samples)),
+			logger.Int("lists", len(x.centroids)),
+			logger.Int("subvectors", x.subvectors),
+			logger.Int("codewords", x.ksub),
+			logger.Duration("duration", time.Since(start)))
+	}
+}
+
+// encode quantizes a prepared vector into its nearest list. The caller
+// holds the write lock.
+func (x *IVFPQIndex) encode(id string, v []float32) {
+	list, _ := nearestCentroid(v, x.centroids)
+	residual := subtract(v, x.centroids[list])
+
+	l := &x.lists[list]
+	x.ids[id] = ivfLocation{list: list, pos: len(l.ids)}
+	l.ids = append(l.ids, id)
+	for j := 0; j < x.subvectors; j++ {
+		l.codes = append(l.codes, x.quantize(j, residual[j*x.subDim:(j+1)*x.subDim]))
+	}
+}
+
+// quantize returns the code of the codeword of subvector j nearest to sub.
+func (x *IVFPQIndex) quantize(j int, sub []float32) byte {
+	best, bestDist := 0, float32(math.MaxFloat32)
+	for c := 0; c < x.ksub; c++ {
+		if d := squaredL2(sub, x.codebooks[j][c*x.subDim:(c+1)*x.subDim]); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return byte(best)
+}
+
+// remove drops a vector from the buffer or its list, moving the list's last
+// vector into its place. The caller holds the write lock.
+func (x *IVFPQIndex) remove(id string) bool {
+	if _, ok := x.buffer[id]; ok {
+		delete(x.buffer, id)
+		return true
+	}
+
+	loc, ok := x.ids[id]
+	if !ok {
+		return false
+	}
+	l := &x.lists[loc.list]
+	m := x.subvectors
+	last := len(l.ids) - 1
+	if loc.pos != last {
+		moved := l.ids[last]
+		l.ids[loc.pos] = moved
+		copy(l.codes[loc.pos*m:(loc.pos+1)*m], l.codes[last*m:])
+		x.ids[moved] = loc
+	}
+	l.ids = l.ids[:last]
+	l.codes = l.codes[:last*m]
+	delete(x.ids, id)
+	return true
+}
+
+// scan offers every vector in the nprobe lists nearest to q to top, with
+// distances estimated from lookup tables.
+func (x *IVFPQIndex) scan(q []float32, nprobe int, top *topK) {
+	table := make([]float32, x.subvectors*x.ksub)
+	if x.innerProduct() {
+		// Inner products with the codewords don't depend on the list
+		x.fillTable(q, table)
+	}
+
+	m := x.subvectors
+	for _, list := range nearestLists(q, x.centroids, nprobe, x.metric) {
+		l := &x.lists[list]
+		if len(l.ids) == 0 {
+			continue
+		}
+
+		var base float32
+		if x.innerProduct() {
+			base = dot(q, x.centroids[list])
+		} else {
+			x.fillTable(subtract(q, x.centroids[list]), table)
+		}
+
+		for pos, id := range l.ids {
+			var sum float32
+			for j, code := range l.codes[pos*m : (pos+1)*m] {
+				sum += table[j*x.ksub+int(code)]
+			}
+			top.offer(id, x.estimate(base, sum))
+		}
+	}
+}
+
+// fillTable computes, for every subvector and codeword, the codeword's
+// contribution to the distance: its inner product with the query for dot,
+// its absolute difference from the query residual for manhattan, and its
+// squared difference otherwise.
+func (x *IVFPQIndex) fillTable(q []float32, table []float32) {
+	for j := 0; j < x.subvectors; j++ {
+		sub := q[j*x.subDim : (j+1)*x.subDim]
+		for c := 0; c < x.ksub; c++ {
+			cw := x.codebooks[j][c*x.subDim : (c+1)*x.subDim]
+			var v float32
+			switch x.metric {
+			case vectormath.DotProduct:
+				v = dot(sub, cw)
+			case vectormath.Manhattan:
+				for i := range sub {
+					v += float32(math.Abs(float64(sub[i] - cw[i])))
+				}
+			default:
+				v = squaredL2(sub, cw)
+			}
+			table[j*x.ksub+c] = v
+		}
+	}
+}
+
+// estimate turns the summed table entries of a vector into a distance.
+// base is the query's inner product with the list centroid.
+func (x *IVFPQIndex) estimate(base, sum float32) float32 {
+	switch x.metric {
+	case vectormath.Cosine:
+		// Between unit vectors, 1 - cos(a, b) = |a - b|² / 2
+		return sum / 2
+	case vectormath.DotProduct:
+		return -(base + sum)
+	case vectormath.Manhattan:
+		return sum
+	default:
+		return float32(math.Sqrt(float64(sum)))
+	}
+}
+
+// nearestLists returns the nprobe lists whose centroids are closest to q:
+// those with the largest inner product for dot, the nearest otherwise.
+func nearestLists(q []float32, centroids [][]float32, nprobe int, metric vectormath.Metric) []int {
+	if nprobe > len(centroids) {
+		nprobe = len(centroids)
+	}
+
+	dists := make([]float32, len(centroids))
+	lists := make([]int, len(centroids))
+	for i, c := range centroids {
+		lists[i] = i
+		if metric == vectormath.DotProduct {
+			dists[i] = -dot(q, c)
+		} else {
+			dists[i] = squaredL2(q, c)
+		}
+	}
+	sort.Slice(lists, func(a, b int) bool { return dists[lists[a]] < dists[lists[b]] })
+	return lists[:nprobe]
+}
+
+func subtract(a, b []float32) []float32 {
+	out := make([]float32, len(a))
+	for i := range a {
+		out[i] = a[i] - b[i]
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,199 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// clusteredVectors returns n vectors scattered around a few random centers,
+// which is the kind of data IVF indexes are built for.
+func clusteredVectors(rng *rand.Rand, n, dim int) map[string][]float32 {
+	centers := make([][]float32, 10)
+	for i := range centers {
+		centers[i] = make([]float32, dim)
+		for j := range centers[i] {
+			centers[i][j] = rng.Float32()*2 - 1
+		}
+	}
+
+	vectors := make(map[string][]float32, n)
+	for i := 0; i < n; i++ {
+		center := centers[rng.Intn(len(centers))]
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = center[j] + float32(rng.NormFloat64()*0.1)
+		}
+		vectors[fmt.Sprintf("v%d", i)] = v
+	}
+	return vectors
+}
+
+// exactNearest returns the IDs of the k vectors closest to query.
+func exactNearest(vectors map[string][]float32, query []float32, k int, metric vectormath.Metric) []string {
+	ids := make([]string, 0, len(vectors))
+	dists := make(map[string]float32, len(vectors))
+	for id, v := range vectors {
+		dists[id], _ = metric.Distance(query, v)
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return dists[ids[i]] < dists[ids[j]] })
+	return ids[:k]
+}
+
+func TestIVFPQIndex_Recall(t *testing.T) {
+	tests := []struct {
+		metric vectormath.Metric
+		nprobe int
+		k      int
+	}{
+		{vectormath.Cosine, 4, 10},
+		{vectormath.Euclidean, 4, 10},
+		{vectormath.Manhattan, 4, 10},
+		// The largest inner product is often outside the query's own
+		// cluster and many vectors come close to it, so scan every list
+		// and take enough candidates to re-rank
+		{vectormath.DotProduct, 16, 50},
+	}
+
+	for _, tt := range tests {
+		metric := tt.metric
+		t.Run(string(metric), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			vectors := clusteredVectors(rng, 2000, 32)
+
+			idx := NewIVFPQIndex(IVFPQConfig{Dimensions: 32, Metric: metric, NList: 16, NProbe: tt.nprobe, TrainSize: 1000}, nil)
+			for id, v := range vectors {
+				if err := idx.Add(id, v); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+			if !idx.trained || idx.Size() != len(vectors) || len(idx.buffer) != 0 {
+				t.Fatalf("Expected a trained index with %d vectors, got trained=%v size=%d buffered=%d",
+					len(vectors), idx.trained, idx.Size(), len(idx.buffer))
+			}
+
+			// The exact nearest neighbour should almost always be among the
+			// top k estimated ones
+			hits := 0
+			for q := 0; q < 50; q++ {
+				query := vectors[fmt.Sprintf("v%d", rng.Intn(len(vectors)))]
+				want := exactNearest(vectors, query, 1, metric)[0]
+				results, err := idx.SearchWithOptions(query, tt.k, SearchOptions{})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				for _, r := range results {
+					if r.ID == want {
+						hits++
+						break
+					}
+				}
+			}
+			if hits < 45 {
+				t.Errorf("Expected recall@%d of at least 0.9, got %d/50", tt.k, hits)
+			}
+		})
+	}
+}
+
+func TestIVFPQIndex_UntrainedIsExact(t *testing.T) {
+	idx := NewIVFPQIndex(IVFPQConfig{Dimensions: 3, TrainSize: 100}, nil)
+	idx.Add("v1", []float32{1, 0, 0})
+	idx.Add("v2", []float32{0, 1, 0})
+	idx.Add("v3", []float32{0, 0, 1})
+
+	results, err := idx.SearchWithOptions([]float32{0.9, 0.1, 0}, 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "v1" || results[1].ID != "v2" {
+		t.Errorf("Expected v1, v2, got %v", results)
+	}
+}
+
+func TestIVFPQIndex_RemoveAndReplace(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := clusteredVectors(rng, 500, 16)
+
+	idx := NewIVFPQIndex(IVFPQConfig{Dimensions: 16, Metric: vectormath.Euclidean, NList: 8, TrainSize: 200}, nil)
+	for id, v := range vectors {
+		idx.Add(id, v)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := idx.Remove(fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+	if err := idx.Remove("v0"); err == nil {
+		t.Errorf("Expected removing a missing vector to fail")
+	}
+	if idx.Size() != 400 {
+		t.Fatalf("Expected 400 vectors, got %d", idx.Size())
+	}
+
+	// Move v100 far away from everything else
+	far := make([]float32, 16)
+	for i := range far {
+		far[i] = 100
+	}
+	idx.Add("v100", far)
+	if idx.Size() != 400 {
+		t.Fatalf("Expected replacing to keep 400 vectors, got %d", idx.Size())
+	}
+
+	results, _ := idx.SearchWithOptions(far, 1, SearchOptions{NProbe: 8})
+	if len(results) != 1 || results[0].ID != "v100" {
+		t.Errorf("Expected v100 at its new position, got %v", results)
+	}
+	results, _ = idx.SearchWithOptions(vectors["v150"], 400, SearchOptions{NProbe: 8})
+	for _, r := range results {
+		var n int
+		fmt.Sscanf(r.ID, "v%d", &n)
+		if n < 100 {
+			t.Errorf("Removed vector %s returned", r.ID)
+		}
+	}
+}
+
+func TestIVFPQIndex_SaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := clusteredVectors(rng, 300, 16)
+
+	idx := NewIVFPQIndex(IVFPQConfig{Dimensions: 16, NList: 4, TrainSize: 250}, nil)
+	for id, v := range vectors {
+		idx.Add(id, v)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewIVFPQIndex(IVFPQConfig{Dimensions: 16}, nil)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if restored.Size() != idx.Size() || !restored.trained || restored.nlist != 4 {
+		t.Fatalf("Expected %d trained vectors in 4 lists, got size=%d trained=%v nlist=%d",
+			idx.Size(), restored.Size(), restored.trained, restored.nlist)
+	}
+
+	query := vectors["v7"]
+	want, _ := idx.SearchWithOptions(query, 5, SearchOptions{})
+	got, _ := restored.SearchWithOptions(query, 5, SearchOptions{})
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("Restored index returned %v, want %v", got, want)
+	}
+
+	mismatched := NewIVFPQIndex(IVFPQConfig{Dimensions: 16, Metric: vectormath.Euclidean}, nil)
+	idx.Save(&buf)
+	if err := mismatched.Load(&buf); err == nil {
+		t.Errorf("Expected loading into a different metric to fail")
+	}
+}
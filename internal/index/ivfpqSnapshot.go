@@ -0,0 +1,249 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+const ivfpqSnapshotVersion uint8 = 1
+
+var ivfpqSnapshotMagic = [4]byte{'I', 'V', 'P', 'Q'}
+
+// ivfpqSnapshotHeader is the fixed-size preamble of a serialized IVF-PQ
+// index. It is followed by the centroids, the codebooks, every list with
+// its IDs and codes, and finally the vectors still waiting for training.
+type ivfpqSnapshotHeader struct {
+	Magic      [4]byte
+	Version    uint8
+	Dim        uint32
+	Metric     [16]byte
+	NList      uint32
+	NProbe     uint32
+	Subvectors uint32
+	TrainSize  uint32
+	Trained    uint8
+	Lists      uint32
+	KSub       uint32
+	Buffered   uint32
+}
+
+// Save serializes the trained quantizers, the encoded vectors and the
+// training buffer.
+func (x *IVFPQIndex) Save(w io.Writer) error {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	header := ivfpqSnapshotHeader{
+		Magic:      ivfpqSnapshotMagic,
+		Version:    ivfpqSnapshotVersion,
+		Dim:        uint32(x.dim),
+		NList:      uint32(x.nlist),
+		NProbe:     uint32(x.nprobe),
+		Subvectors: uint32(x.subvectors),
+		TrainSize:  uint32(x.trainSize),
+		Lists:      uint32(len(x.lists)),
+		KSub:       uint32(x.ksub),
+		Buffered:   uint32(len(x.buffer)),
+	}
+	if x.trained {
+		header.Trained = 1
+	}
+	copy(header.Metric[:], x.metric)
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+
+	if err := x.writeQuantizers(bw); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	for _, l := range x.lists {
+		if err := writeUint32(bw, uint32(len(l.ids))); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+		for _, id := range l.ids {
+			if err := writeString(bw, id); err != nil {
+				return ErrSnapshotWrite(err)
+			}
+		}
+		if _, err := bw.Write(l.codes); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+	for id, v := range x.buffer {
+		if err := writeString(bw, id); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+		if err := writeFloats(bw, v); err != nil {
+			return ErrSnapshotWrite(err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return ErrSnapshotWrite(err)
+	}
+	return nil
+}
+
+func (x *IVFPQIndex) writeQuantizers(w io.Writer) error {
+	for _, c := range x.centroids {
+		if err := writeFloats(w, c); err != nil {
+			return err
+		}
+	}
+	for _, cb := range x.codebooks {
+		if err := writeFloats(w, cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load replaces the index with one previously written by Save. The
+// snapshot must have been taken from an index with the same dimensions and
+// metric.
+func (x *IVFPQIndex) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	var header ivfpqSnapshotHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return ErrSnapshotRead(err)
+	}
+	if header.Magic != ivfpqSnapshotMagic {
+		return ErrSnapshotRead(fmt.Errorf("bad magic %q", header.Magic[:]))
+	}
+	if header.Version != ivfpqSnapshotVersion {
+		return ErrSnapshotRead(fmt.Errorf("unsupported version %d", header.Version))
+	}
+	if int(header.Dim) != x.dim {
+		return ErrDimensionMismatch(x.dim, int(header.Dim))
+	}
+	if metric := vectormath.Metric(bytes.TrimRight(header.Metric[:], "\x00")); metric != x.metric {
+		return ErrMetricMismatch(x.metric, metric)
+	}
+	m := int(header.Subvectors)
+	if m == 0 || x.dim%m != 0 || header.KSub > pqCodebookSize {
+		return ErrSnapshotRead(fmt.Errorf("invalid quantizer shape"))
+	}
+	subDim := x.dim / m
+
+	centroids := make([][]float32, header.Lists)
+	for i := range centroids {
+		c, err := readFloats(br, x.dim)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		centroids[i] = c
+	}
+	var codebooks [][]float32
+	if header.Trained == 1 {
+		codebooks = make([][]float32, m)
+		for j := range codebooks {
+			cb, err := readFloats(br, int(header.KSub)*subDim)
+			if err != nil {
+				return ErrSnapshotRead(err)
+			}
+			codebooks[j] = cb
+		}
+	}
+
+	lists := make([]ivfList, header.Lists)
+	ids := make(map[string]ivfLocation)
+	for i := range lists {
+		count, err := readUint32(br)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		l := ivfList{ids: make([]string, count), codes: make([]byte, int(count)*m)}
+		for pos := range l.ids {
+			if l.ids[pos], err = readString(br); err != nil {
+				return ErrSnapshotRead(err)
+			}
+			ids[l.ids[pos]] = ivfLocation{list: i, pos: pos}
+		}
+		if _, err := io.ReadFull(br, l.codes); err != nil {
+			return ErrSnapshotRead(err)
+		}
+		for _, code := range l.codes {
+			if uint32(code) >= header.KSub {
+				return ErrSnapshotRead(fmt.Errorf("code %d out of range", code))
+			}
+		}
+		lists[i] = l
+	}
+
+	buffer := make(map[string][]float32, header.Buffered)
+	for i := uint32(0); i < header.Buffered; i++ {
+		id, err := readString(br)
+		if err != nil {
+			return ErrSnapshotRead(err)
+		}
+		if buffer[id], err = readFloats(br, x.dim); err != nil {
+			return ErrSnapshotRead(err)
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.nlist = int(header.NList)
+	x.nprobe = int(header.NProbe)
+	x.subvectors = m
+	x.subDim = subDim
+	x.trainSize = int(header.TrainSize)
+	x.trained = header.Trained == 1
+	x.centroids = centroids
+	x.codebooks = codebooks
+	x.ksub = int(header.KSub)
+	x.lists = lists
+	x.ids = ids
+	x.buffer = buffer
+	return nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := writeUint32(w, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func writeFloats(w io.Writer, v []float32) error {
+	for _, f := range v {
+		if err := writeUint32(w, math.Float32bits(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFloats(r io.Reader, n int) ([]float32, error) {
+	v := make([]float32, n)
+	for i := range v {
+		bits, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = math.Float32frombits(bits)
+	}
+	return v, nil
+}
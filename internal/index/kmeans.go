@@ -0,0 +1,136 @@
+package index
+
+import (
+	"math"
+	"math/rand"
+)
+
+// kmeans clusters samples into k centroids under squared euclidean
+// distance. Centroids are seeded with k-means++ and refined for at most
+// maxIter rounds, stopping early once no sample changes cluster. With fewer
+// samples than k, every sample becomes a centroid.
+func kmeans(samples [][]float32, k, maxIter int, rng *rand.Rand) [][]float32 {
+	if len(samples) == 0 || k < 1 {
+		return nil
+	}
+	if k >= len(samples) {
+		centroids := make([][]float32, len(samples))
+		for i, s := range samples {
+			centroids[i] = append([]float32(nil), s...)
+		}
+		return centroids
+	}
+
+	centroids := seedCentroids(samples, k, rng)
+	dim := len(samples[0])
+	assignment := make([]int, len(samples))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+
+	sums := make([][]float64, k)
+	for i := range sums {
+		sums[i] = make([]float64, dim)
+	}
+	counts := make([]int, k)
+
+	for iter := 0; iter < maxIter; iter++ {
+		changed := 0
+		for i, s := range samples {
+			c, _ := nearestCentroid(s, centroids)
+			if c != assignment[i] {
+				assignment[i] = c
+				changed++
+			}
+		}
+		if changed == 0 {
+			break
+		}
+
+		for c := range sums {
+			for j := range sums[c] {
+				sums[c][j] = 0
+			}
+			counts[c] = 0
+		}
+		for i, s := range samples {
+			c := assignment[i]
+			counts[c]++
+			for j, v := range s {
+				sums[c][j] += float64(v)
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				// Restart an empty cluster from a random sample
+				copy(centroids[c], samples[rng.Intn(len(samples))])
+				continue
+			}
+			for j := range centroids[c] {
+				centroids[c][j] = float32(sums[c][j] / float64(counts[c]))
+			}
+		}
+	}
+	return centroids
+}
+
+// seedCentroids picks k samples, each with probability proportional to its
+// squared distance from the centroids picked so far (k-means++).
+func seedCentroids(samples [][]float32, k int, rng *rand.Rand) [][]float32 {
+	centroids := make([][]float32, 0, k)
+	centroids = append(centroids, append([]float32(nil), samples[rng.Intn(len(samples))]...))
+
+	nearest := make([]float32, len(samples))
+	for i, s := range samples {
+		nearest[i] = squaredL2(s, centroids[0])
+	}
+
+	for len(centroids) < k {
+		var total float64
+		for _, d := range nearest {
+			total += float64(d)
+		}
+
+		pick := rng.Intn(len(samples))
+		if total > 0 {
+			target := rng.Float64() * total
+			for i, d := range nearest {
+				target -= float64(d)
+				if target <= 0 {
+					pick = i
+					break
+				}
+			}
+		}
+
+		centroid := append([]float32(nil), samples[pick]...)
+		centroids = append(centroids, centroid)
+		for i, s := range samples {
+			if d := squaredL2(s, centroid); d < nearest[i] {
+				nearest[i] = d
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid closest to v and its
+// squared distance.
+func nearestCentroid(v []float32, centroids [][]float32) (int, float32) {
+	best, bestDist := 0, float32(math.MaxFloat32)
+	for i, c := range centroids {
+		if d := squaredL2(v, c); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best, bestDist
+}
+
+func squaredL2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
@@ -0,0 +1,215 @@
+package index
+
+import (
+	"io"
+	"math"
+	"strings"
+)
+
+// Quantization selects how an HNSW graph keeps embeddings in memory.
+type Quantization string
+
+const (
+	QuantizationNone   Quantization = "none"   // Full float32 copies
+	QuantizationInt8   Quantization = "int8"   // One byte per dimension, 4x smaller
+	QuantizationBinary Quantization = "binary" // One bit per dimension, 32x smaller
+)
+
+// defaultCalibrationSize is the number of vectors a quantized graph keeps
+// at full precision before it fits its quantizer to them.
+const defaultCalibrationSize = 1000
+
+// ParseQuantization resolves a configured quantization; empty means none.
+func ParseQuantization(s string) (Quantization, error) {
+	switch q := Quantization(strings.ToLower(s)); q {
+	case "", QuantizationNone:
+		return QuantizationNone, nil
+	case QuantizationInt8, QuantizationBinary:
+		return q, nil
+	default:
+		return "", ErrUnsupportedQuantization(s)
+	}
+}
+
+// quantizer compresses embeddings into fixed-size codes. Decoding gives an
+// approximation good enough to walk the graph; exact distances have to be
+// recomputed from the original vectors.
+type quantizer interface {
+	encode(v []float32) []byte
+	decode(code []byte, dst []float32)
+	codeSize() int
+	save(w io.Writer) error
+}
+
+// trainQuantizer fits a quantizer of the given kind to samples.
+func trainQuantizer(kind Quantization, samples [][]float32, dim int) quantizer {
+	switch kind {
+	case QuantizationInt8:
+		return trainInt8Quantizer(samples, dim)
+	case QuantizationBinary:
+		return trainBinaryQuantizer(samples, dim)
+	default:
+		return nil
+	}
+}
+
+// loadQuantizer reads a quantizer written by its save method.
+func loadQuantizer(kind Quantization, r io.Reader, dim int) (quantizer, error) {
+	switch kind {
+	case QuantizationInt8:
+		low, err := readFloats(r, dim)
+		if err != nil {
+			return nil, err
+		}
+		step, err := readFloats(r, dim)
+		if err != nil {
+			return nil, err
+		}
+		return &int8Quantizer{low: low, step: step}, nil
+	case QuantizationBinary:
+		q := &binaryQuantizer{}
+		for _, dst := range []*[]float32{&q.threshold, &q.low, &q.high} {
+			v, err := readFloats(r, dim)
+			if err != nil {
+				return nil, err
+			}
+			*dst = v
+		}
+		return q, nil
+	default:
+		return nil, ErrUnsupportedQuantization(string(kind))
+	}
+}
+
+// int8Quantizer maps each dimension's observed range onto 256 evenly
+// spaced levels. Values outside the calibrated range are clamped.
+type int8Quantizer struct {
+	low  []float32
+	step []float32
+}
+
+func trainInt8Quantizer(samples [][]float32, dim int) *int8Quantizer {
+	q := &int8Quantizer{low: make([]float32, dim), step: make([]float32, dim)}
+	for d := 0; d < dim; d++ {
+		lo, hi := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+		for _, s := range samples {
+			lo = min(lo, s[d])
+			hi = max(hi, s[d])
+		}
+		if len(samples) == 0 {
+			lo, hi = 0, 0
+		}
+		q.low[d] = lo
+		q.step[d] = (hi - lo) / 255
+	}
+	return q
+}
+
+func (q *int8Quantizer) encode(v []float32) []byte {
+	code := make([]byte, len(v))
+	for d, x := range v {
+		if q.step[d] == 0 {
+			continue
+		}
+		level := math.Round(float64((x - q.low[d]) / q.step[d]))
+		code[d] = byte(max(0, min(255, level)))
+	}
+	return code
+}
+
+func (q *int8Quantizer) decode(code []byte, dst []float32) {
+	for d, c := range code {
+		dst[d] = q.low[d] + float32(c)*q.step[d]
+	}
+}
+
+func (q *int8Quantizer) codeSize() int {
+	return len(q.low)
+}
+
+func (q *int8Quantizer) save(w io.Writer) error {
+	if err := writeFloats(w, q.low); err != nil {
+		return err
+	}
+	return writeFloats(w, q.step)
+}
+
+// binaryQuantizer keeps one bit per dimension: whether the value is above
+// that dimension's mean. Each bit decodes to the mean of the calibration
+// values on its side of the threshold, which preserves far more of the
+// geometry than decoding to ±1.
+type binaryQuantizer struct {
+	threshold []float32
+	low       []float32
+	high      []float32
+}
+
+func trainBinaryQuantizer(samples [][]float32, dim int) *binaryQuantizer {
+	q := &binaryQuantizer{
+		threshold: make([]float32, dim),
+		low:       make([]float32, dim),
+		high:      make([]float32, dim),
+	}
+	for d := 0; d < dim; d++ {
+		var sum float64
+		for _, s := range samples {
+			sum += float64(s[d])
+		}
+		if len(samples) > 0 {
+			q.threshold[d] = float32(sum / float64(len(samples)))
+		}
+
+		var lowSum, highSum float64
+		var lowCount, highCount int
+		for _, s := range samples {
+			if s[d] > q.threshold[d] {
+				highSum += float64(s[d])
+				highCount++
+			} else {
+				lowSum += float64(s[d])
+				lowCount++
+			}
+		}
+		q.low[d], q.high[d] = q.threshold[d], q.threshold[d]
+		if lowCount > 0 {
+			q.low[d] = float32(lowSum / float64(lowCount))
+		}
+		if highCount > 0 {
+			q.high[d] = float32(highSum / float64(highCount))
+		}
+	}
+	return q
+}
+
+func (q *binaryQuantizer) encode(v []float32) []byte {
+	code := make([]byte, q.codeSize())
+	for d, x := range v {
+		if x > q.threshold[d] {
+			code[d/8] |= 1 << (d % 8)
+		}
+	}
+	return code
+}
+
+func (q *binaryQuantizer) decode(code []byte, dst []float32) {
+	for d := range dst {
+		if code[d/8]&(1<<(d%8)) != 0 {
+			dst[d] = q.high[d]
+		} else {
+			dst[d] = q.low[d]
+		}
+	}
+}
+
+func (q *binaryQuantizer) codeSize() int {
+	return (len(q.threshold) + 7) / 8
+}
+
+func (q *binaryQuantizer) save(w io.Writer) error {
+	for _, v := range [][]float32{q.threshold, q.low, q.high} {
+		if err := writeFloats(w, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
+)
+
+// Index types registered by this package.
+const (
+	TypeHNSW    = "hnsw"
+	TypeFlat    = "flat"
+	TypeIVFFlat = "ivfflat"
+	TypeIVFPQ   = "ivfpq"
+
+	DefaultType = TypeHNSW
+)
+
+// ErrUnknownType is returned by New for a type nobody registered.
+var ErrUnknownType = errors.New("unknown index type")
+
+// Config describes an index independently of its type. Each type reads the
+// fields it understands; zero values select its defaults.
+type Config struct {
+	Dimensions     int
+	Metric         vectormath.Metric
+	M              int
+	EfConstruction int
+	EfSearch       int
+	NList          int
+	NProbe         int
+	Subvectors     int
+	TrainSize      int
+	Quantization   Quantization
+}
+
+// Factory builds an empty index, rejecting parameters its type can't use.
+type Factory func(cfg Config, log logger.Logger) (VectorIndex, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an index type available to New. Registering a name twice
+// panics, as it is a programming error.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("index type %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// New builds an empty index of the named type.
+func New(name string, cfg Config, log logger.Logger) (VectorIndex, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, name)
+	}
+	return factory(cfg, log)
+}
+
+// Types returns the registered index types, sorted.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for name := range registry {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func init() {
+	Register(TypeHNSW, func(cfg Config, log logger.Logger) (VectorIndex, error) {
+		return NewHNSWIndexWithConfig(HNSWConfig{
+			Dimensions:      cfg.Dimensions,
+			Metric:          cfg.Metric,
+			M:               cfg.M,
+			EfConstruction:  cfg.EfConstruction,
+			EfSearch:        cfg.EfSearch,
+			Quantization:    cfg.Quantization,
+			CalibrationSize: cfg.TrainSize,
+		}, log), nil
+	})
+
+	Register(TypeFlat, func(cfg Config, log logger.Logger) (VectorIndex, error) {
+		if err := unquantized(TypeFlat, cfg); err != nil {
+			return nil, err
+		}
+		return NewFlatIndex(FlatConfig{Dimensions: cfg.Dimensions, Metric: cfg.Metric}, log), nil
+	})
+
+	Register(TypeIVFFlat, func(cfg Config, log logger.Logger) (VectorIndex, error) {
+		if err := unquantized(TypeIVFFlat, cfg); err != nil {
+			return nil, err
+		}
+		return NewIVFFlatIndex(IVFFlatConfig{
+			Dimensions: cfg.Dimensions,
+			Metric:     cfg.Metric,
+			NList:      cfg.NList,
+			NProbe:     cfg.NProbe,
+			TrainSize:  cfg.TrainSize,
+		}, log), nil
+	})
+
+	Register(TypeIVFPQ, func(cfg Config, log logger.Logger) (VectorIndex, error) {
+		if err := unquantized(TypeIVFPQ, cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Subvectors > 0 && cfg.Dimensions%cfg.Subvectors != 0 {
+			return nil, fmt.Errorf("pq_subvectors %d does not divide dimensions %d", cfg.Subvectors, cfg.Dimensions)
+		}
+		return NewIVFPQIndex(IVFPQConfig{
+			Dimensions: cfg.Dimensions,
+			Metric:     cfg.Metric,
+			NList:      cfg.NList,
+			NProbe:     cfg.NProbe,
+			Subvectors: cfg.Subvectors,
+			TrainSize:  cfg.TrainSize,
+		}, log), nil
+	})
+}
+
+// unquantized rejects a quantization setting for index types that manage
+// their own memory layout.
+func unquantized(name string, cfg Config) error {
+	if cfg.Quantization != "" && cfg.Quantization != QuantizationNone {
+		return fmt.Errorf("quantization is not supported by the %s index", name)
+	}
+	return nil
+}
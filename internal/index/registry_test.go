@@ -0,0 +1,35 @@
+package index
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{TypeHNSW, TypeFlat, TypeIVFFlat, TypeIVFPQ} {
+		idx, err := New(name, Config{Dimensions: 8}, nil)
+		if err != nil {
+			t.Fatalf("New(%q) failed: %v", name, err)
+		}
+		if got := idx.Stats()["type"]; got != name {
+			t.Errorf("New(%q) built a %v index", name, got)
+		}
+	}
+
+	if _, err := New("annoy", Config{Dimensions: 8}, nil); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType, got %v", err)
+	}
+	if _, err := New(TypeIVFPQ, Config{Dimensions: 8, Quantization: QuantizationInt8}, nil); err == nil {
+		t.Errorf("Expected IVF-PQ to reject quantization")
+	}
+	if _, err := New(TypeIVFPQ, Config{Dimensions: 8, Subvectors: 3}, nil); err == nil {
+		t.Errorf("Expected IVF-PQ to reject subvectors that don't divide the dimensions")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a type twice to panic")
+		}
+	}()
+	Register(TypeHNSW, nil)
+}
@@ -0,0 +1,137 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+// Stored vector records start with a format byte. Version 1 is
+//
+//	[0]        format version (1)
+//	[1:5]      embedding length n, uint32 little endian
+//	[5:5+4n]   embedding, float32 little endian
+//	[..+4]     metadata length m, uint32 little endian
+//	[..+m]     metadata as JSON, absent when m is 0
+//
+// The ID is not stored; it is the record's key. Records written before the
+// format byte existed are JSON objects, which always start with '{', so
+// both can be told apart and read.
+const (
+	recordFormatJSON byte = '{'
+	recordFormatV1   byte = 1
+)
+
+func encodeVector(vector types.Vector) ([]byte, error) {
+	var metadata []byte
+	if len(vector.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(vector.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	n := len(vector.Embedding)
+	buf := make([]byte, 1+4+4*n+4+len(metadata))
+	buf[0] = recordFormatV1
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(n))
+	off := 5
+	for _, v := range vector.Embedding {
+		binary.LittleEndian.PutUint32(buf[off:], math.Float32bits(v))
+		off += 4
+	}
+	binary.LittleEndian.PutUint32(buf[off:], uint32(len(metadata)))
+	copy(buf[off+4:], metadata)
+	return buf, nil
+}
+
+// decodeVector reads a record in any supported format. val is only valid
+// inside the Badger transaction, so nothing returned may alias it.
+func decodeVector(id string, val []byte) (types.Vector, error) {
+	if len(val) == 0 {
+		return types.Vector{}, ErrRecordFormat(id, "empty record")
+	}
+
+	switch val[0] {
+	case recordFormatJSON:
+		var vector types.Vector
+		if err := json.Unmarshal(val, &vector); err != nil {
+			return types.Vector{}, err
+		}
+		vector.ID = id
+		return vector, nil
+	case recordFormatV1:
+		return decodeVectorV1(id, val)
+	default:
+		return types.Vector{}, ErrRecordFormat(id, fmt.Sprintf("unknown format version %d", val[0]))
+	}
+}
+
+func decodeVectorV1(id string, val []byte) (types.Vector, error) {
+	if len(val) < 5 {
+		return types.Vector{}, ErrRecordFormat(id, "truncated header")
+	}
+	n := int(binary.LittleEndian.Uint32(val[1:5]))
+	off := 5
+	if len(val) < off+4*n+4 {
+		return types.Vector{}, ErrRecordFormat(id, "truncated embedding")
+	}
+
+	vector := types.Vector{ID: id, Embedding: make([]float32, n)}
+	for i := range vector.Embedding {
+		vector.Embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(val[off:]))
+		off += 4
+	}
+
+	m := int(binary.LittleEndian.Uint32(val[off:]))
+	off += 4
+	if len(val) != off+m {
+		return types.Vector{}, ErrRecordFormat(id, "metadata length mismatch")
+	}
+	if m > 0 {
+		if err := json.Unmarshal(val[off:], &vector.Metadata); err != nil {
+			return types.Vector{}, err
+		}
+	}
+	return vector, nil
+}
+
+// encodeRecord splits a vector into its embedding record and, if it has
+// any, its metadata record.
+func encodeRecord(vector types.Vector) ([]byte, []byte, error) {
+	embedding, err := encodeVector(types.Vector{Embedding: vector.Embedding})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(vector.Metadata) == 0 {
+		return embedding, nil, nil
+	}
+	metadata, err := encodeMetadata(vector.Metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return embedding, metadata, nil
+}
+
+// Metadata records are a format byte followed by the metadata as JSON.
+func encodeMetadata(metadata map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{recordFormatV1}, data...), nil
+}
+
+func decodeMetadata(id string, val []byte) (map[string]interface{}, error) {
+	if len(val) == 0 || val[0] != recordFormatV1 {
+		return nil, ErrRecordFormat(id, "unknown metadata format")
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(val[1:], &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -6,12 +6,13 @@ import (
 )
 
 var (
-	ErrBadgerOpen    = errors.New("failed to open badger db")
-	ErrBadgerPut     = errors.New("failed to put vector")
-	ErrBadgerMarshal = errors.New("failed to marshal data")
-	ErrBadgerGet     = errors.New("failed to get vector")
-	ErrBadgerDelete  = errors.New("failed to delete vector")
-	ErrBadgerClose   = errors.New("failed to close badger db")
+	ErrBadgerOpen     = errors.New("failed to open badger db")
+	ErrBadgerPut      = errors.New("failed to put vector")
+	ErrBadgerMarshal  = errors.New("failed to marshal data")
+	ErrBadgerGet      = errors.New("failed to get vector")
+	ErrBadgerDelete   = errors.New("failed to delete vector")
+	ErrBadgerClose    = errors.New("failed to close badger db")
+	ErrBadgerDropRoot = errors.New("cannot drop the root keyspace")
 )
 
 func ErrBadgerKeyNotFound(id string) error {
@@ -29,3 +30,7 @@ func ErrBadgerBatchSet(id string, err error) error {
 func ErrBadgerBatchWriteFailed(index int, err error) error {
 	return fmt.Errorf("batch write failed at index %d: %w", index, err)
 }
+
+func ErrRecordFormat(id, reason string) error {
+	return fmt.Errorf("invalid record for vector %s: %s", id, reason)
+}
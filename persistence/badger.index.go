@@ -0,0 +1,201 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// indexChunkSize bounds each value of persisted index data well below the
+// value log file size.
+const indexChunkSize = 4 << 20 // 4 mb
+
+// indexManifest is stored under i:<name> and points at the chunks of the
+// current generation, stored under i:<name>/<generation>/<seq>. It is
+// written last, so a save that fails part way leaves the previous data in
+// place.
+type indexManifest struct {
+	Generation uint64
+	Chunks     uint32
+}
+
+func (bs *BadgerStore) indexChunkPrefix(name string, generation uint64) []byte {
+	return []byte(fmt.Sprintf("%s/%016x/", bs.indexKey(name), generation))
+}
+
+func (bs *BadgerStore) indexChunkKey(name string, generation uint64, seq uint32) []byte {
+	return append(bs.indexChunkPrefix(name, generation), []byte(fmt.Sprintf("%08x", seq))...)
+}
+
+func (bs *BadgerStore) readIndexManifest(name string) (indexManifest, bool, error) {
+	var manifest indexManifest
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(bs.indexKey(name))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return binary.Read(bytes.NewReader(val), binary.LittleEndian, &manifest)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return manifest, false, nil
+	}
+	return manifest, err == nil, err
+}
+
+// SaveIndexData stores the bytes produced by write under i:<name> in this
+// keyspace, replacing what was saved there before.
+func (bs *BadgerStore) SaveIndexData(name string, write func(w io.Writer) error) error {
+	previous, hasPrevious, err := bs.readIndexManifest(name)
+	if err != nil {
+		return err
+	}
+
+	cw := &indexChunkWriter{bs: bs, name: name, generation: previous.Generation + 1}
+	if err := write(cw); err != nil {
+		bs.deleteIndexChunks(name, cw.generation)
+		return err
+	}
+	if err := cw.flush(); err != nil {
+		bs.deleteIndexChunks(name, cw.generation)
+		return err
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, indexManifest{Generation: cw.generation, Chunks: cw.seq})
+	err = bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(bs.indexKey(name), buf.Bytes())
+	})
+	if err != nil {
+		bs.deleteIndexChunks(name, cw.generation)
+		return err
+	}
+
+	if hasPrevious {
+		return bs.deleteIndexChunks(name, previous.Generation)
+	}
+	return nil
+}
+
+// LoadIndexData returns a reader over the data last saved under i:<name>,
+// or false if nothing has been saved.
+func (bs *BadgerStore) LoadIndexData(name string) (io.Reader, bool, error) {
+	manifest, found, err := bs.readIndexManifest(name)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &indexChunkReader{bs: bs, name: name, manifest: manifest}, true, nil
+}
+
+func (bs *BadgerStore) deleteIndexChunks(name string, generation uint64) error {
+	prefix := bs.indexChunkPrefix(name, generation)
+
+	var keys [][]byte
+	err := bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// indexChunkWriter buffers writes and stores them one chunk per transaction.
+type indexChunkWriter struct {
+	bs         *BadgerStore
+	name       string
+	generation uint64
+	seq        uint32
+	buf        []byte
+}
+
+func (w *indexChunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		room := indexChunkSize - len(w.buf)
+		if room > len(p) {
+			room = len(p)
+		}
+		w.buf = append(w.buf, p[:room]...)
+		p = p[room:]
+		if len(w.buf) == indexChunkSize {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *indexChunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	key := w.bs.indexChunkKey(w.name, w.generation, w.seq)
+	err := w.bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, w.buf)
+	})
+	if err != nil {
+		return err
+	}
+	w.seq++
+	w.buf = make([]byte, 0, indexChunkSize)
+	return nil
+}
+
+// indexChunkReader reads the chunks of one generation in order, loading
+// one at a time.
+type indexChunkReader struct {
+	bs       *BadgerStore
+	name     string
+	manifest indexManifest
+	seq      uint32
+	buf      []byte
+}
+
+func (r *indexChunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.seq >= r.manifest.Chunks {
+			return 0, io.EOF
+		}
+		key := r.bs.indexChunkKey(r.name, r.manifest.Generation, r.seq)
+		err := r.bs.db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			r.buf, err = item.ValueCopy(nil)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to read index chunk %d: %w", r.seq, err)
+		}
+		r.seq++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,107 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The default collection's keys sit at the root of the database. All other
+// keys live in a namespace starting with a NUL byte, which keeps them apart
+// from the default collection's v:, m: and i: namespaces.
+const (
+	reservedPrefix   = "\x00"
+	collectionPrefix = reservedPrefix + "c/"
+	catalogPrefix    = reservedPrefix + "sys/collections/"
+	appliedPrefix    = reservedPrefix + "sys/wal-applied/"
+	layoutKey        = reservedPrefix + "sys/layout"
+)
+
+// CollectionPrefix returns the key prefix holding a named collection's vectors.
+func CollectionPrefix(name string) string {
+	return collectionPrefix + name + "/"
+}
+
+func isReservedKey(key []byte) bool {
+	return strings.HasPrefix(string(key), reservedPrefix)
+}
+
+// appliedKey holds the LSN of the last WAL record applied to this view's
+// keyspace. Each keyspace tracks its own, since collections apply their
+// records independently.
+func (bs *BadgerStore) appliedKey() []byte {
+	return []byte(appliedPrefix + bs.prefix)
+}
+
+func (bs *BadgerStore) setAppliedLSN(txn *badger.Txn, lsn uint64) error {
+	if lsn == 0 {
+		return nil
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], lsn)
+	return txn.Set(bs.appliedKey(), buf[:])
+}
+
+// AppliedLSN returns the LSN of the last WAL record applied to this view's
+// keyspace, or 0 if none has been.
+func (bs *BadgerStore) AppliedLSN() (uint64, error) {
+	var lsn uint64
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(bs.appliedKey())
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid applied LSN of %d bytes", len(val))
+			}
+			lsn = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	return lsn, err
+}
+
+// SaveCatalogEntry stores the definition of a named collection.
+func (bs *BadgerStore) SaveCatalogEntry(name string, data []byte) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(catalogPrefix+name), data)
+	})
+}
+
+// DeleteCatalogEntry removes the definition of a named collection.
+func (bs *BadgerStore) DeleteCatalogEntry(name string) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(catalogPrefix + name))
+	})
+}
+
+// IterateCatalog calls fn with every stored collection definition.
+func (bs *BadgerStore) IterateCatalog(fn func(name string, data []byte) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(catalogPrefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			name := strings.TrimPrefix(string(item.Key()), catalogPrefix)
+
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(name, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
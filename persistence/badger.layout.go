@@ -0,0 +1,150 @@
+package persistence
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// layoutVersion 2 keeps each vector's embedding under v:<id> and its
+// metadata under m:<id> within the keyspace. Version 1, which has no
+// marker, stored the whole vector under the bare ID.
+const layoutVersion byte = 2
+
+// migrateLayout moves vectors stored under bare IDs to the v: and m:
+// namespaces, rewriting JSON records in the binary format on the way. It
+// runs once, when the store is opened, and records the layout version so
+// it never runs again.
+func (bs *BadgerStore) migrateLayout() error {
+	var current byte
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(layoutKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) > 0 {
+				current = val[0]
+			}
+			return nil
+		})
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if current >= layoutVersion {
+		return nil
+	}
+
+	start := time.Now()
+	migrated := 0
+
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err = bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			prefix, id, ok := legacyVectorKey(string(item.Key()))
+			if !ok {
+				continue
+			}
+			view := &BadgerStore{prefix: prefix}
+
+			var embedding, metadata []byte
+			err := item.Value(func(val []byte) error {
+				vector, err := decodeVector(id, val)
+				if err != nil {
+					return err
+				}
+				embedding, metadata, err = encodeRecord(vector)
+				return err
+			})
+			if err != nil {
+				bs.logger.Warn("Dropping unreadable record during layout migration",
+					logger.String("key", string(item.Key())),
+					logger.Error("error", err))
+				if err := wb.Delete(item.KeyCopy(nil)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// An old ID like "v:x" is also the new key of ID "x". If "x"
+			// exists too, its migration owns that key, so don't delete it.
+			if !shadowsLegacyKey(txn, prefix, id) {
+				if err := wb.Delete(item.KeyCopy(nil)); err != nil {
+					return err
+				}
+			}
+			if err := wb.Set(view.vectorKey(id), embedding); err != nil {
+				return err
+			}
+			if metadata == nil {
+				err = wb.Delete(view.metadataKey(id))
+			} else {
+				err = wb.Set(view.metadataKey(id), metadata)
+			}
+			if err != nil {
+				return err
+			}
+
+			migrated++
+			if migrated%10000 == 0 {
+				bs.logger.Info("Layout migration progress",
+					logger.Int("vectors_migrated", migrated))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := wb.Set([]byte(layoutKey), []byte{layoutVersion}); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		bs.logger.Info("Storage layout migrated",
+			logger.Int("vectors_migrated", migrated),
+			logger.Duration("duration", time.Since(start)))
+	}
+	return nil
+}
+
+// legacyVectorKey splits a version 1 key into its keyspace prefix and the
+// vector ID. Keys of the default collection are bare IDs; those of named
+// collections sit under their collection prefix.
+func legacyVectorKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, reservedPrefix) {
+		return "", key, key != ""
+	}
+	if !strings.HasPrefix(key, collectionPrefix) {
+		return "", "", false
+	}
+
+	rest := key[len(collectionPrefix):]
+	slash := strings.IndexByte(rest, '/')
+	if slash <= 0 || slash == len(rest)-1 {
+		return "", "", false
+	}
+	return CollectionPrefix(rest[:slash]), rest[slash+1:], true
+}
+
+func shadowsLegacyKey(txn *badger.Txn, prefix, id string) bool {
+	if !strings.HasPrefix(id, "v:") && !strings.HasPrefix(id, "m:") {
+		return false
+	}
+	_, err := txn.Get([]byte(prefix + id[2:]))
+	return err == nil
+}
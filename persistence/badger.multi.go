@@ -0,0 +1,197 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// Multi-vectors, bags of token embeddings under a name, are kept in one
+// record per vector written in the same transactions as the vector:
+//
+//	u:<id>  format byte, count uint32 LE, then per multi-vector its name
+//	        length uint32 LE, the name, its token count and dimension
+//	        uint32 LE and the tokens' values float32 LE
+//
+// Names are written in sorted order so equal maps encode to equal bytes.
+
+func (bs *BadgerStore) multiKey(id string) []byte {
+	return []byte(bs.prefix + "u:" + id)
+}
+
+func (bs *BadgerStore) multiPrefix() []byte {
+	return []byte(bs.prefix + "u:")
+}
+
+func encodeMulti(multi map[string][][]float32) []byte {
+	names := make([]string, 0, len(multi))
+	size := 5
+	for name, tokens := range multi {
+		names = append(names, name)
+		size += 12 + len(name)
+		for _, token := range tokens {
+			size += 4 * len(token)
+		}
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 5, size)
+	buf[0] = recordFormatV1
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(names)))
+	for _, name := range names {
+		tokens := multi[name]
+		dim := 0
+		if len(tokens) > 0 {
+			dim = len(tokens[0])
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(name)))
+		buf = append(buf, name...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(tokens)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(dim))
+		for _, token := range tokens {
+			for _, v := range token {
+				buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+			}
+		}
+	}
+	return buf
+}
+
+// decodeMulti decodes the multi-vectors of a record. With only set, it
+// skips every other name and returns at most that one.
+func decodeMulti(id string, val []byte, only string) (map[string][][]float32, error) {
+	if len(val) < 5 || val[0] != recordFormatV1 {
+		return nil, ErrRecordFormat(id, "unknown multi-vectors format")
+	}
+	count := binary.LittleEndian.Uint32(val[1:5])
+	multi := make(map[string][][]float32, count)
+	rest := val[5:]
+	for i := uint32(0); i < count; i++ {
+		if len(rest) < 4 {
+			return nil, ErrRecordFormat(id, "truncated multi-vector")
+		}
+		n := int(binary.LittleEndian.Uint32(rest[0:4]))
+		if len(rest) < 12+n {
+			return nil, ErrRecordFormat(id, "truncated multi-vector")
+		}
+		name := string(rest[4 : 4+n])
+		tokens := int(binary.LittleEndian.Uint32(rest[4+n : 8+n]))
+		dim := int(binary.LittleEndian.Uint32(rest[8+n : 12+n]))
+		rest = rest[12+n:]
+		if len(rest) < 4*tokens*dim {
+			return nil, ErrRecordFormat(id, "truncated multi-vector")
+		}
+		if only == "" || name == only {
+			bag := make([][]float32, tokens)
+			for t := range bag {
+				bag[t] = make([]float32, dim)
+				for j := range bag[t] {
+					bag[t][j] = math.Float32frombits(binary.LittleEndian.Uint32(rest[4*(t*dim+j):]))
+				}
+			}
+			multi[name] = bag
+		}
+		rest = rest[4*tokens*dim:]
+	}
+	if len(rest) != 0 {
+		return nil, ErrRecordFormat(id, "multi-vectors size mismatch")
+	}
+	return multi, nil
+}
+
+// readMulti returns a vector's multi-vectors, or nil if it has none.
+func (bs *BadgerStore) readMulti(txn *badger.Txn, id string) (map[string][][]float32, error) {
+	item, err := txn.Get(bs.multiKey(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var multi map[string][][]float32
+	err = item.Value(func(val []byte) error {
+		multi, err = decodeMulti(id, val, "")
+		return err
+	})
+	return multi, err
+}
+
+// setMulti replaces a vector's multi-vectors. An empty map removes them.
+func (bs *BadgerStore) setMulti(txn *badger.Txn, id string, multi map[string][][]float32) error {
+	if len(multi) == 0 {
+		return txn.Delete(bs.multiKey(id))
+	}
+	return txn.Set(bs.multiKey(id), encodeMulti(multi))
+}
+
+// GetMultiVector returns one multi-vector of a vector, or nil if the vector
+// has none by that name.
+func (bs *BadgerStore) GetMultiVector(id, name string) ([][]float32, error) {
+	var tokens [][]float32
+	err := bs.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(bs.vectorKey(id)); err != nil {
+			return err
+		}
+		item, err := txn.Get(bs.multiKey(id))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			multi, err := decodeMulti(id, val, name)
+			tokens = multi[name]
+			return err
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrBadgerKeyNotFound(id)
+	}
+	return tokens, err
+}
+
+// IterateMultiVectors walks the multi-vector called name of every stored
+// vector that has one.
+func (bs *BadgerStore) IterateMultiVectors(name string, fn func(id string, tokens [][]float32) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		prefix := bs.multiPrefix()
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			id := string(item.Key()[len(prefix):])
+
+			var tokens [][]float32
+			err := item.Value(func(val []byte) error {
+				multi, err := decodeMulti(id, val, name)
+				tokens = multi[name]
+				return err
+			})
+			if err != nil {
+				if bs.logger != nil {
+					bs.logger.Warn("Failed to decode multi-vectors",
+						logger.String("id", id),
+						logger.Error("error", err))
+				}
+				continue // Skip corrupted entries
+			}
+			if tokens == nil {
+				continue
+			}
+
+			if err := fn(id, tokens); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
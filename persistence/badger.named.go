@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/internal/logger"
+)
+
+// Vectors may carry named embeddings besides their own, all kept in one
+// record written in the same transactions as the vector:
+//
+//	n:<id>  format byte, count uint32 LE, then per embedding its name
+//	        length uint32 LE, the name, its dimension uint32 LE and the
+//	        values float32 LE
+//
+// Names are written in sorted order so equal maps encode to equal bytes.
+
+func (bs *BadgerStore) namedKey(id string) []byte {
+	return []byte(bs.prefix + "n:" + id)
+}
+
+func (bs *BadgerStore) namedPrefix() []byte {
+	return []byte(bs.prefix + "n:")
+}
+
+func encodeNamed(named map[string][]float32) []byte {
+	names := make([]string, 0, len(named))
+	size := 5
+	for name, embedding := range named {
+		names = append(names, name)
+		size += 8 + len(name) + 4*len(embedding)
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 5, size)
+	buf[0] = recordFormatV1
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(names)))
+	for _, name := range names {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(name)))
+		buf = append(buf, name...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(named[name])))
+		for _, v := range named[name] {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		}
+	}
+	return buf
+}
+
+// decodeNamed decodes the named embeddings of a record. With only set, it
+// skips every other name and returns at most that one.
+func decodeNamed(id string, val []byte, only string) (map[string][]float32, error) {
+	if len(val) < 5 || val[0] != recordFormatV1 {
+		return nil, ErrRecordFormat(id, "unknown named vectors format")
+	}
+	count := binary.LittleEndian.Uint32(val[1:5])
+	named := make(map[string][]float32, count)
+	rest := val[5:]
+	for i := uint32(0); i < count; i++ {
+		if len(rest) < 4 {
+			return nil, ErrRecordFormat(id, "truncated named vector")
+		}
+		n := int(binary.LittleEndian.Uint32(rest[0:4]))
+		if len(rest) < 8+n {
+			return nil, ErrRecordFormat(id, "truncated named vector")
+		}
+		name := string(rest[4 : 4+n])
+		dim := int(binary.LittleEndian.Uint32(rest[4+n : 8+n]))
+		rest = rest[8+n:]
+		if len(rest) < 4*dim {
+			return nil, ErrRecordFormat(id, "truncated named vector")
+		}
+		if only == "" || name == only {
+			embedding := make([]float32, dim)
+			for j := range embedding {
+				embedding[j] = math.Float32frombits(binary.LittleEndian.Uint32(rest[4*j:]))
+			}
+			named[name] = embedding
+		}
+		rest = rest[4*dim:]
+	}
+	if len(rest) != 0 {
+		return nil, ErrRecordFormat(id, "named vectors size mismatch")
+	}
+	return named, nil
+}
+
+// readNamed returns a vector's named embeddings, or nil if it has none.
+func (bs *BadgerStore) readNamed(txn *badger.Txn, id string) (map[string][]float32, error) {
+	item, err := txn.Get(bs.namedKey(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var named map[string][]float32
+	err = item.Value(func(val []byte) error {
+		named, err = decodeNamed(id, val, "")
+		return err
+	})
+	return named, err
+}
+
+// setNamed replaces a vector's named embeddings. An empty map removes them.
+func (bs *BadgerStore) setNamed(txn *badger.Txn, id string, named map[string][]float32) error {
+	if len(named) == 0 {
+		return txn.Delete(bs.namedKey(id))
+	}
+	return txn.Set(bs.namedKey(id), encodeNamed(named))
+}
+
+// GetNamedEmbedding returns one named embedding of a vector, or nil if the
+// vector has none by that name. The empty name is the vector's own
+// embedding, as with GetEmbedding.
+func (bs *BadgerStore) GetNamedEmbedding(id, name string) ([]float32, error) {
+	if name == "" {
+		return bs.GetEmbedding(id)
+	}
+
+	var embedding []float32
+	err := bs.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(bs.vectorKey(id)); err != nil {
+			return err
+		}
+		item, err := txn.Get(bs.namedKey(id))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			named, err := decodeNamed(id, val, name)
+			embedding = named[name]
+			return err
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrBadgerKeyNotFound(id)
+	}
+	return embedding, err
+}
+
+// IterateNamedEmbeddings walks the embedding called name of every stored
+// vector that has one. The empty name walks the vectors' own embeddings,
+// as IterateEmbeddings does.
+func (bs *BadgerStore) IterateNamedEmbeddings(name string, fn func(id string, embedding []float32) error) error {
+	if name == "" {
+		return bs.IterateEmbeddings(fn)
+	}
+
+	return bs.db.View(func(txn *badger.Txn) error {
+		prefix := bs.namedPrefix()
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			id := string(item.Key()[len(prefix):])
+
+			var embedding []float32
+			err := item.Value(func(val []byte) error {
+				named, err := decodeNamed(id, val, name)
+				embedding = named[name]
+				return err
+			})
+			if err != nil {
+				if bs.logger != nil {
+					bs.logger.Warn("Failed to decode named vectors",
+						logger.String("id", id),
+						logger.Error("error", err))
+				}
+				continue // Skip corrupted entries
+			}
+			if embedding == nil {
+				continue
+			}
+
+			if err := fn(id, embedding); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+// Vectors may carry a sparse embedding, which each keyspace keeps as an
+// inverted index in the same transactions that write the vectors:
+//
+//	w:<id>                   format byte, entry count uint32 LE, then
+//	                         index uint32 LE and value float32 LE per entry
+//	d:<index uint32 BE><id>  posting: the vector's value, float32 LE
+//
+// A query only reads the postings of its own non-zero indices, which is
+// what makes dot products over vocabularies of 30k+ dimensions cheap.
+
+func (bs *BadgerStore) sparseKey(id string) []byte {
+	return []byte(bs.prefix + "w:" + id)
+}
+
+func (bs *BadgerStore) sparsePostingPrefix(index uint32) []byte {
+	key := []byte(bs.prefix + "d:")
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+func (bs *BadgerStore) sparsePostingKey(index uint32, id string) []byte {
+	return append(bs.sparsePostingPrefix(index), id...)
+}
+
+func encodeSparse(sparse *types.SparseVector) []byte {
+	buf := make([]byte, 5, 5+8*len(sparse.Indices))
+	buf[0] = recordFormatV1
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(sparse.Indices)))
+	for i, index := range sparse.Indices {
+		buf = binary.LittleEndian.AppendUint32(buf, index)
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(sparse.Values[i]))
+	}
+	return buf
+}
+
+func decodeSparse(id string, val []byte) (*types.SparseVector, error) {
+	if len(val) < 5 || val[0] != recordFormatV1 {
+		return nil, ErrRecordFormat(id, "unknown sparse format")
+	}
+	n := int(binary.LittleEndian.Uint32(val[1:5]))
+	if len(val) != 5+8*n {
+		return nil, ErrRecordFormat(id, "sparse vector size mismatch")
+	}
+	sparse := &types.SparseVector{
+		Indices: make([]uint32, n),
+		Values:  make([]float32, n),
+	}
+	for i := 0; i < n; i++ {
+		entry := val[5+8*i:]
+		sparse.Indices[i] = binary.LittleEndian.Uint32(entry[0:4])
+		sparse.Values[i] = math.Float32frombits(binary.LittleEndian.Uint32(entry[4:8]))
+	}
+	return sparse, nil
+}
+
+// readSparse returns a vector's sparse embedding, or nil if it has none.
+func (bs *BadgerStore) readSparse(txn *badger.Txn, id string) (*types.SparseVector, error) {
+	item, err := txn.Get(bs.sparseKey(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sparse *types.SparseVector
+	err = item.Value(func(val []byte) error {
+		sparse, err = decodeSparse(id, val)
+		return err
+	})
+	return sparse, err
+}
+
+// setSparse replaces a vector's sparse embedding and its postings. A nil
+// or empty one removes them.
+func (bs *BadgerStore) setSparse(txn *badger.Txn, id string, sparse *types.SparseVector) error {
+	if err := bs.removeSparse(txn, id); err != nil {
+		return err
+	}
+	if sparse == nil || len(sparse.Indices) == 0 {
+		return nil
+	}
+
+	for i, index := range sparse.Indices {
+		var posting [4]byte
+		binary.LittleEndian.PutUint32(posting[:], math.Float32bits(sparse.Values[i]))
+		if err := txn.Set(bs.sparsePostingKey(index, id), posting[:]); err != nil {
+			return err
+		}
+	}
+	return txn.Set(bs.sparseKey(id), encodeSparse(sparse))
+}
+
+// removeSparse deletes a vector's sparse embedding and postings, if it has
+// any.
+func (bs *BadgerStore) removeSparse(txn *badger.Txn, id string) error {
+	sparse, err := bs.readSparse(txn, id)
+	if err != nil || sparse == nil {
+		return err
+	}
+
+	for _, index := range sparse.Indices {
+		if err := txn.Delete(bs.sparsePostingKey(index, id)); err != nil {
+			return err
+		}
+	}
+	return txn.Delete(bs.sparseKey(id))
+}
+
+// SearchSparse scores every vector sharing a non-zero index with the query
+// by their dot product and returns the k best accepted by allow, best
+// first. Like SearchText, it only consults allow for vectors that would
+// make the cut.
+func (bs *BadgerStore) SearchSparse(query *types.SparseVector, k int, allow func(id string) bool) ([]Hit, error) {
+	scores := make(map[string]float64)
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		for i, index := range query.Indices {
+			weight := float64(query.Values[i])
+			if weight == 0 {
+				continue
+			}
+			prefix := bs.sparsePostingPrefix(index)
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = prefix
+
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				item := it.Item()
+				id := string(item.Key()[len(prefix):])
+				err := item.Value(func(val []byte) error {
+					if len(val) != 4 {
+						return ErrRecordFormat(id, "invalid sparse posting")
+					}
+					scores[id] += weight * float64(math.Float32frombits(binary.LittleEndian.Uint32(val)))
+					return nil
+				})
+				if err != nil {
+					it.Close()
+					return err
+				}
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topHits(scores, k, allow), nil
+}
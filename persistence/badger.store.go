@@ -1,8 +1,9 @@
 package persistence
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -10,9 +11,14 @@ import (
 	"github.com/ishaan29/vectorDB/pkg/types"
 )
 
+// BadgerStore persists vectors in Badger. A store either owns the database
+// or is a view onto one keyspace of it, created with WithPrefix.
 type BadgerStore struct {
 	db     *badger.DB
 	logger logger.Logger
+	prefix string
+	owner  bool
+	gc     *sync.RWMutex // Shared by HoldChanges, exclusive while compacting
 }
 
 const batchSize = 100
@@ -50,19 +56,74 @@ func NewBadgerStore(path string, log logger.Logger) (*BadgerStore, error) {
 		return nil, fmt.Errorf("Failed to open badger db: %w", err)
 	}
 
-	go runGC(db, log)
-
-	return &BadgerStore{
+	bs := &BadgerStore{
 		db:     db,
 		logger: log,
-	}, nil
+		owner:  true,
+		gc:     &sync.RWMutex{},
+	}
+	if err := bs.migrateLayout(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to migrate storage layout: %w", err)
+	}
+
+	go runGC(db, bs.gc, log)
+
+	return bs, nil
+}
+
+// WithPrefix returns a view of the store that keeps its vectors under the
+// given key prefix. Views share the database and never close it.
+func (bs *BadgerStore) WithPrefix(prefix string) *BadgerStore {
+	return &BadgerStore{
+		db:     bs.db,
+		logger: bs.logger,
+		prefix: prefix,
+		gc:     bs.gc,
+	}
+}
+
+// DropAll deletes every key in this view's keyspace along with its
+// applied WAL position.
+func (bs *BadgerStore) DropAll() error {
+	if bs.prefix == "" {
+		return ErrBadgerDropRoot
+	}
+	return bs.db.DropPrefix([]byte(bs.prefix), bs.appliedKey())
+}
+
+func (bs *BadgerStore) vectorKey(id string) []byte {
+	return []byte(bs.prefix + bs.GetVectorKey(id))
 }
 
-func runGC(db *badger.DB, log logger.Logger) {
+func (bs *BadgerStore) metadataKey(id string) []byte {
+	return []byte(bs.prefix + bs.GetMetadataKey(id))
+}
+
+func (bs *BadgerStore) indexKey(name string) []byte {
+	return []byte(bs.prefix + bs.GetIndexKey(name))
+}
+
+// idFromKey strips the view prefix and the v: namespace from an embedding key.
+func (bs *BadgerStore) idFromKey(key []byte) string {
+	return string(key[len(bs.vectorKey("")):])
+}
+
+// iteratorOptions walks the embedding keys of this view, one per vector.
+func (bs *BadgerStore) iteratorOptions() badger.IteratorOptions {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = bs.vectorKey("")
+	return opts
+}
+
+func runGC(db *badger.DB, gc *sync.RWMutex, log logger.Logger) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
 	for range ticker.C {
+		if !gc.TryLock() {
+			continue // Changes are held or Compact is running
+		}
 		lsm, vlog := db.Size()
 		if vlog > 1<<32 {
 			err := db.RunValueLogGC(0.5)
@@ -76,30 +137,118 @@ func runGC(db *badger.DB, log logger.Logger) {
 				log.Warn("LSM flatten error ", logger.Error("error", err))
 			}
 		}
+		gc.Unlock()
 	}
 }
 
+// Compact garbage-collects the value log until no file is worth rewriting
+// and then flattens the LSM tree, which runGC only does once the database
+// has grown large. Views share the database, so compacting one compacts
+// all of them. It waits for changes held with HoldChanges to be released.
+// It returns the number of value log files rewritten.
+func (bs *BadgerStore) Compact() (int, error) {
+	bs.gc.Lock()
+	defer bs.gc.Unlock()
+
+	rewritten := 0
+	for {
+		err := bs.db.RunValueLogGC(0.5)
+		if err == badger.ErrNoRewrite || err == badger.ErrRejected {
+			// Nothing left to rewrite, or runGC is already at it
+			break
+		}
+		if err != nil {
+			return rewritten, fmt.Errorf("value log GC failed: %w", err)
+		}
+		rewritten++
+	}
+	if err := bs.db.Flatten(2); err != nil {
+		return rewritten, fmt.Errorf("LSM flatten failed: %w", err)
+	}
+	return rewritten, nil
+}
+
 func (bs *BadgerStore) Put(vector types.Vector) error {
-	data, err := json.Marshal(vector)
+	return bs.PutAt(vector, 0)
+}
+
+// PutAt stores the vector and, in the same transaction, records lsn as the
+// last WAL record applied to this keyspace. An lsn of 0 records nothing.
+func (bs *BadgerStore) PutAt(vector types.Vector, lsn uint64) error {
+	embedding, metadata, err := encodeRecord(vector)
 	if err != nil {
 		return ErrBadgerMarshal
 	}
 	return bs.db.Update(func(txn *badger.Txn) error {
-		key := []byte(vector.ID)
-		return txn.Set(key, data)
+		if err := bs.setRecord(txn, vector, embedding, metadata); err != nil {
+			return err
+		}
+		return bs.setAppliedLSN(txn, lsn)
 	})
 }
 
+// setRecord writes a vector's encoded embedding under v:, its encoded
+// metadata under m:, its named embeddings under n: and its multi-vectors
+// under u:, and indexes its text and sparse embedding. A vector without
+// metadata, named embeddings, multi-vectors, text or a sparse embedding
+// clears any stored for the ID.
+func (bs *BadgerStore) setRecord(txn *badger.Txn, vector types.Vector, embedding, metadata []byte) error {
+	id := vector.ID
+	if err := txn.Set(bs.vectorKey(id), embedding); err != nil {
+		return err
+	}
+	if metadata == nil {
+		if err := txn.Delete(bs.metadataKey(id)); err != nil {
+			return err
+		}
+	} else if err := txn.Set(bs.metadataKey(id), metadata); err != nil {
+		return err
+	}
+	if err := bs.setNamed(txn, id, vector.Vectors); err != nil {
+		return err
+	}
+	if err := bs.setMulti(txn, id, vector.MultiVectors); err != nil {
+		return err
+	}
+	if err := bs.setText(txn, id, vector.Text); err != nil {
+		return err
+	}
+	return bs.setSparse(txn, id, vector.Sparse)
+}
+
 func (bs *BadgerStore) Get(id string) (types.Vector, error) {
 	var vector types.Vector
 	err := bs.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
+		item, err := txn.Get(bs.vectorKey(id))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &vector)
+		err = item.Value(func(val []byte) error {
+			vector, err = decodeVector(id, val)
+			return err
 		})
+		if err != nil {
+			return err
+		}
+
+		metadata, err := bs.readMetadata(txn, id)
+		if err != nil {
+			return err
+		}
+		if metadata != nil {
+			vector.Metadata = metadata
+		}
+		if vector.Vectors, err = bs.readNamed(txn, id); err != nil {
+			return err
+		}
+		if vector.MultiVectors, err = bs.readMulti(txn, id); err != nil {
+			return err
+		}
+		if vector.Text, err = bs.readText(txn, id); err != nil {
+			return err
+		}
+		vector.Sparse, err = bs.readSparse(txn, id)
+		return err
 	})
 	if err == badger.ErrKeyNotFound {
 		return vector, ErrBadgerKeyNotFound(id)
@@ -107,13 +256,138 @@ func (bs *BadgerStore) Get(id string) (types.Vector, error) {
 	return vector, err
 }
 
+// GetEmbedding returns a vector's embedding without reading its metadata.
+func (bs *BadgerStore) GetEmbedding(id string) ([]float32, error) {
+	var vector types.Vector
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(bs.vectorKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			vector, err = decodeVector(id, val)
+			return err
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrBadgerKeyNotFound(id)
+	}
+	return vector.Embedding, err
+}
+
+// Exists reports whether a vector is stored under id without reading it.
+func (bs *BadgerStore) Exists(id string) (bool, error) {
+	err := bs.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(bs.vectorKey(id))
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetMetadata returns a vector's metadata without reading its embedding.
+func (bs *BadgerStore) GetMetadata(id string) (map[string]interface{}, error) {
+	var metadata map[string]interface{}
+	err := bs.db.View(func(txn *badger.Txn) error {
+		// Only the key is looked up, the embedding itself is never read
+		if _, err := txn.Get(bs.vectorKey(id)); err != nil {
+			return err
+		}
+		var err error
+		metadata, err = bs.readMetadata(txn, id)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrBadgerKeyNotFound(id)
+	}
+	return metadata, err
+}
+
+// UpdateMetadataAt replaces an existing vector's metadata, leaving its
+// embedding untouched, and records lsn like PutAt.
+func (bs *BadgerStore) UpdateMetadataAt(id string, metadata map[string]interface{}, lsn uint64) error {
+	var data []byte
+	if len(metadata) > 0 {
+		var err error
+		if data, err = encodeMetadata(metadata); err != nil {
+			return ErrBadgerMarshal
+		}
+	}
+
+	err := bs.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(bs.vectorKey(id)); err != nil {
+			return err
+		}
+		if data == nil {
+			if err := txn.Delete(bs.metadataKey(id)); err != nil {
+				return err
+			}
+		} else if err := txn.Set(bs.metadataKey(id), data); err != nil {
+			return err
+		}
+		return bs.setAppliedLSN(txn, lsn)
+	})
+	if err == badger.ErrKeyNotFound {
+		return ErrBadgerKeyNotFound(id)
+	}
+	return err
+}
+
+func (bs *BadgerStore) readMetadata(txn *badger.Txn, id string) (map[string]interface{}, error) {
+	item, err := txn.Get(bs.metadataKey(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata map[string]interface{}
+	err = item.Value(func(val []byte) error {
+		metadata, err = decodeMetadata(id, val)
+		return err
+	})
+	return metadata, err
+}
+
 func (bs *BadgerStore) Delete(id string) error {
+	return bs.DeleteAt(id, 0)
+}
+
+// DeleteAt removes the vector and records lsn like PutAt.
+func (bs *BadgerStore) DeleteAt(id string, lsn uint64) error {
 	return bs.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(id))
+		if err := txn.Delete(bs.vectorKey(id)); err != nil {
+			return err
+		}
+		if err := txn.Delete(bs.metadataKey(id)); err != nil {
+			return err
+		}
+		if err := txn.Delete(bs.namedKey(id)); err != nil {
+			return err
+		}
+		if err := txn.Delete(bs.multiKey(id)); err != nil {
+			return err
+		}
+		if err := bs.removeText(txn, id); err != nil {
+			return err
+		}
+		if err := bs.removeSparse(txn, id); err != nil {
+			return err
+		}
+		return bs.setAppliedLSN(txn, lsn)
 	})
 }
 
 func (bs *BadgerStore) BatchPut(vectors []types.Vector) error {
+	return bs.BatchPutAt(vectors, 0)
+}
+
+// BatchPutAt stores the vectors in chunks and records lsn with the last
+// chunk, so a crash part way through leaves the whole batch to be replayed.
+func (bs *BadgerStore) BatchPutAt(vectors []types.Vector, lsn uint64) error {
 
 	for i := 0; i < len(vectors); i += batchSize {
 		end := i + batchSize
@@ -124,15 +398,18 @@ func (bs *BadgerStore) BatchPut(vectors []types.Vector) error {
 
 		err := bs.db.Update(func(txn *badger.Txn) error {
 			for _, vector := range batch {
-				data, err := json.Marshal(vector)
+				embedding, metadata, err := encodeRecord(vector)
 				if err != nil {
 					return ErrBadgerBatchMarshal(vector.ID, err)
 				}
 
-				if err := txn.Set([]byte(vector.ID), data); err != nil {
+				if err := bs.setRecord(txn, vector, embedding, metadata); err != nil {
 					return ErrBadgerBatchSet(vector.ID, err)
 				}
 			}
+			if end == len(vectors) {
+				return bs.setAppliedLSN(txn, lsn)
+			}
 			return nil
 		})
 		if err != nil {
@@ -150,31 +427,268 @@ func (bs *BadgerStore) BatchPut(vectors []types.Vector) error {
 
 func (bs *BadgerStore) Iterate(fn func(types.Vector) error) error {
 	return bs.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
+		opts := bs.iteratorOptions()
 		opts.PrefetchValues = true
 		opts.PrefetchSize = 10
 
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
+		for it.Rewind(); it.Valid(); it.Next() {
+			vector, ok := bs.readItem(txn, it.Item())
+			if !ok {
+				continue // Skip corrupted entries
+			}
+			if err := fn(vector); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IterateAfter walks the stored vectors in ID order, starting after the
+// given ID or from the first one when it is empty. With withVectors unset
+// only the IDs and metadata are read. Each call is one read transaction,
+// so a walk split into pages by the last ID seen returns every vector
+// that exists throughout it exactly once, even while writes continue.
+func (bs *BadgerStore) IterateAfter(after string, withVectors bool, fn func(types.Vector) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		opts := bs.iteratorOptions()
+		opts.PrefetchValues = withVectors
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		if after == "" {
+			it.Rewind()
+		} else {
+			cursor := bs.vectorKey(after)
+			it.Seek(cursor)
+			if it.Valid() && bytes.Equal(it.Item().Key(), cursor) {
+				it.Next()
+			}
+		}
+
+		for ; it.Valid(); it.Next() {
+			var vector types.Vector
+			if withVectors {
+				var ok bool
+				if vector, ok = bs.readItem(txn, it.Item()); !ok {
+					continue // Skip corrupted entries
+				}
+			} else {
+				vector.ID = bs.idFromKey(it.Item().Key())
+				metadata, err := bs.readMetadata(txn, vector.ID)
+				if err != nil {
+					if bs.logger != nil {
+						bs.logger.Warn("Failed to read vector metadata",
+							logger.String("id", vector.ID),
+							logger.Error("error", err))
+					}
+				} else if metadata != nil {
+					vector.Metadata = metadata
+				}
+			}
+			if err := fn(vector); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readItem decodes the vector under an embedding key with everything
+// stored alongside it. Parts that can't be read are logged and left out;
+// it reports false if the embedding itself can't be.
+func (bs *BadgerStore) readItem(txn *badger.Txn, item *badger.Item) (types.Vector, bool) {
+	id := bs.idFromKey(item.Key())
+
+	var vector types.Vector
+	err := item.Value(func(val []byte) (err error) {
+		vector, err = decodeVector(id, val)
+		return err
+	})
+
+	if err != nil {
+		if bs.logger != nil {
+			bs.logger.Warn("Failed to decode vector",
+				logger.String("key", string(item.Key())),
+				logger.Error("error", err))
+		}
+		return vector, false
+	}
+
+	metadata, err := bs.readMetadata(txn, id)
+	if err != nil {
+		if bs.logger != nil {
+			bs.logger.Warn("Failed to read vector metadata",
+				logger.String("id", id),
+				logger.Error("error", err))
+		}
+	} else if metadata != nil {
+		vector.Metadata = metadata
+	}
+	if vector.Vectors, err = bs.readNamed(txn, id); err != nil && bs.logger != nil {
+		bs.logger.Warn("Failed to read named vectors",
+			logger.String("id", id),
+			logger.Error("error", err))
+	}
+	if vector.MultiVectors, err = bs.readMulti(txn, id); err != nil && bs.logger != nil {
+		bs.logger.Warn("Failed to read multi-vectors",
+			logger.String("id", id),
+			logger.Error("error", err))
+	}
+	if vector.Text, err = bs.readText(txn, id); err != nil && bs.logger != nil {
+		bs.logger.Warn("Failed to read vector text",
+			logger.String("id", id),
+			logger.Error("error", err))
+	}
+	if vector.Sparse, err = bs.readSparse(txn, id); err != nil && bs.logger != nil {
+		bs.logger.Warn("Failed to read vector sparse embedding",
+			logger.String("id", id),
+			logger.Error("error", err))
+	}
+	return vector, true
+}
+
+// IterateEmbeddings walks the embedding of every stored vector without
+// loading metadata.
+func (bs *BadgerStore) IterateEmbeddings(fn func(id string, embedding []float32) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(bs.iteratorOptions())
+		defer it.Close()
+
 		for it.Rewind(); it.Valid(); it.Next() {
 			item := it.Item()
+			id := bs.idFromKey(item.Key())
 
 			var vector types.Vector
-			err := item.Value(func(val []byte) error {
-				return json.Unmarshal(val, &vector)
+			err := item.Value(func(val []byte) (err error) {
+				vector, err = decodeVector(id, val)
+				return err
 			})
+			if err != nil {
+				if bs.logger != nil {
+					bs.logger.Warn("Failed to decode vector",
+						logger.String("key", string(item.Key())),
+						logger.Error("error", err))
+				}
+				continue // Skip corrupted entries
+			}
+
+			if err := fn(id, vector.Embedding); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IterateSince walks every stored vector for the index. Vectors whose
+// embedding was written after the given store version are decoded and
+// passed with changed set; older ones only carry their ID, so their values
+// are never read. Metadata is not loaded, and metadata-only updates don't
+// count as changes.
+func (bs *BadgerStore) IterateSince(version uint64, fn func(vector types.Vector, changed bool) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		opts := bs.iteratorOptions()
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
 
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			id := bs.idFromKey(item.Key())
+
+			if item.Version() <= version {
+				if err := fn(types.Vector{ID: id}, false); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var vector types.Vector
+			err := item.Value(func(val []byte) (err error) {
+				vector, err = decodeVector(id, val)
+				return err
+			})
 			if err != nil {
 				if bs.logger != nil {
-					bs.logger.Warn("Failed to unmarshal vector",
+					bs.logger.Warn("Failed to decode vector",
 						logger.String("key", string(item.Key())),
 						logger.Error("error", err))
 				}
 				continue // Skip corrupted entries
 			}
+			if vector.Vectors, err = bs.readNamed(txn, id); err != nil && bs.logger != nil {
+				bs.logger.Warn("Failed to read named vectors",
+					logger.String("id", id),
+					logger.Error("error", err))
+			}
+			if vector.MultiVectors, err = bs.readMulti(txn, id); err != nil && bs.logger != nil {
+				bs.logger.Warn("Failed to read multi-vectors",
+					logger.String("id", id),
+					logger.Error("error", err))
+			}
 
-			if err := fn(vector); err != nil {
+			if err := fn(vector, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HoldChanges keeps every change made from now on readable by
+// IterateChanges until release is called. Compaction may otherwise discard
+// the markers of deleted keys: Compact and the periodic GC wait, and an
+// open read transaction stops Badger's own compactions from dropping any
+// version written after it. Views share the hold.
+func (bs *BadgerStore) HoldChanges() (release func()) {
+	bs.gc.RLock()
+	txn := bs.db.NewTransaction(false)
+	return func() {
+		txn.Discard()
+		bs.gc.RUnlock()
+	}
+}
+
+// IterateChanges walks the vectors whose embedding was written or deleted
+// after the given store version, each once as it is stored now. Deleted
+// ones only carry their ID, and are only sure to be seen while the changes
+// are held. Only keys changed since then are read, so the walk is as short
+// as the list of changes.
+func (bs *BadgerStore) IterateChanges(version uint64, fn func(vector types.Vector, deleted bool) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		opts := bs.iteratorOptions()
+		opts.SinceTs = version
+		opts.AllVersions = true // Deletions only show up among all versions
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		var last []byte
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			// Versions of a key come newest first
+			if bytes.Equal(item.Key(), last) {
+				continue
+			}
+			last = item.KeyCopy(last)
+
+			if item.IsDeletedOrExpired() {
+				if err := fn(types.Vector{ID: bs.idFromKey(item.Key())}, true); err != nil {
+					return err
+				}
+				continue
+			}
+			vector, ok := bs.readItem(txn, item)
+			if !ok {
+				continue // Skip corrupted entries
+			}
+			if err := fn(vector, false); err != nil {
 				return err
 			}
 		}
@@ -182,7 +696,22 @@ func (bs *BadgerStore) Iterate(fn func(types.Vector) error) error {
 	})
 }
 
+// Version returns the commit version of the latest write to the store.
+func (bs *BadgerStore) Version() uint64 {
+	return bs.db.MaxVersion()
+}
+
+// Sync flushes pending writes to disk. Badger writes are not synced
+// individually, so this must run before WAL records covering them are
+// discarded.
+func (bs *BadgerStore) Sync() error {
+	return bs.db.Sync()
+}
+
 func (bs *BadgerStore) Close() error {
+	if !bs.owner {
+		return nil
+	}
 	return bs.db.Close()
 }
 
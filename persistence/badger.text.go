@@ -0,0 +1,235 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/pkg/bm25"
+)
+
+// Vectors may carry text, which each keyspace indexes for BM25 keyword
+// search in the same transactions that write the vectors:
+//
+//	t:<id>            format byte, term count uint32 LE, the text
+//	p:<term>\x00<id>  posting: term frequency and document length, uint32 LE
+//	s:text            documents with text and their total terms, uint64 LE
+//
+// A term's document frequency is the number of its postings, which are
+// read in full to score it anyway.
+const textStatsName = "s:text"
+
+// Hit is a vector matched by a keyword or sparse search.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+func (bs *BadgerStore) textKey(id string) []byte {
+	return []byte(bs.prefix + "t:" + id)
+}
+
+func (bs *BadgerStore) postingPrefix(term string) []byte {
+	return []byte(bs.prefix + "p:" + term + "\x00")
+}
+
+func (bs *BadgerStore) postingKey(term, id string) []byte {
+	return append(bs.postingPrefix(term), id...)
+}
+
+func (bs *BadgerStore) textStatsKey() []byte {
+	return []byte(bs.prefix + textStatsName)
+}
+
+func encodeText(text string, terms int) []byte {
+	buf := make([]byte, 5+len(text))
+	buf[0] = recordFormatV1
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(terms))
+	copy(buf[5:], text)
+	return buf
+}
+
+func decodeText(id string, val []byte) (string, error) {
+	if len(val) < 5 || val[0] != recordFormatV1 {
+		return "", ErrRecordFormat(id, "unknown text format")
+	}
+	return string(val[5:]), nil
+}
+
+// readText returns a vector's text, or "" if it has none.
+func (bs *BadgerStore) readText(txn *badger.Txn, id string) (string, error) {
+	item, err := txn.Get(bs.textKey(id))
+	if err == badger.ErrKeyNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	err = item.Value(func(val []byte) error {
+		text, err = decodeText(id, val)
+		return err
+	})
+	return text, err
+}
+
+func (bs *BadgerStore) readTextStats(txn *badger.Txn) (docs, terms uint64, err error) {
+	item, err := txn.Get(bs.textStatsKey())
+	if err == badger.ErrKeyNotFound {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	err = item.Value(func(val []byte) error {
+		if len(val) != 16 {
+			return fmt.Errorf("invalid text stats of %d bytes", len(val))
+		}
+		docs = binary.LittleEndian.Uint64(val[0:8])
+		terms = binary.LittleEndian.Uint64(val[8:16])
+		return nil
+	})
+	return docs, terms, err
+}
+
+// addTextStats adjusts the document and term totals by the given amounts.
+func (bs *BadgerStore) addTextStats(txn *badger.Txn, docs, terms int) error {
+	curDocs, curTerms, err := bs.readTextStats(txn)
+	if err != nil {
+		return err
+	}
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(int64(curDocs)+int64(docs)))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(int64(curTerms)+int64(terms)))
+	return txn.Set(bs.textStatsKey(), buf[:])
+}
+
+// setText replaces a vector's text and its postings. Empty text removes
+// them.
+func (bs *BadgerStore) setText(txn *badger.Txn, id, text string) error {
+	if err := bs.removeText(txn, id); err != nil {
+		return err
+	}
+	if text == "" {
+		return nil
+	}
+
+	tf, length := bm25.TermFrequencies(text)
+	var posting [8]byte
+	binary.LittleEndian.PutUint32(posting[4:8], uint32(length))
+	for term, n := range tf {
+		binary.LittleEndian.PutUint32(posting[0:4], uint32(n))
+		if err := txn.Set(bs.postingKey(term, id), append([]byte(nil), posting[:]...)); err != nil {
+			return err
+		}
+	}
+	if err := txn.Set(bs.textKey(id), encodeText(text, length)); err != nil {
+		return err
+	}
+	return bs.addTextStats(txn, 1, length)
+}
+
+// removeText deletes a vector's text and postings, if it has any.
+func (bs *BadgerStore) removeText(txn *badger.Txn, id string) error {
+	text, err := bs.readText(txn, id)
+	if err != nil || text == "" {
+		return err
+	}
+
+	tf, length := bm25.TermFrequencies(text)
+	for term := range tf {
+		if err := txn.Delete(bs.postingKey(term, id)); err != nil {
+			return err
+		}
+	}
+	if err := txn.Delete(bs.textKey(id)); err != nil {
+		return err
+	}
+	return bs.addTextStats(txn, -1, -length)
+}
+
+// SearchText scores every vector containing a term of the query with BM25
+// and returns the k best accepted by allow, best first. Like the indexes,
+// it only consults allow for vectors that would make the cut.
+func (bs *BadgerStore) SearchText(query string, k int, allow func(id string) bool) ([]Hit, error) {
+	terms, _ := bm25.TermFrequencies(query)
+	scores := make(map[string]float64)
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		docs, total, err := bs.readTextStats(txn)
+		if err != nil || docs == 0 {
+			return err
+		}
+		avgDocLen := float64(total) / float64(docs)
+
+		type posting struct {
+			id         string
+			tf, docLen int
+		}
+		for term := range terms {
+			prefix := bs.postingPrefix(term)
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = prefix
+
+			var postings []posting
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				item := it.Item()
+				p := posting{id: string(item.Key()[len(prefix):])}
+				err := item.Value(func(val []byte) error {
+					if len(val) != 8 {
+						return ErrRecordFormat(p.id, "invalid posting")
+					}
+					p.tf = int(binary.LittleEndian.Uint32(val[0:4]))
+					p.docLen = int(binary.LittleEndian.Uint32(val[4:8]))
+					return nil
+				})
+				if err != nil {
+					it.Close()
+					return err
+				}
+				postings = append(postings, p)
+			}
+			it.Close()
+
+			idf := bm25.IDF(len(postings), int(docs))
+			for _, p := range postings {
+				scores[p.id] += bm25.DefaultParams.Score(idf, p.tf, p.docLen, avgDocLen)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return topHits(scores, k, allow), nil
+}
+
+// topHits returns the k best scored vectors accepted by allow, best first.
+// Ties are broken by ID so results are stable.
+func topHits(scores map[string]float64, k int, allow func(id string) bool) []Hit {
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	kept := hits[:0]
+	for _, h := range hits {
+		if len(kept) == k {
+			break
+		}
+		if allow == nil || allow(h.ID) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestVectorCodecRoundTrip(t *testing.T) {
+	for _, vector := range []types.Vector{
+		{ID: "a", Embedding: []float32{0.1, -2.5, 3}, Metadata: map[string]interface{}{"lang": "go", "stars": 42.0}},
+		{ID: "b", Embedding: []float32{1}},
+		{ID: "c"},
+	} {
+		data, err := encodeVector(vector)
+		if err != nil {
+			t.Fatalf("encode %s: %v", vector.ID, err)
+		}
+		if data[0] != recordFormatV1 {
+			t.Errorf("encode %s: expected format byte %d, got %d", vector.ID, recordFormatV1, data[0])
+		}
+
+		got, err := decodeVector(vector.ID, data)
+		if err != nil {
+			t.Fatalf("decode %s: %v", vector.ID, err)
+		}
+		if len(got.Embedding) != len(vector.Embedding) {
+			t.Fatalf("decode %s: expected %d dims, got %d", vector.ID, len(vector.Embedding), len(got.Embedding))
+		}
+		for i := range vector.Embedding {
+			if got.Embedding[i] != vector.Embedding[i] {
+				t.Errorf("decode %s: embedding[%d] = %v, want %v", vector.ID, i, got.Embedding[i], vector.Embedding[i])
+			}
+		}
+		if len(got.Metadata) != len(vector.Metadata) || got.Metadata["lang"] != vector.Metadata["lang"] {
+			t.Errorf("decode %s: metadata = %v, want %v", vector.ID, got.Metadata, vector.Metadata)
+		}
+	}
+
+	if _, err := decodeVector("bad", []byte{recordFormatV1, 9, 0, 0, 0}); err == nil {
+		t.Errorf("Expected truncated record to fail")
+	}
+	if _, err := decodeVector("bad", []byte{7}); err == nil {
+		t.Errorf("Expected unknown format to fail")
+	}
+}
+
+func TestMigrateLayout(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir, log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	// Bare-ID keys as written by earlier versions, in both record formats
+	legacyJSON, _ := json.Marshal(types.Vector{ID: "a", Embedding: []float32{1, 2}, Metadata: map[string]interface{}{"lang": "go"}})
+	legacyBinary, _ := encodeVector(types.Vector{Embedding: []float32{3, 4}, Metadata: map[string]interface{}{"lang": "rust"}})
+	shadow, _ := encodeVector(types.Vector{Embedding: []float32{5, 6}})
+	err = store.db.Update(func(txn *badger.Txn) error {
+		txn.Delete([]byte(layoutKey))
+		txn.Set([]byte("a"), legacyJSON)
+		txn.Set([]byte("v:a"), shadow)
+		txn.Set([]byte(CollectionPrefix("code")+"b"), legacyBinary)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to write legacy records: %v", err)
+	}
+	store.Close()
+
+	store, err = NewBadgerStore(dir, log)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	a, err := store.Get("a")
+	if err != nil || a.Embedding[0] != 1 || a.Metadata["lang"] != "go" {
+		t.Errorf("Vector a not migrated: %v (err %v)", a, err)
+	}
+	store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(store.vectorKey("a"))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if val[0] != recordFormatV1 {
+				t.Errorf("Expected vector a rewritten in the binary format, got format %d", val[0])
+			}
+			return nil
+		})
+	})
+	if va, err := store.Get("v:a"); err != nil || va.Embedding[0] != 5 {
+		t.Errorf("Vector v:a not migrated: %v (err %v)", va, err)
+	}
+	code := store.WithPrefix(CollectionPrefix("code"))
+	if metadata, err := code.GetMetadata("b"); err != nil || metadata["lang"] != "rust" {
+		t.Errorf("Collection vector b not migrated: %v (err %v)", metadata, err)
+	}
+
+	count := 0
+	store.Iterate(func(types.Vector) error {
+		count++
+		return nil
+	})
+	if count != 2 {
+		t.Errorf("Expected 2 vectors in the default keyspace, got %d", count)
+	}
+}
@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestMultiVectors(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("code"))
+
+	err = store.BatchPut([]types.Vector{
+		{ID: "a", Embedding: []float32{1}, MultiVectors: map[string][][]float32{"tokens": {{1, 2}, {3, 4}, {5, 6}}}},
+		{ID: "b", Embedding: []float32{2}, MultiVectors: map[string][][]float32{"tokens": {{7, 8}}, "lines": {{9}}}},
+		{ID: "c", Embedding: []float32{3}, Vectors: map[string][]float32{"doc": {1}}},
+	})
+	if err != nil {
+		t.Fatalf("BatchPut failed: %v", err)
+	}
+
+	if v, _ := store.Get("b"); len(v.MultiVectors) != 2 || fmt.Sprint(v.MultiVectors["lines"]) != "[[9]]" {
+		t.Errorf("Expected both multi-vectors, got %v", v.MultiVectors)
+	}
+	if tokens, err := store.GetMultiVector("a", "tokens"); err != nil || fmt.Sprint(tokens) != "[[1 2] [3 4] [5 6]]" {
+		t.Errorf("Expected a's tokens, got %v (err %v)", tokens, err)
+	}
+	if tokens, err := store.GetMultiVector("a", "lines"); err != nil || tokens != nil {
+		t.Errorf("Expected no lines for a, got %v (err %v)", tokens, err)
+	}
+	if _, err := store.GetMultiVector("missing", "tokens"); err == nil {
+		t.Errorf("Expected an error for a missing vector")
+	}
+
+	walk := func(name string) string {
+		var ids []string
+		store.IterateMultiVectors(name, func(id string, tokens [][]float32) error {
+			ids = append(ids, fmt.Sprintf("%s:%d", id, len(tokens)))
+			return nil
+		})
+		return fmt.Sprint(ids)
+	}
+	if ids := walk("tokens"); ids != "[a:3 b:1]" {
+		t.Errorf("Expected a and b to have tokens, got %s", ids)
+	}
+
+	// Replacing a vector without multi-vectors and deleting one clears them
+	store.Put(types.Vector{ID: "a", Embedding: []float32{1}})
+	store.Delete("b")
+	if ids := walk("tokens"); ids != "[]" {
+		t.Errorf("Expected no tokens left, got %s", ids)
+	}
+}
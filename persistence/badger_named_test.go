@@ -0,0 +1,67 @@
+package persistence
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestNamedEmbeddings(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("files"))
+
+	err = store.BatchPut([]types.Vector{
+		{ID: "a", Embedding: []float32{1}, Vectors: map[string][]float32{"code": {1, 2, 3}, "doc": {4, 5}}},
+		{ID: "b", Embedding: []float32{2}, Vectors: map[string][]float32{"code": {6, 7, 8}}},
+		{ID: "c", Embedding: []float32{3}},
+	})
+	if err != nil {
+		t.Fatalf("BatchPut failed: %v", err)
+	}
+
+	if v, _ := store.Get("a"); len(v.Vectors) != 2 || v.Vectors["doc"][1] != 5 {
+		t.Errorf("Expected both named embeddings, got %v", v.Vectors)
+	}
+	if e, err := store.GetNamedEmbedding("b", "code"); err != nil || fmt.Sprint(e) != "[6 7 8]" {
+		t.Errorf("Expected b's code embedding, got %v (err %v)", e, err)
+	}
+	if e, err := store.GetNamedEmbedding("b", "doc"); err != nil || e != nil {
+		t.Errorf("Expected no doc embedding for b, got %v (err %v)", e, err)
+	}
+	if e, err := store.GetNamedEmbedding("c", ""); err != nil || e[0] != 3 {
+		t.Errorf("Expected the empty name to be the vector's own embedding, got %v (err %v)", e, err)
+	}
+	if _, err := store.GetNamedEmbedding("missing", "code"); err == nil {
+		t.Errorf("Expected an error for a missing vector")
+	}
+
+	walk := func(name string) string {
+		var ids []string
+		store.IterateNamedEmbeddings(name, func(id string, embedding []float32) error {
+			ids = append(ids, id)
+			return nil
+		})
+		return fmt.Sprint(ids)
+	}
+	if ids := walk("code"); ids != "[a b]" {
+		t.Errorf("Expected a and b to have code embeddings, got %s", ids)
+	}
+	if ids := walk("doc"); ids != "[a]" {
+		t.Errorf("Expected only a to have a doc embedding, got %s", ids)
+	}
+
+	// Replacing a vector without named embeddings and deleting one clears
+	// them
+	store.Put(types.Vector{ID: "a", Embedding: []float32{1}})
+	store.Delete("b")
+	if ids := walk("code"); ids != "[]" {
+		t.Errorf("Expected no code embeddings left, got %s", ids)
+	}
+}
@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestSearchSparse(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("docs"))
+
+	sparse := func(indices []uint32, values []float32) *types.SparseVector {
+		return &types.SparseVector{Indices: indices, Values: values}
+	}
+	err = store.BatchPut([]types.Vector{
+		{ID: "a", Embedding: []float32{1}, Sparse: sparse([]uint32{1, 2}, []float32{1, 2})},
+		{ID: "b", Embedding: []float32{1}, Sparse: sparse([]uint32{2, 300000}, []float32{0.5, 4})},
+		{ID: "c", Embedding: []float32{1}, Sparse: sparse([]uint32{3}, []float32{9})},
+		{ID: "plain", Embedding: []float32{1}},
+	})
+	if err != nil {
+		t.Fatalf("BatchPut failed: %v", err)
+	}
+	// Other keyspaces are indexed separately
+	root.Put(types.Vector{ID: "other", Embedding: []float32{1}, Sparse: sparse([]uint32{2}, []float32{100})})
+
+	// a scores 1*1 + 2*2 and b 2*0.5 + 1*4, so they tie at 5
+	query := sparse([]uint32{1, 2, 300000}, []float32{1, 2, 1})
+	hits, err := store.SearchSparse(query, 10, nil)
+	if err != nil {
+		t.Fatalf("SearchSparse failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "a" || hits[0].Score != 5 || hits[1].ID != "b" || hits[1].Score != 5 {
+		t.Errorf("Expected a and b tied at 5 in ID order, got %v", hits)
+	}
+	hits, _ = store.SearchSparse(query, 1, func(id string) bool { return id != "a" })
+	if len(hits) != 1 || hits[0].ID != "b" {
+		t.Errorf("Expected the filter to drop a, got %v", hits)
+	}
+
+	if v, _ := store.Get("b"); v.Sparse == nil || len(v.Sparse.Indices) != 2 || v.Sparse.Values[1] != 4 {
+		t.Errorf("Expected the sparse embedding to be stored, got %+v", v.Sparse)
+	}
+	if v, _ := store.Get("plain"); v.Sparse != nil {
+		t.Errorf("Expected no sparse embedding, got %+v", v.Sparse)
+	}
+
+	// Replacing and deleting vectors updates their postings
+	store.Put(types.Vector{ID: "a", Embedding: []float32{1}, Sparse: sparse([]uint32{3}, []float32{1})})
+	store.Delete("b")
+	if hits, _ := store.SearchSparse(query, 10, nil); len(hits) != 0 {
+		t.Errorf("Expected stale postings to be gone, got %v", hits)
+	}
+	hits, _ = store.SearchSparse(sparse([]uint32{3}, []float32{1}), 10, nil)
+	if len(hits) != 2 || hits[0].ID != "c" || hits[1].ID != "a" {
+		t.Errorf("Expected c then a, got %v", hits)
+	}
+}
@@ -0,0 +1,152 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestIterateAfter(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("docs"))
+
+	err = store.BatchPut([]types.Vector{
+		{ID: "c", Embedding: []float32{3}},
+		{ID: "a", Embedding: []float32{1}, Metadata: map[string]interface{}{"n": 1.0}},
+		{ID: "b", Embedding: []float32{2}, Text: "two"},
+	})
+	if err != nil {
+		t.Fatalf("BatchPut failed: %v", err)
+	}
+	// Other keyspaces are walked separately
+	root.Put(types.Vector{ID: "other", Embedding: []float32{1}})
+
+	stop := errors.New("stop")
+	walk := func(after string, withVectors bool, limit int) []types.Vector {
+		var vectors []types.Vector
+		err := store.IterateAfter(after, withVectors, func(v types.Vector) error {
+			vectors = append(vectors, v)
+			if len(vectors) == limit {
+				return stop
+			}
+			return nil
+		})
+		if err != nil && err != stop {
+			t.Fatalf("IterateAfter failed: %v", err)
+		}
+		return vectors
+	}
+	ids := func(vectors []types.Vector) string {
+		s := make([]string, len(vectors))
+		for i, v := range vectors {
+			s[i] = v.ID
+		}
+		return fmt.Sprint(s)
+	}
+
+	page := walk("", false, 2)
+	if ids(page) != "[a b]" || page[0].Metadata["n"] != 1.0 || page[0].Embedding != nil || page[1].Text != "" {
+		t.Errorf("Expected a and b with metadata only, got %+v", page)
+	}
+	if page = walk("b", true, 2); ids(page) != "[c]" || len(page[0].Embedding) != 1 {
+		t.Errorf("Expected c with its embedding after b, got %+v", page)
+	}
+	// The cursor doesn't have to exist any more
+	if page = walk("a0", true, 0); ids(page) != "[b c]" || page[0].Text != "two" {
+		t.Errorf("Expected b and c after a deleted ID, got %+v", page)
+	}
+}
+
+func TestIterateChanges(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("docs"))
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Put(types.Vector{ID: id, Embedding: []float32{1}}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	since := store.Version()
+
+	store.Put(types.Vector{ID: "b", Embedding: []float32{2}})
+	store.Put(types.Vector{ID: "b", Embedding: []float32{3}})
+	store.Delete("c")
+	store.Put(types.Vector{ID: "d", Embedding: []float32{4}})
+	root.Put(types.Vector{ID: "other", Embedding: []float32{1}})
+
+	var changes []string
+	err = store.IterateChanges(since, func(v types.Vector, deleted bool) error {
+		if deleted {
+			changes = append(changes, "-"+v.ID)
+		} else {
+			changes = append(changes, fmt.Sprintf("%s=%v", v.ID, v.Embedding))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateChanges failed: %v", err)
+	}
+	// Unchanged vectors are left out and changed ones come once, as they are now
+	if fmt.Sprint(changes) != "[b=[3] -c d=[4]]" {
+		t.Errorf("Expected [b=[3] -c d=[4]], got %v", changes)
+	}
+}
+
+func TestHoldChanges(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("docs"))
+	store.Put(types.Vector{ID: "a", Embedding: []float32{1}})
+
+	release := store.HoldChanges()
+	since := store.Version()
+	store.Delete("a")
+
+	// Compaction through any view waits for the hold
+	compacted := make(chan struct{})
+	go func() {
+		root.Compact()
+		close(compacted)
+	}()
+	select {
+	case <-compacted:
+		t.Fatalf("Expected Compact to wait for the held changes")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var deleted []string
+	store.IterateChanges(since, func(v types.Vector, gone bool) error {
+		if gone {
+			deleted = append(deleted, v.ID)
+		}
+		return nil
+	})
+	if fmt.Sprint(deleted) != "[a]" {
+		t.Errorf("Expected the deletion of a, got %v", deleted)
+	}
+
+	release()
+	select {
+	case <-compacted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Compact to run once the changes were released")
+	}
+}
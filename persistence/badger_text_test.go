@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func TestSearchText(t *testing.T) {
+	log, _ := logger.New(&logger.Config{Level: "error", Encoding: "json", OutputPaths: []string{"stdout"}})
+	root, err := NewBadgerStore(t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer root.Close()
+	store := root.WithPrefix(CollectionPrefix("code"))
+
+	err = store.BatchPut([]types.Vector{
+		{ID: "open", Embedding: []float32{1}, Text: "func OpenWAL(path string) opens the write-ahead log"},
+		{ID: "parse", Embedding: []float32{1}, Text: "func parse_config(path string) reads the config file"},
+		{ID: "long", Embedding: []float32{1}, Text: "the config the config the config and many other words about nothing in particular"},
+		{ID: "plain", Embedding: []float32{1}},
+	})
+	if err != nil {
+		t.Fatalf("BatchPut failed: %v", err)
+	}
+	// Other keyspaces are indexed separately
+	root.Put(types.Vector{ID: "other", Embedding: []float32{1}, Text: "parse_config"})
+
+	hits, err := store.SearchText("parse_config", 10, nil)
+	if err != nil {
+		t.Fatalf("SearchText failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != "parse" {
+		t.Errorf("Expected only the exact identifier to match, got %v", hits)
+	}
+
+	hits, _ = store.SearchText("config path", 10, nil)
+	if len(hits) != 3 || hits[0].ID != "parse" {
+		t.Errorf("Expected parse to rank first of 3 for both terms, got %v", hits)
+	}
+	hits, _ = store.SearchText("config path", 10, func(id string) bool { return id != "parse" })
+	if len(hits) != 2 || hits[0].ID == "parse" {
+		t.Errorf("Expected the filter to drop parse, got %v", hits)
+	}
+
+	// Replacing and deleting vectors updates their postings and the stats
+	store.Put(types.Vector{ID: "parse", Embedding: []float32{1}, Text: "load settings"})
+	store.Delete("open")
+	if hits, _ := store.SearchText("parse_config OpenWAL", 10, nil); len(hits) != 0 {
+		t.Errorf("Expected stale postings to be gone, got %v", hits)
+	}
+	if v, _ := store.Get("parse"); v.Text != "load settings" {
+		t.Errorf("Expected the new text, got %q", v.Text)
+	}
+	store.db.View(func(txn *badger.Txn) error {
+		docs, terms, err := store.readTextStats(txn)
+		if err != nil || docs != 2 || terms != 16 {
+			t.Errorf("Expected 2 documents of 16 terms, got %d and %d (err %v)", docs, terms, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrWALClosed = errors.New("write-ahead log is closed")
+
+func ErrWALOpen(err error) error {
+	return fmt.Errorf("failed to open write-ahead log: %w", err)
+}
+
+func ErrWALWrite(err error) error {
+	return fmt.Errorf("failed to write to write-ahead log: %w", err)
+}
+
+func ErrWALCorrupt(err error) error {
+	return fmt.Errorf("corrupt write-ahead log: %w", err)
+}
@@ -0,0 +1,714 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+// WALOp is the kind of mutation a WAL record describes.
+type WALOp uint8
+
+const (
+	WALPut    WALOp = 1
+	WALDelete WALOp = 2
+	// WALMetadata replaces a vector's metadata and leaves its embedding
+	// alone. Its records carry no embedding.
+	WALMetadata WALOp = 3
+)
+
+// walVersion is the format of new logs. Logs of version 1, whose put
+// records carry only an embedding and metadata, are rewritten when opened.
+const walVersion uint8 = 2
+
+// Parts of a vector a put record carries besides its embedding and
+// metadata. A flags byte after the metadata says which of them follow, in
+// this order.
+const (
+	walText uint8 = 1 << iota
+	walSparse
+	walVectors
+	walMultiVectors
+)
+
+var walMagic = [4]byte{'V', 'W', 'A', 'L'}
+
+// maxWALRecordSize bounds the payload of a record. Writing a larger one
+// fails, so a larger length read back is taken for a corrupt tail rather
+// than allocated.
+const maxWALRecordSize = 1 << 28
+
+// walHeader starts every log file. BaseLSN is the last sequence number
+// discarded by a checkpoint, so numbering continues after a truncation.
+type walHeader struct {
+	Magic   [4]byte
+	Version uint8
+	BaseLSN uint64
+}
+
+var walHeaderSize = int64(binary.Size(walHeader{}))
+
+// WALRecord is one logged mutation. Delete records only carry the vector
+// ID, metadata records the ID and the new metadata.
+type WALRecord struct {
+	LSN        uint64
+	Op         WALOp
+	Collection string
+	Vector     types.Vector
+}
+
+// WAL is an append-only, fsynced log of mutations. Every record gets a
+// log sequence number (LSN); once Append returns, the records survive a
+// crash and are replayed on the next start.
+//
+// Each record is framed as length, CRC-32 and payload, so a torn write at
+// the tail is detected and cut off when the log is opened.
+type WAL struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	logger  logger.Logger
+	version uint8 // Format of the records in file
+	baseLSN uint64
+	lastLSN uint64
+	size    int64
+}
+
+// OpenWAL opens or creates the log at path and positions it after the last
+// intact record.
+func OpenWAL(path string, log logger.Logger) (*WAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, ErrWALOpen(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, ErrWALOpen(err)
+	}
+
+	w := &WAL{path: path, file: f, logger: log}
+	if err := w.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// recover reads the header, finds the end of the last intact record and
+// truncates anything after it.
+func (w *WAL) recover() error {
+	info, err := w.file.Stat()
+	if err != nil {
+		return ErrWALOpen(err)
+	}
+	if info.Size() == 0 {
+		w.size = walHeaderSize
+		w.version = walVersion
+		return writeWALHeader(w.file, 0)
+	}
+
+	var header walHeader
+	if err := binary.Read(w.file, binary.LittleEndian, &header); err != nil {
+		return ErrWALCorrupt(err)
+	}
+	if header.Magic != walMagic || header.Version < 1 || header.Version > walVersion {
+		return ErrWALCorrupt(errors.New("bad header"))
+	}
+	w.version = header.Version
+	w.baseLSN = header.BaseLSN
+	w.lastLSN = header.BaseLSN
+
+	end := walHeaderSize
+	r := bufio.NewReader(w.file)
+	for {
+		rec, n, err := readWALRecord(r, w.version, info.Size()-end)
+		if err != nil {
+			if err != io.EOF && w.logger != nil {
+				w.logger.Warn("Discarding torn tail of write-ahead log",
+					logger.String("path", w.path),
+					logger.Int64("offset", end),
+					logger.Error("error", err))
+			}
+			break
+		}
+		w.lastLSN = rec.LSN
+		end += n
+	}
+
+	if end < info.Size() {
+		if err := w.file.Truncate(end); err != nil {
+			return ErrWALOpen(err)
+		}
+	}
+	if _, err := w.file.Seek(end, io.SeekStart); err != nil {
+		return ErrWALOpen(err)
+	}
+	w.size = end
+
+	if w.version != walVersion {
+		// New records are appended in the current format only
+		return w.rewrite(w.baseLSN)
+	}
+	return nil
+}
+
+func writeWALHeader(f *os.File, base uint64) error {
+	header := walHeader{Magic: walMagic, Version: walVersion, BaseLSN: base}
+	if err := binary.Write(f, binary.LittleEndian, header); err != nil {
+		return ErrWALWrite(err)
+	}
+	if err := f.Sync(); err != nil {
+		return ErrWALWrite(err)
+	}
+	return nil
+}
+
+// Append assigns the next LSNs to the records, writes them and syncs the
+// file. It returns the LSN of the last record.
+func (w *WAL) Append(records ...*WALRecord) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, ErrWALClosed
+	}
+
+	var buf bytes.Buffer
+	lsn := w.lastLSN
+	for _, rec := range records {
+		lsn++
+		rec.LSN = lsn
+		if err := writeWALRecord(&buf, rec); err != nil {
+			return 0, ErrWALWrite(err)
+		}
+	}
+
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		w.dropBatch(lsn)
+		return 0, ErrWALWrite(err)
+	}
+	if err := w.file.Sync(); err != nil {
+		// The batch isn't durable, so it isn't acknowledged either
+		w.dropBatch(lsn)
+		return 0, ErrWALWrite(err)
+	}
+
+	w.size += int64(buf.Len())
+	w.lastLSN = lsn
+	return lsn, nil
+}
+
+// dropBatch cuts off whatever part of a failed batch made it to the file,
+// so the log ends with the last acknowledged record again. If that fails
+// too, the size follows the file and the batch's LSNs are skipped, as its
+// records may still be replayed. The caller holds the lock.
+func (w *WAL) dropBatch(lsn uint64) {
+	if err := w.file.Truncate(w.size); err == nil {
+		if _, err := w.file.Seek(w.size, io.SeekStart); err == nil {
+			return
+		}
+	}
+	if info, err := w.file.Stat(); err == nil {
+		w.size = info.Size()
+	}
+	w.lastLSN = lsn
+	if _, err := w.file.Seek(w.size, io.SeekStart); err != nil && w.logger != nil {
+		w.logger.Error("Failed to reposition write-ahead log", logger.Error("error", err))
+	}
+}
+
+// Replay calls fn with every record newer than since, in LSN order.
+func (w *WAL) Replay(since uint64, fn func(rec *WALRecord) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrWALClosed
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return ErrWALOpen(err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(io.NewSectionReader(f, walHeaderSize, w.size-walHeaderSize))
+	remaining := w.size - walHeaderSize
+	for {
+		rec, n, err := readWALRecord(r, w.version, remaining)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ErrWALCorrupt(err)
+		}
+		remaining -= n
+		if rec.LSN <= since {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Truncate discards every record up to and including upTo. Callers must
+// make sure those mutations are durable elsewhere first. If upTo is past
+// the last record, numbering continues after upTo.
+func (w *WAL) Truncate(upTo uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrWALClosed
+	}
+	if upTo <= w.baseLSN {
+		return nil
+	}
+	return w.rewrite(upTo)
+}
+
+// rewrite replaces the log with one in the current format holding only the
+// records newer than upTo. The caller holds the lock.
+func (w *WAL) rewrite(upTo uint64) error {
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return ErrWALWrite(err)
+	}
+	kept, err := w.copyAfter(tmp, upTo)
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(w.path))
+
+	w.file.Close()
+	w.file = tmp
+	if _, err := w.file.Seek(kept, io.SeekStart); err != nil {
+		return ErrWALWrite(err)
+	}
+	w.version = walVersion
+	w.baseLSN = upTo
+	if upTo > w.lastLSN {
+		w.lastLSN = upTo
+	}
+	w.size = kept
+	return nil
+}
+
+// copyAfter writes a new log to dst holding only the records newer than
+// upTo and returns its size.
+func (w *WAL) copyAfter(dst *os.File, upTo uint64) (int64, error) {
+	if err := writeWALHeader(dst, upTo); err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(w.file, walHeaderSize, w.size-walHeaderSize))
+	remaining := w.size - walHeaderSize
+	bw := bufio.NewWriter(dst)
+	for {
+		rec, n, err := readWALRecord(r, w.version, remaining)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, ErrWALCorrupt(err)
+		}
+		remaining -= n
+		if rec.LSN <= upTo {
+			continue
+		}
+		if err := writeWALRecord(bw, rec); err != nil {
+			return 0, ErrWALWrite(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, ErrWALWrite(err)
+	}
+	if err := dst.Sync(); err != nil {
+		return 0, ErrWALWrite(err)
+	}
+	// Records in an older format don't keep their size
+	size, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, ErrWALWrite(err)
+	}
+	return size, nil
+}
+
+// LastLSN returns the LSN of the most recently appended record.
+func (w *WAL) LastLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastLSN
+}
+
+// Size returns the current size of the log file in bytes.
+func (w *WAL) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// writeWALRecord frames a record as payload length, CRC-32 of the payload
+// and the payload itself.
+func writeWALRecord(w io.Writer, rec *WALRecord) error {
+	var payload bytes.Buffer
+	binary.Write(&payload, binary.LittleEndian, rec.LSN)
+	payload.WriteByte(byte(rec.Op))
+	writeWALString(&payload, rec.Collection)
+	writeWALString(&payload, rec.Vector.ID)
+
+	if rec.Op == WALPut || rec.Op == WALMetadata {
+		writeUint32(&payload, uint32(len(rec.Vector.Embedding)))
+		for _, v := range rec.Vector.Embedding {
+			writeUint32(&payload, math.Float32bits(v))
+		}
+		var metadata []byte
+		if rec.Vector.Metadata != nil {
+			var err error
+			if metadata, err = json.Marshal(rec.Vector.Metadata); err != nil {
+				return err
+			}
+		}
+		writeWALString(&payload, string(metadata))
+	}
+	if rec.Op == WALPut {
+		var flags uint8
+		if rec.Vector.Text != "" {
+			flags |= walText
+		}
+		if rec.Vector.Sparse != nil && len(rec.Vector.Sparse.Indices) > 0 {
+			flags |= walSparse
+		}
+		if len(rec.Vector.Vectors) > 0 {
+			flags |= walVectors
+		}
+		if len(rec.Vector.MultiVectors) > 0 {
+			flags |= walMultiVectors
+		}
+		payload.WriteByte(flags)
+		if flags&walText != 0 {
+			writeWALString(&payload, rec.Vector.Text)
+		}
+		if flags&walSparse != 0 {
+			sparse := rec.Vector.Sparse
+			writeUint32(&payload, uint32(len(sparse.Indices)))
+			for i, index := range sparse.Indices {
+				writeUint32(&payload, index)
+				writeUint32(&payload, math.Float32bits(sparse.Values[i]))
+			}
+		}
+		if flags&walVectors != 0 {
+			names := make([]string, 0, len(rec.Vector.Vectors))
+			for name := range rec.Vector.Vectors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			writeUint32(&payload, uint32(len(names)))
+			for _, name := range names {
+				writeWALString(&payload, name)
+				writeUint32(&payload, uint32(len(rec.Vector.Vectors[name])))
+				for _, v := range rec.Vector.Vectors[name] {
+					writeUint32(&payload, math.Float32bits(v))
+				}
+			}
+		}
+		if flags&walMultiVectors != 0 {
+			names := make([]string, 0, len(rec.Vector.MultiVectors))
+			for name := range rec.Vector.MultiVectors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			writeUint32(&payload, uint32(len(names)))
+			for _, name := range names {
+				tokens := rec.Vector.MultiVectors[name]
+				writeWALString(&payload, name)
+				writeUint32(&payload, uint32(len(tokens)))
+				for _, token := range tokens {
+					writeUint32(&payload, uint32(len(token)))
+					for _, v := range token {
+						writeUint32(&payload, math.Float32bits(v))
+					}
+				}
+			}
+		}
+	}
+
+	if payload.Len() > maxWALRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the maximum of %d", payload.Len(), maxWALRecordSize)
+	}
+	var frame [8]byte
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+	if _, err := w.Write(frame[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// readWALRecord returns the next record, in the given format, and the
+// number of bytes it took out of the remaining ones. A clean end of the log
+// is io.EOF; a partial or corrupt record is any other error.
+func readWALRecord(r io.Reader, version uint8, remaining int64) (*WALRecord, int64, error) {
+	var frame [8]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errors.New("truncated record header")
+		}
+		return nil, 0, err
+	}
+	length := binary.LittleEndian.Uint32(frame[0:4])
+	checksum := binary.LittleEndian.Uint32(frame[4:8])
+	if length > maxWALRecordSize || int64(length) > remaining-int64(len(frame)) {
+		return nil, 0, fmt.Errorf("record length %d exceeds the log", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errors.New("truncated record")
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+
+	rec, err := decodeWALPayload(bytes.NewReader(payload), version)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rec, int64(len(frame)) + int64(length), nil
+}
+
+func decodeWALPayload(r *bytes.Reader, version uint8) (*WALRecord, error) {
+	rec := &WALRecord{}
+	if err := binary.Read(r, binary.LittleEndian, &rec.LSN); err != nil {
+		return nil, err
+	}
+	op, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	rec.Op = WALOp(op)
+	if rec.Collection, err = readWALString(r); err != nil {
+		return nil, err
+	}
+	if rec.Vector.ID, err = readWALString(r); err != nil {
+		return nil, err
+	}
+
+	switch rec.Op {
+	case WALDelete:
+		return rec, nil
+	case WALPut, WALMetadata:
+	default:
+		return nil, errors.New("unknown operation")
+	}
+
+	dim, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if int(dim)*4 > r.Len() {
+		return nil, errors.New("embedding exceeds record")
+	}
+	rec.Vector.Embedding = make([]float32, dim)
+	for i := range rec.Vector.Embedding {
+		bits, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.Vector.Embedding[i] = math.Float32frombits(bits)
+	}
+
+	metadata, err := readWALString(r)
+	if err != nil {
+		return nil, err
+	}
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &rec.Vector.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	// Version 1 put records end here
+	if rec.Op != WALPut || version == 1 {
+		return rec, nil
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if flags&^(walText|walSparse|walVectors|walMultiVectors) != 0 {
+		return nil, errors.New("unknown vector parts")
+	}
+	if flags&walText != 0 {
+		if rec.Vector.Text, err = readWALString(r); err != nil {
+			return nil, err
+		}
+	}
+	if flags&walSparse != 0 {
+		n, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		if int(n)*8 > r.Len() {
+			return nil, errors.New("sparse vector exceeds record")
+		}
+		rec.Vector.Sparse = &types.SparseVector{
+			Indices: make([]uint32, n),
+			Values:  make([]float32, n),
+		}
+		for i := 0; i < int(n); i++ {
+			if rec.Vector.Sparse.Indices[i], err = readUint32(r); err != nil {
+				return nil, err
+			}
+			bits, err := readUint32(r)
+			if err != nil {
+				return nil, err
+			}
+			rec.Vector.Sparse.Values[i] = math.Float32frombits(bits)
+		}
+	}
+	if flags&walVectors != 0 {
+		count, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.Vector.Vectors = make(map[string][]float32, count)
+		for i := uint32(0); i < count; i++ {
+			name, err := readWALString(r)
+			if err != nil {
+				return nil, err
+			}
+			dim, err := readUint32(r)
+			if err != nil {
+				return nil, err
+			}
+			if int(dim)*4 > r.Len() {
+				return nil, errors.New("named embedding exceeds record")
+			}
+			embedding := make([]float32, dim)
+			for j := range embedding {
+				bits, err := readUint32(r)
+				if err != nil {
+					return nil, err
+				}
+				embedding[j] = math.Float32frombits(bits)
+			}
+			rec.Vector.Vectors[name] = embedding
+		}
+	}
+	if flags&walMultiVectors != 0 {
+		count, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.Vector.MultiVectors = make(map[string][][]float32, count)
+		for i := uint32(0); i < count; i++ {
+			name, err := readWALString(r)
+			if err != nil {
+				return nil, err
+			}
+			n, err := readUint32(r)
+			if err != nil {
+				return nil, err
+			}
+			if int(n)*4 > r.Len() {
+				return nil, errors.New("multi-vector exceeds record")
+			}
+			tokens := make([][]float32, n)
+			for t := range tokens {
+				dim, err := readUint32(r)
+				if err != nil {
+					return nil, err
+				}
+				if int(dim)*4 > r.Len() {
+					return nil, errors.New("multi-vector exceeds record")
+				}
+				tokens[t] = make([]float32, dim)
+				for j := range tokens[t] {
+					bits, err := readUint32(r)
+					if err != nil {
+						return nil, err
+					}
+					tokens[t][j] = math.Float32frombits(bits)
+				}
+			}
+			rec.Vector.MultiVectors[name] = tokens
+		}
+	}
+	return rec, nil
+}
+
+func writeWALString(buf *bytes.Buffer, s string) {
+	writeUint32(buf, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func readWALString(r *bytes.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if int(n) > r.Len() {
+		return "", errors.New("string exceeds record")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
@@ -0,0 +1,202 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ishaan29/vectorDB/pkg/types"
+)
+
+func collectWAL(t *testing.T, w *WAL, since uint64) []*WALRecord {
+	t.Helper()
+	var records []*WALRecord
+	if err := w.Replay(since, func(rec *WALRecord) error {
+		records = append(records, rec)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	return records
+}
+
+func TestWALAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, err := OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+
+	lsn, err := w.Append(
+		&WALRecord{Op: WALPut, Collection: "default", Vector: types.Vector{
+			ID:           "a",
+			Embedding:    []float32{1, 2, 3},
+			Metadata:     map[string]interface{}{"lang": "go"},
+			Text:         "func main()",
+			Sparse:       &types.SparseVector{Indices: []uint32{7, 30521}, Values: []float32{0.5, 1.25}},
+			Vectors:      map[string][]float32{"docstring": {4, 5}, "code": {6}},
+			MultiVectors: map[string][][]float32{"tokens": {{1, 0}, {0, 1}, {7, 8}}},
+		}},
+		&WALRecord{Op: WALDelete, Collection: "code", Vector: types.Vector{ID: "b"}},
+		&WALRecord{Op: WALMetadata, Collection: "default", Vector: types.Vector{
+			ID:       "a",
+			Metadata: map[string]interface{}{"lang": "rust"},
+		}},
+	)
+	if err != nil || lsn != 3 {
+		t.Fatalf("Expected LSN 3, got %d (err %v)", lsn, err)
+	}
+	w.Close()
+
+	w, err = OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer w.Close()
+
+	records := collectWAL(t, w, 0)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	put := records[0]
+	if put.LSN != 1 || put.Op != WALPut || put.Vector.ID != "a" || len(put.Vector.Embedding) != 3 || put.Vector.Metadata["lang"] != "go" || put.Vector.Text != "func main()" ||
+		put.Vector.Sparse == nil || put.Vector.Sparse.Indices[1] != 30521 || put.Vector.Sparse.Values[1] != 1.25 ||
+		len(put.Vector.Vectors) != 2 || put.Vector.Vectors["docstring"][1] != 5 || put.Vector.Vectors["code"][0] != 6 ||
+		len(put.Vector.MultiVectors["tokens"]) != 3 || put.Vector.MultiVectors["tokens"][2][1] != 8 {
+		t.Errorf("Put record not restored: %+v", put)
+	}
+	if del := records[1]; del.LSN != 2 || del.Op != WALDelete || del.Collection != "code" || del.Vector.ID != "b" {
+		t.Errorf("Delete record not restored: %+v", del)
+	}
+	if md := records[2]; md.Op != WALMetadata || len(md.Vector.Embedding) != 0 || md.Vector.Metadata["lang"] != "rust" {
+		t.Errorf("Metadata record not restored: %+v", md)
+	}
+	if got := collectWAL(t, w, 2); len(got) != 1 || got[0].LSN != 3 {
+		t.Errorf("Expected replay since 2 to return only LSN 3, got %d records", len(got))
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, _ := OpenWAL(path, nil)
+	w.Append(&WALRecord{Op: WALPut, Collection: "default", Vector: types.Vector{ID: "a", Embedding: []float32{1}}})
+	w.Append(&WALRecord{Op: WALPut, Collection: "default", Vector: types.Vector{ID: "b", Embedding: []float32{2}}})
+	size := w.Size()
+	w.Close()
+
+	// Cut the last record in half, as a crash during the write would
+	if err := os.Truncate(path, size-5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	w, err := OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer w.Close()
+
+	if w.LastLSN() != 1 {
+		t.Errorf("Expected last LSN 1 after torn tail, got %d", w.LastLSN())
+	}
+	lsn, _ := w.Append(&WALRecord{Op: WALDelete, Collection: "default", Vector: types.Vector{ID: "a"}})
+	if lsn != 2 {
+		t.Errorf("Expected next LSN 2, got %d", lsn)
+	}
+	if records := collectWAL(t, w, 0); len(records) != 2 || records[1].Op != WALDelete {
+		t.Errorf("Expected the torn record to be replaced, got %d records", len(records))
+	}
+	size = w.Size()
+	w.Close()
+
+	// A garbage frame claiming a huge record is cut off without reading it
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0xf0, 0xff, 0xff, 0xff, 1, 2, 3, 4, 5, 6})
+	f.Close()
+	w, err = OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer w.Close()
+	if w.LastLSN() != 2 || w.Size() != size {
+		t.Errorf("Expected the garbage cut off after LSN 2, got LSN %d and %d bytes", w.LastLSN(), w.Size())
+	}
+}
+
+func TestWALTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, _ := OpenWAL(path, nil)
+	for _, id := range []string{"a", "b", "c"} {
+		w.Append(&WALRecord{Op: WALDelete, Collection: "default", Vector: types.Vector{ID: id}})
+	}
+
+	if err := w.Truncate(2); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if records := collectWAL(t, w, 0); len(records) != 1 || records[0].Vector.ID != "c" {
+		t.Errorf("Expected only record c to survive, got %d records", len(records))
+	}
+	w.Close()
+
+	// Numbering continues past the truncated records after a reopen
+	w, _ = OpenWAL(path, nil)
+	defer w.Close()
+	if err := w.Truncate(3); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	lsn, _ := w.Append(&WALRecord{Op: WALDelete, Collection: "default", Vector: types.Vector{ID: "d"}})
+	if lsn != 4 {
+		t.Errorf("Expected LSN 4 after truncating everything, got %d", lsn)
+	}
+}
+
+func TestWALUpgrade(t *testing.T) {
+	// A version 1 log with a put, whose record ends after the metadata
+	var payload bytes.Buffer
+	binary.Write(&payload, binary.LittleEndian, uint64(1))
+	payload.WriteByte(byte(WALPut))
+	writeWALString(&payload, "default")
+	writeWALString(&payload, "a")
+	writeUint32(&payload, 2)
+	writeUint32(&payload, math.Float32bits(1))
+	writeUint32(&payload, math.Float32bits(2))
+	writeWALString(&payload, `{"lang":"go"}`)
+
+	var log bytes.Buffer
+	binary.Write(&log, binary.LittleEndian, walHeader{Magic: walMagic, Version: 1})
+	writeUint32(&log, uint32(payload.Len()))
+	writeUint32(&log, crc32.ChecksumIEEE(payload.Bytes()))
+	log.Write(payload.Bytes())
+
+	path := filepath.Join(t.TempDir(), "wal.log")
+	if err := os.WriteFile(path, log.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	w, err := OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	lsn, err := w.Append(&WALRecord{Op: WALPut, Collection: "default", Vector: types.Vector{ID: "b", Embedding: []float32{3, 4}, Text: "two"}})
+	if err != nil || lsn != 2 {
+		t.Fatalf("Expected LSN 2, got %d (err %v)", lsn, err)
+	}
+	w.Close()
+
+	// The log was rewritten in the current format, old record included
+	w, err = OpenWAL(path, nil)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer w.Close()
+	if w.version != walVersion {
+		t.Errorf("Expected version %d, got %d", walVersion, w.version)
+	}
+	records := collectWAL(t, w, 0)
+	if len(records) != 2 || records[0].Vector.Embedding[1] != 2 || records[0].Vector.Metadata["lang"] != "go" || records[1].Vector.Text != "two" {
+		t.Errorf("Expected both puts back, got %d records", len(records))
+	}
+}
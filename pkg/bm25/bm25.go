@@ -0,0 +1,61 @@
+package bm25
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Params are the free parameters of Okapi BM25
+type Params struct {
+	K1 float64 // Term frequency saturation
+	B  float64 // Document length normalization, from 0 (none) to 1 (full)
+}
+
+// DefaultParams are the values most search engines ship with
+var DefaultParams = Params{K1: 1.2, B: 0.75}
+
+// Tokenize splits text into lowercase terms. A term is a run of letters,
+// digits and underscores, so identifiers like parse_config or ErrNotFound
+// stay whole and match exactly, while punctuation such as the dot in
+// os.Open separates terms.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for i, f := range fields {
+		fields[i] = strings.ToLower(f)
+	}
+	return fields
+}
+
+// TermFrequencies counts the occurrences of each term of text and returns
+// them with the total number of terms.
+func TermFrequencies(text string) (map[string]int, int) {
+	terms := Tokenize(text)
+	tf := make(map[string]int, len(terms))
+	for _, t := range terms {
+		tf[t]++
+	}
+	return tf, len(terms)
+}
+
+// IDF is the inverse document frequency of a term found in df of docs
+// documents. It is never negative, even for terms in most documents.
+func IDF(df, docs int) float64 {
+	return math.Log(1 + (float64(docs)-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// Score is the contribution of one query term that occurs tf times in a
+// document of docLen terms, when documents average avgDocLen terms.
+func (p Params) Score(idf float64, tf, docLen int, avgDocLen float64) float64 {
+	if tf == 0 {
+		return 0
+	}
+	norm := 1 - p.B
+	if avgDocLen > 0 {
+		norm += p.B * float64(docLen) / avgDocLen
+	}
+	f := float64(tf)
+	return idf * f * (p.K1 + 1) / (f + p.K1*norm)
+}
@@ -0,0 +1,50 @@
+package bm25
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"func parse_config() error", []string{"func", "parse_config", "error"}},
+		{"err := os.Open(path) // ErrNotFound", []string{"err", "os", "open", "path", "errnotfound"}},
+		{"connection refused: 127.0.0.1:5432", []string{"connection", "refused", "127", "0", "0", "1", "5432"}},
+		{"  ", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	p := DefaultParams
+
+	// Rare terms weigh more than common ones
+	if IDF(1, 100) <= IDF(50, 100) {
+		t.Errorf("Expected a rare term to have a higher IDF")
+	}
+	if IDF(100, 100) < 0 {
+		t.Errorf("Expected IDF to stay non-negative")
+	}
+
+	idf := IDF(5, 100)
+	if p.Score(idf, 0, 10, 10) != 0 {
+		t.Errorf("Expected an absent term to score 0")
+	}
+	// More occurrences score higher but saturate
+	one, two, many := p.Score(idf, 1, 10, 10), p.Score(idf, 2, 10, 10), p.Score(idf, 100, 10, 10)
+	if !(one < two && two < many && many < idf*(p.K1+1)) {
+		t.Errorf("Expected saturating term frequency, got %v %v %v", one, two, many)
+	}
+	// Shorter documents score higher for the same frequency
+	if p.Score(idf, 1, 5, 10) <= p.Score(idf, 1, 20, 10) {
+		t.Errorf("Expected length normalization to favour short documents")
+	}
+}
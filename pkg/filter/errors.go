@@ -0,0 +1,22 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrEmptyFilter is returned when a filter node sets neither a condition nor a combinator
+	ErrEmptyFilter = errors.New("filter must set a field condition, and, or, or not")
+
+	// ErrMixedFilter is returned when a filter node sets more than one of condition, and, or, not
+	ErrMixedFilter = errors.New("filter must set exactly one of a field condition, and, or, or not")
+)
+
+func ErrUnknownOperator(op Op) error {
+	return fmt.Errorf("unknown filter operator %q", op)
+}
+
+func ErrInvalidValue(op Op, reason string) error {
+	return fmt.Errorf("invalid value for filter operator %q: %s", op, reason)
+}
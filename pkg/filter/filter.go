@@ -0,0 +1,270 @@
+package filter
+
+import (
+	"strings"
+)
+
+// Op is a comparison operator applied to a single metadata field
+type Op string
+
+const (
+	OpEq     Op = "eq"
+	OpNe     Op = "ne"
+	OpGt     Op = "gt"
+	OpGte    Op = "gte"
+	OpLt     Op = "lt"
+	OpLte    Op = "lte"
+	OpIn     Op = "in"
+	OpExists Op = "exists"
+)
+
+// Filter is a boolean expression over vector metadata. A node is either a
+// field condition (Field, Op, Value) or exactly one of And, Or and Not.
+// Field may use dots to reach into nested objects, e.g. "author.name".
+//
+//	{"and": [
+//	  {"field": "lang", "op": "eq", "value": "go"},
+//	  {"not": {"field": "stars", "op": "lt", "value": 10}}
+//	]}
+type Filter struct {
+	And []*Filter `json:"and,omitempty"`
+	Or  []*Filter `json:"or,omitempty"`
+	Not *Filter   `json:"not,omitempty"`
+
+	Field string      `json:"field,omitempty"`
+	Op    Op          `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Validate checks the filter is well formed so that Match never has to
+// report errors.
+func (f *Filter) Validate() error {
+	set := 0
+	if f.Field != "" || f.Op != "" {
+		set++
+	}
+	if f.And != nil {
+		set++
+	}
+	if f.Or != nil {
+		set++
+	}
+	if f.Not != nil {
+		set++
+	}
+	if set == 0 {
+		return ErrEmptyFilter
+	}
+	if set > 1 {
+		return ErrMixedFilter
+	}
+
+	for _, list := range [][]*Filter{f.And, f.Or} {
+		for _, sub := range list {
+			if sub == nil {
+				return ErrEmptyFilter
+			}
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	if f.Not != nil {
+		return f.Not.Validate()
+	}
+	if f.Field == "" && f.Op != "" {
+		return ErrInvalidValue(f.Op, "missing field")
+	}
+	if f.Field != "" {
+		return f.validateCondition()
+	}
+	return nil
+}
+
+func (f *Filter) validateCondition() error {
+	switch f.Op {
+	case OpEq, OpNe:
+		return nil
+	case OpGt, OpGte, OpLt, OpLte:
+		if _, ok := toFloat(f.Value); ok {
+			return nil
+		}
+		if _, ok := f.Value.(string); ok {
+			return nil
+		}
+		return ErrInvalidValue(f.Op, "expected a number or string")
+	case OpIn:
+		if _, ok := f.Value.([]interface{}); !ok {
+			return ErrInvalidValue(f.Op, "expected an array")
+		}
+		return nil
+	case OpExists:
+		return nil
+	default:
+		return ErrUnknownOperator(f.Op)
+	}
+}
+
+// Match reports whether metadata satisfies the filter. A nil filter
+// matches everything.
+func (f *Filter) Match(metadata map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+
+	switch {
+	case f.And != nil:
+		for _, sub := range f.And {
+			if !sub.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	case f.Or != nil:
+		for _, sub := range f.Or {
+			if sub.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	case f.Not != nil:
+		return !f.Not.Match(metadata)
+	}
+
+	value, found := lookup(metadata, f.Field)
+	switch f.Op {
+	case OpExists:
+		return found
+	case OpNe:
+		return !found || !matchesAny(value, func(v interface{}) bool { return equal(v, f.Value) })
+	}
+	if !found {
+		return false
+	}
+
+	switch f.Op {
+	case OpEq:
+		return matchesAny(value, func(v interface{}) bool { return equal(v, f.Value) })
+	case OpIn:
+		return matchesAny(value, func(v interface{}) bool {
+			for _, candidate := range f.Value.([]interface{}) {
+				if equal(v, candidate) {
+					return true
+				}
+			}
+			return false
+		})
+	case OpGt:
+		return matchesAny(value, func(v interface{}) bool { c, ok := compare(v, f.Value); return ok && c > 0 })
+	case OpGte:
+		return matchesAny(value, func(v interface{}) bool { c, ok := compare(v, f.Value); return ok && c >= 0 })
+	case OpLt:
+		return matchesAny(value, func(v interface{}) bool { c, ok := compare(v, f.Value); return ok && c < 0 })
+	case OpLte:
+		return matchesAny(value, func(v interface{}) bool { c, ok := compare(v, f.Value); return ok && c <= 0 })
+	}
+	return false
+}
+
+// lookup resolves a dotted field path inside nested metadata objects
+func lookup(metadata map[string]interface{}, field string) (interface{}, bool) {
+	if value, ok := metadata[field]; ok {
+		return value, true
+	}
+
+	parts := strings.Split(field, ".")
+	current := metadata
+	for i, part := range parts {
+		value, ok := current[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return value, true
+		}
+		if current, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// matchesAny applies pred to a scalar, or to each element of an array so
+// that e.g. {"field": "tags", "op": "eq", "value": "go"} matches ["go", "rust"].
+func matchesAny(value interface{}, pred func(interface{}) bool) bool {
+	if list, ok := value.([]interface{}); ok {
+		for _, v := range list {
+			if pred(v) {
+				return true
+			}
+		}
+		return false
+	}
+	return pred(value)
+}
+
+func equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case nil:
+		return b == nil
+	}
+	return false
+}
+
+// compare orders two numbers or two strings; ok is false for any other pair
+func compare(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(sa, sb), true
+	}
+	return 0, false
+}
+
+// toFloat normalizes JSON (float64) and Go-native numeric metadata values
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	metadata := map[string]interface{}{
+		"lang":  "go",
+		"stars": float64(42),
+		"tags":  []interface{}{"db", "vector"},
+		"author": map[string]interface{}{
+			"name": "ishaan",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   bool
+	}{
+		{"eq", `{"field": "lang", "op": "eq", "value": "go"}`, true},
+		{"eq mismatch", `{"field": "lang", "op": "eq", "value": "rust"}`, false},
+		{"ne missing field", `{"field": "license", "op": "ne", "value": "mit"}`, true},
+		{"gte", `{"field": "stars", "op": "gte", "value": 42}`, true},
+		{"lt", `{"field": "stars", "op": "lt", "value": 10}`, false},
+		{"in", `{"field": "lang", "op": "in", "value": ["go", "rust"]}`, true},
+		{"eq array element", `{"field": "tags", "op": "eq", "value": "vector"}`, true},
+		{"exists", `{"field": "stars", "op": "exists"}`, true},
+		{"exists missing", `{"field": "license", "op": "exists"}`, false},
+		{"nested field", `{"field": "author.name", "op": "eq", "value": "ishaan"}`, true},
+		{"and", `{"and": [{"field": "lang", "op": "eq", "value": "go"}, {"field": "stars", "op": "gt", "value": 100}]}`, false},
+		{"or", `{"or": [{"field": "lang", "op": "eq", "value": "rust"}, {"field": "stars", "op": "gt", "value": 10}]}`, true},
+		{"not", `{"not": {"field": "lang", "op": "eq", "value": "go"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f Filter
+			if err := json.Unmarshal([]byte(tt.filter), &f); err != nil {
+				t.Fatalf("Failed to decode filter: %v", err)
+			}
+			if err := f.Validate(); err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			if got := f.Match(metadata); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	invalid := []string{
+		`{}`,
+		`{"field": "lang", "op": "like", "value": "go"}`,
+		`{"field": "lang", "op": "in", "value": "go"}`,
+		`{"field": "stars", "op": "gt", "value": true}`,
+		`{"field": "lang", "op": "eq", "value": "go", "not": {"field": "x", "op": "exists"}}`,
+	}
+
+	for _, raw := range invalid {
+		var f Filter
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			t.Fatalf("Failed to decode filter: %v", err)
+		}
+		if err := f.Validate(); err == nil {
+			t.Errorf("Expected %s to be invalid", raw)
+		}
+	}
+}
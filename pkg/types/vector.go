@@ -10,9 +10,21 @@ var Log logger.Logger
 
 // In mem representation of a vector database
 type Vector struct {
-	ID        string                 `json:"id"`
-	Embedding []float32              `json:"embedding"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ID           string                 `json:"id"`
+	Embedding    []float32              `json:"embedding"`
+	Vectors      map[string][]float32   `json:"vectors,omitempty"`      // Named embeddings, each with its own index
+	MultiVectors map[string][][]float32 `json:"multivectors,omitempty"` // Named bags of token embeddings, scored by MaxSim
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Text         string                 `json:"text,omitempty"`   // Indexed for keyword search
+	Sparse       *SparseVector          `json:"sparse,omitempty"` // Indexed for sparse dot-product search
+}
+
+// SparseVector holds the non-zero dimensions of a sparse embedding, such as
+// the term weights of a SPLADE model. Indices are unique and pair up with
+// Values.
+type SparseVector struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
 }
 
 type SearchResult struct {
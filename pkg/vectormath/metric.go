@@ -0,0 +1,70 @@
+package vectormath
+
+import (
+	"fmt"
+	"math"
+)
+
+// Metric names a distance function. Every metric produces a distance where
+// smaller means closer, and a score where larger means more similar.
+type Metric string
+
+const (
+	Cosine     Metric = "cosine"
+	Euclidean  Metric = "euclidean"
+	DotProduct Metric = "dot"
+	Manhattan  Metric = "manhattan"
+)
+
+// ParseMetric validates a metric name; the empty string selects cosine
+func ParseMetric(name string) (Metric, error) {
+	switch m := Metric(name); m {
+	case "":
+		return Cosine, nil
+	case Cosine, Euclidean, DotProduct, Manhattan:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown distance metric %q", name)
+}
+
+// Distance computes the distance between two vectors under the metric.
+// Dot product distance is the negated inner product.
+func (m Metric) Distance(v1, v2 []float32) (float32, error) {
+	switch m {
+	case Euclidean:
+		return EuclideanDistance(v1, v2)
+	case DotProduct:
+		dot, err := Dot(v1, v2)
+		return -dot, err
+	case Manhattan:
+		return ManhattanDistance(v1, v2)
+	default:
+		return CosineDistance(v1, v2)
+	}
+}
+
+// Score converts a distance into a similarity score:
+//   - cosine: the cosine similarity, in [-1, 1]
+//   - dot: the inner product
+//   - euclidean, manhattan: 1 / (1 + distance), in (0, 1]
+func (m Metric) Score(distance float32) float32 {
+	switch m {
+	case DotProduct:
+		return -distance
+	case Euclidean, Manhattan:
+		return 1 / (1 + distance)
+	default:
+		return 1 - distance
+	}
+}
+
+// MaxDistance is the distance reported when it is undefined, e.g. the
+// cosine distance to a zero vector
+func (m Metric) MaxDistance() float32 {
+	switch m {
+	case Euclidean, Manhattan, DotProduct:
+		return math.MaxFloat32
+	default:
+		return 2.0
+	}
+}
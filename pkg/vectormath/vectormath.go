@@ -80,3 +80,17 @@ func NormalizeVector(v []float32) error {
 	}
 	return nil
 }
+
+// ManhattanDistance calculates the L1 (taxicab) distance between two vectors
+func ManhattanDistance(v1, v2 []float32) (float32, error) {
+	if len(v1) != len(v2) {
+		logger.Error("Dimension mismatch in ManhattanDistance", ErrDimensionMismatch)
+		return 0, ErrDimensionMismatch
+	}
+
+	var sum float32
+	for i := 0; i < len(v1); i++ {
+		sum += float32(math.Abs(float64(v1[i] - v2[i])))
+	}
+	return sum, nil
+}
@@ -2,14 +2,23 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ishaan29/vectorDB/internal/config"
 	"github.com/ishaan29/vectorDB/internal/engine"
 	"github.com/ishaan29/vectorDB/internal/logger"
+	"github.com/ishaan29/vectorDB/persistence"
+	"github.com/ishaan29/vectorDB/pkg/filter"
 	"github.com/ishaan29/vectorDB/pkg/types"
+	"github.com/ishaan29/vectorDB/pkg/vectormath"
 )
 
 func TestCompleteFlow(t *testing.T) {
@@ -76,7 +85,6 @@ func TestCompleteFlow(t *testing.T) {
 		// Search
 		results, err := eng.Search(vectors[0], engine.SearchParams{
 			K:           3,
-			Threshold:   0.0,
 			IncludeVecs: false,
 			IncludeMeta: true,
 		})
@@ -123,6 +131,1185 @@ func TestCompleteFlow(t *testing.T) {
 			t.Errorf("Wrong vector retrieved")
 		}
 	})
+
+	// Test 4: Filtered search returns K matches
+	t.Run("FilteredSearch", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		for i := 0; i < 20; i++ {
+			eng.Insert(types.Vector{
+				ID:        fmt.Sprintf("filtered%d", i),
+				Embedding: generateRandomVector(128),
+				Metadata:  map[string]interface{}{"group": i % 4},
+			})
+		}
+
+		results, err := eng.Search(types.Vector{Embedding: generateRandomVector(128)}, engine.SearchParams{
+			K:           5,
+			IncludeMeta: true,
+			Filter:      &filter.Filter{Field: "group", Op: filter.OpEq, Value: 3},
+		})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+
+		if len(results) != 5 {
+			t.Fatalf("Expected 5 results, got %d", len(results))
+		}
+		for _, r := range results {
+			if r.Vector.Metadata["group"] != float64(3) {
+				t.Errorf("Result %s does not match filter: %v", r.Vector.ID, r.Vector.Metadata)
+			}
+		}
+	})
+
+	t.Run("BatchSearch", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, _ := eng.Collection(engine.DefaultCollection)
+		queries := make([]engine.BatchQuery, 0, 50)
+		for i := 0; i < 50; i++ {
+			params := engine.SearchParams{K: 1 + i%7, IncludeMeta: true}
+			if i%2 == 0 {
+				params.Filter = &filter.Filter{Field: "group", Op: filter.OpEq, Value: i % 4}
+			}
+			queries = append(queries, engine.BatchQuery{Query: types.Vector{Embedding: generateRandomVector(128)}, Params: params})
+		}
+		queries = append(queries, engine.BatchQuery{Query: types.Vector{Embedding: generateRandomVector(3)}, Params: engine.SearchParams{K: 1}})
+
+		batch := coll.SearchBatch(queries)
+		if len(batch) != len(queries) {
+			t.Fatalf("Expected %d results, got %d", len(queries), len(batch))
+		}
+		for i, q := range queries[:50] {
+			want, err := coll.Search(q.Query, q.Params)
+			if err != nil || batch[i].Err != nil {
+				t.Fatalf("Query %d failed: %v / %v", i, err, batch[i].Err)
+			}
+			if fmt.Sprint(batch[i].Results) != fmt.Sprint(want) {
+				t.Errorf("Query %d returned %v, want %v", i, batch[i].Results, want)
+			}
+		}
+		if batch[50].Err == nil {
+			t.Errorf("Expected the query of the wrong dimension to fail on its own")
+		}
+	})
+
+	t.Run("Recommend", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "recommend", Dimensions: 4, Metric: "euclidean"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		// Two clusters along the first axis: a0..a9 around 0 and b0..b9 around 10
+		for i := 0; i < 10; i++ {
+			coll.Insert(types.Vector{ID: fmt.Sprintf("a%d", i), Embedding: []float32{float32(i) / 10, 0, 0, 0}})
+			coll.Insert(types.Vector{ID: fmt.Sprintf("b%d", i), Embedding: []float32{10 + float32(i)/10, 0, 0, 0}})
+		}
+
+		results, err := coll.Recommend(engine.RecommendQuery{Positive: []string{"a0", "a1"}, Negative: []string{"b0"}},
+			engine.SearchParams{K: 3, Exact: true})
+		if err != nil {
+			t.Fatalf("Recommend failed: %v", err)
+		}
+		if ids := resultIDs(results); fmt.Sprint(ids) != "[a2 a3 a4]" {
+			t.Errorf("Expected [a2 a3 a4] away from b0 without the examples, got %v", ids)
+		}
+
+		zero := float32(0)
+		results, err = coll.Recommend(engine.RecommendQuery{Positive: []string{"a5"}, Negative: []string{"a9"}, Strategy: engine.RecommendBestScore},
+			engine.SearchParams{K: 8, Threshold: &zero, Exact: true})
+		if err != nil {
+			t.Fatalf("Recommend failed: %v", err)
+		}
+		ids := resultIDs(results)
+		// a8 and the b cluster are closer to a9 than to a5, so they score
+		// below zero and the threshold drops them
+		if len(ids) != 7 || (ids[0] != "a4" && ids[0] != "a6") || ids[6] != "a0" {
+			t.Errorf("Expected a0..a7 but a5 by distance to a5, got %v", ids)
+		}
+		results, _ = coll.Recommend(engine.RecommendQuery{Positive: []string{"a5"}, Negative: []string{"a9"}, Strategy: engine.RecommendBestScore},
+			engine.SearchParams{K: 18, Exact: true})
+		if ids := resultIDs(results); len(ids) != 18 || ids[7] != "b9" || ids[17] != "a8" {
+			t.Errorf("Expected the vectors closer to a9 last, a8 the very last, got %v", ids)
+		}
+
+		// Dot products can be negative, so the penalized candidates can
+		// score above zero; they still rank last and least like n first
+		dot, err := eng.CreateCollection(engine.CollectionConfig{Name: "recommend-dot", Dimensions: 2, Metric: "dot"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		dot.BatchInsert([]types.Vector{
+			{ID: "p", Embedding: []float32{1, 0}},
+			{ID: "n", Embedding: []float32{0, 1}},
+			{ID: "x1", Embedding: []float32{2, 0}},
+			{ID: "x2", Embedding: []float32{1, 0.5}},
+			{ID: "y", Embedding: []float32{-1, -1}},
+			{ID: "z1", Embedding: []float32{-3, -1}},
+			{ID: "z2", Embedding: []float32{-3, -2}},
+			{ID: "w", Embedding: []float32{0, 3}},
+		})
+		results, _ = dot.Recommend(engine.RecommendQuery{Positive: []string{"p"}, Negative: []string{"n"}, Strategy: engine.RecommendBestScore},
+			engine.SearchParams{K: 6, Exact: true})
+		if ids := fmt.Sprint(resultIDs(results)); ids != "[x1 x2 y z2 z1 w]" || results[3].Score != 2 || results[5].Score != -3 {
+			t.Errorf("Expected [x1 x2 y z2 z1 w] with z2 scoring 2 and w -3, got %s", ids)
+		}
+
+		if _, err := coll.Recommend(engine.RecommendQuery{Positive: []string{"missing"}}, engine.SearchParams{K: 1}); !errors.Is(err, engine.ErrVectorNotFound) {
+			t.Errorf("Expected ErrVectorNotFound for an unknown example, got %v", err)
+		}
+		if _, err := coll.Recommend(engine.RecommendQuery{Negative: []string{"a1"}}, engine.SearchParams{K: 1}); !errors.Is(err, engine.ErrNoPositiveExamples) {
+			t.Errorf("Expected ErrNoPositiveExamples, got %v", err)
+		}
+	})
+
+	t.Run("MMR", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "mmr", Dimensions: 2, Metric: "euclidean"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		// Five near-duplicates of the query and two vectors unlike them
+		for i := 0; i < 5; i++ {
+			coll.Insert(types.Vector{ID: fmt.Sprintf("dup%d", i), Embedding: []float32{1, float32(i) / 1000}})
+		}
+		coll.Insert(types.Vector{ID: "diag", Embedding: []float32{0.7, 0.7}})
+		coll.Insert(types.Vector{ID: "up", Embedding: []float32{0, 1}})
+
+		query := types.Vector{Embedding: []float32{1, 0}}
+		countDups := func(results []types.SearchResult) int {
+			dups := 0
+			for _, r := range results {
+				if strings.HasPrefix(r.Vector.ID, "dup") {
+					dups++
+				}
+			}
+			return dups
+		}
+
+		plain, _ := coll.Search(query, engine.SearchParams{K: 3, Exact: true})
+		if countDups(plain) != 3 {
+			t.Fatalf("Expected only near-duplicates without MMR, got %v", resultIDs(plain))
+		}
+
+		diverse, err := coll.Search(query, engine.SearchParams{K: 3, Exact: true, MMR: &engine.MMRParams{Lambda: 0.3}})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(diverse) != 3 || diverse[0].Vector.ID != "dup0" || countDups(diverse) != 1 {
+			t.Errorf("Expected dup0 then the dissimilar vectors, got %v", resultIDs(diverse))
+		}
+		if diverse[0].Vector.Embedding != nil {
+			t.Errorf("Expected embeddings to be left out")
+		}
+
+		// MMR can only pick from the fetched candidates
+		narrow, _ := coll.Search(query, engine.SearchParams{K: 3, Exact: true, MMR: &engine.MMRParams{Lambda: 0.3, FetchK: 5}})
+		if countDups(narrow) != 3 {
+			t.Errorf("Expected only near-duplicates among 5 candidates, got %v", resultIDs(narrow))
+		}
+
+		// Fused scores aren't on the scale of similarities MMR weighs them against
+		if _, err := coll.Search(query, engine.SearchParams{K: 3, Text: "dup", MMR: &engine.MMRParams{Lambda: 0.3}}); !errors.Is(err, engine.ErrHybridMMR) {
+			t.Errorf("Expected ErrHybridMMR with text, got %v", err)
+		}
+		sparse := query
+		sparse.Sparse = &types.SparseVector{Indices: []uint32{1}, Values: []float32{1}}
+		if _, err := coll.Search(sparse, engine.SearchParams{K: 3, MMR: &engine.MMRParams{Lambda: 0.3}}); !errors.Is(err, engine.ErrHybridMMR) {
+			t.Errorf("Expected ErrHybridMMR with a sparse vector, got %v", err)
+		}
+	})
+
+	t.Run("HybridSearch", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{Name: "docs", Dimensions: 2, Metric: "euclidean"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		docs := []types.Vector{
+			{ID: "near", Embedding: []float32{0, 0}, Text: "opening files and directories"},
+			{ID: "close", Embedding: []float32{0.1, 0}, Text: "reading configuration"},
+			{ID: "far", Embedding: []float32{9, 9}, Text: "return ErrNotFound when the key is missing", Metadata: map[string]interface{}{"lang": "go"}},
+			{ID: "farther", Embedding: []float32{10, 10}, Text: "unrelated words"},
+		}
+		if _, err := coll.BatchInsert(docs); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		eng1.Stop()
+
+		// Text is logged and stored, so it survives a restart
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+		coll, _ = eng2.Collection("docs")
+		if v, ok := coll.Get("far"); !ok || v.Text != docs[2].Text {
+			t.Fatalf("Expected text to be restored, got %q", v.Text)
+		}
+
+		query := types.Vector{Embedding: []float32{0, 0}}
+		plain, _ := coll.Search(query, engine.SearchParams{K: 2})
+		if ids := fmt.Sprint(resultIDs(plain)); ids != "[near close]" {
+			t.Fatalf("Expected [near close] from the embedding alone, got %s", ids)
+		}
+
+		keyword, err := coll.Search(types.Vector{}, engine.SearchParams{K: 2, Text: "ErrNotFound"})
+		if err != nil || len(keyword) != 1 || keyword[0].Vector.ID != "far" || keyword[0].Score <= 0 {
+			t.Fatalf("Expected far alone with a BM25 score, got %v (err %v)", keyword, err)
+		}
+
+		hybrid, err := coll.Search(query, engine.SearchParams{K: 2, Text: "ErrNotFound", IncludeMeta: true})
+		if err != nil {
+			t.Fatalf("Hybrid search failed: %v", err)
+		}
+		if ids := fmt.Sprint(resultIDs(hybrid)); ids != "[far near]" {
+			t.Errorf("Expected the keyword match fused in first, got %s", ids)
+		}
+		if hybrid[0].Vector.Metadata["lang"] != "go" || hybrid[0].Distance == 0 {
+			t.Errorf("Expected the keyword-only match hydrated with its distance, got %+v", hybrid[0])
+		}
+
+		weighted, _ := coll.Search(query, engine.SearchParams{K: 2, Text: "ErrNotFound",
+			Fusion: &engine.FusionParams{Method: engine.FusionWeighted, Alpha: 1}})
+		if ids := fmt.Sprint(resultIDs(weighted)); ids != "[near close]" {
+			t.Errorf("Expected alpha 1 to rank by vector score only, got %s", ids)
+		}
+
+		filtered, _ := coll.Search(types.Vector{}, engine.SearchParams{K: 2, Text: "ErrNotFound",
+			Filter: &filter.Filter{Field: "lang", Op: filter.OpEq, Value: "rust"}})
+		if len(filtered) != 0 {
+			t.Errorf("Expected the filter to apply to keyword matches, got %v", resultIDs(filtered))
+		}
+
+		coll.Delete("far")
+		if after, _ := coll.Search(types.Vector{}, engine.SearchParams{K: 2, Text: "ErrNotFound"}); len(after) != 0 {
+			t.Errorf("Expected deleted vectors to leave the keyword index, got %v", resultIDs(after))
+		}
+	})
+
+	t.Run("SparseSearch", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{Name: "splade", Dimensions: 2, Metric: "euclidean"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		sparse := func(indices []uint32, values []float32) *types.SparseVector {
+			return &types.SparseVector{Indices: indices, Values: values}
+		}
+		docs := []types.Vector{
+			{ID: "near", Embedding: []float32{0, 0}, Sparse: sparse([]uint32{10}, []float32{0.2})},
+			{ID: "close", Embedding: []float32{0.1, 0}},
+			{ID: "far", Embedding: []float32{9, 9}, Sparse: sparse([]uint32{10, 20000}, []float32{0.5, 2}), Metadata: map[string]interface{}{"lang": "go"}},
+		}
+		for _, doc := range docs {
+			if _, err := coll.Insert(doc); err != nil {
+				t.Fatalf("Failed to insert %s: %v", doc.ID, err)
+			}
+		}
+		if _, err := coll.Insert(types.Vector{ID: "bad", Embedding: []float32{0, 0}, Sparse: sparse([]uint32{1, 1}, []float32{1, 2})}); err == nil {
+			t.Errorf("Expected duplicate sparse indices to be rejected")
+		}
+		eng1.Stop()
+
+		// Sparse embeddings are logged and stored, so they survive a restart
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+		coll, _ = eng2.Collection("splade")
+		if v, ok := coll.Get("far"); !ok || v.Sparse == nil || v.Sparse.Indices[1] != 20000 {
+			t.Fatalf("Expected the sparse embedding to be restored, got %+v", v.Sparse)
+		}
+
+		query := sparse([]uint32{10, 20000}, []float32{1, 1})
+		only, err := coll.Search(types.Vector{Sparse: query}, engine.SearchParams{K: 3})
+		if err != nil {
+			t.Fatalf("Sparse search failed: %v", err)
+		}
+		if ids := fmt.Sprint(resultIDs(only)); ids != "[far near]" || only[0].Score != 2.5 {
+			t.Errorf("Expected [far near] scored by dot product, got %s (%v)", ids, only)
+		}
+
+		hybrid, err := coll.Search(types.Vector{Embedding: []float32{0, 0}, Sparse: query}, engine.SearchParams{K: 3, IncludeMeta: true})
+		if err != nil {
+			t.Fatalf("Hybrid search failed: %v", err)
+		}
+		// near ranks high in both rankings, while close is missing from the
+		// sparse one
+		if ids := fmt.Sprint(resultIDs(hybrid)); ids != "[near far close]" {
+			t.Errorf("Expected the rankings fused, got %s", ids)
+		}
+
+		filtered, _ := coll.Search(types.Vector{Sparse: query}, engine.SearchParams{K: 3,
+			Filter: &filter.Filter{Field: "lang", Op: filter.OpEq, Value: "go"}})
+		if ids := fmt.Sprint(resultIDs(filtered)); ids != "[far]" {
+			t.Errorf("Expected the filter to apply to sparse matches, got %s", ids)
+		}
+
+		coll.Delete("far")
+		if after, _ := coll.Search(types.Vector{Sparse: query}, engine.SearchParams{K: 3}); len(after) != 1 {
+			t.Errorf("Expected deleted vectors to leave the sparse index, got %v", resultIDs(after))
+		}
+	})
+
+	t.Run("NamedVectors", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		_, err := eng1.CreateCollection(engine.CollectionConfig{Name: "files", Dimensions: 2, Metric: "euclidean",
+			Vectors: map[string]engine.VectorConfig{"bad name!": {Dimensions: 3, Metric: "cosine"}}})
+		if err == nil {
+			t.Errorf("Expected an invalid vector name to be rejected")
+		}
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{Name: "files", Dimensions: 2, Metric: "euclidean",
+			Vectors: map[string]engine.VectorConfig{
+				"code":      {Dimensions: 3, Metric: "euclidean"},
+				"docstring": {Dimensions: 2, Metric: "cosine", IndexType: engine.IndexFlat},
+			}})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		if coll.Config().Vectors["code"].IndexType != engine.IndexHNSW {
+			t.Errorf("Expected named vectors to get the default index type, got %+v", coll.Config().Vectors)
+		}
+
+		files := []types.Vector{
+			{ID: "wal.go", Embedding: []float32{0, 0}, Metadata: map[string]interface{}{"lang": "go"},
+				Vectors: map[string][]float32{"code": {9, 9, 9}, "docstring": {1, 0}}},
+			{ID: "store.go", Embedding: []float32{1, 1}, Metadata: map[string]interface{}{"lang": "go"},
+				Vectors: map[string][]float32{"code": {0, 0, 0}, "docstring": {0, 1}}},
+			{ID: "main.rs", Embedding: []float32{5, 5}, Vectors: map[string][]float32{"code": {1, 0, 0}}},
+		}
+		if _, err := coll.BatchInsert(files); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if _, err := coll.Insert(types.Vector{ID: "x", Embedding: []float32{0, 0}, Vectors: map[string][]float32{"code": {1}}}); err == nil {
+			t.Errorf("Expected a named vector of the wrong dimension to be rejected")
+		}
+		if _, err := coll.Insert(types.Vector{ID: "x", Embedding: []float32{0, 0}, Vectors: map[string][]float32{"image": {1}}}); !errors.Is(err, engine.ErrNoSuchVector) {
+			t.Errorf("Expected ErrNoSuchVector for an unknown vector name, got %v", err)
+		}
+		if found, _ := coll.Search(types.Vector{Embedding: []float32{0, 0, 0}}, engine.SearchParams{K: 3, Using: "code"}); len(found) != 3 {
+			t.Errorf("Expected the batch indexed by name right away, got %v", resultIDs(found))
+		}
+		eng1.Stop()
+
+		// The named indexes are snapshotted and restored with the collection's
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+		coll, _ = eng2.Collection("files")
+		if v, ok := coll.Get("wal.go"); !ok || len(v.Vectors) != 2 {
+			t.Fatalf("Expected the named vectors to be restored, got %+v", v)
+		}
+
+		own, _ := coll.Search(types.Vector{Embedding: []float32{0, 0}}, engine.SearchParams{K: 1})
+		byCode, _ := coll.Search(types.Vector{Embedding: []float32{0, 0, 0}}, engine.SearchParams{K: 3, Using: "code", IncludeMeta: true})
+		byDoc, _ := coll.Search(types.Vector{Embedding: []float32{1, 0}}, engine.SearchParams{K: 3, Using: "docstring"})
+		if ids := fmt.Sprint(resultIDs(own), resultIDs(byCode), resultIDs(byDoc)); ids != "[wal.go] [store.go main.rs wal.go] [wal.go store.go]" {
+			t.Errorf("Expected each vector searched separately, got %s", ids)
+		}
+		if byCode[0].Vector.Metadata["lang"] != "go" {
+			t.Errorf("Expected the metadata shared across vectors, got %+v", byCode[0].Vector)
+		}
+		exact, _ := coll.Search(types.Vector{Embedding: []float32{0, 0, 0}}, engine.SearchParams{K: 3, Using: "code", Exact: true})
+		if fmt.Sprint(resultIDs(exact)) != fmt.Sprint(resultIDs(byCode)) {
+			t.Errorf("Expected the exact search to agree, got %v", resultIDs(exact))
+		}
+		if _, err := coll.Search(types.Vector{Embedding: []float32{0, 0}}, engine.SearchParams{K: 1, Using: "image"}); !errors.Is(err, engine.ErrNoSuchVector) {
+			t.Errorf("Expected ErrNoSuchVector when searching an unknown vector, got %v", err)
+		}
+
+		// Replacing a vector without a named vector drops it from that index
+		coll.Insert(types.Vector{ID: "wal.go", Embedding: []float32{0, 0}})
+		coll.Delete("store.go")
+		if ok, err := coll.Rebuild(nil, func() {}); !ok || err != nil {
+			t.Fatalf("Rebuild failed: %v", err)
+		}
+		byCode, _ = coll.Search(types.Vector{Embedding: []float32{0, 0, 0}}, engine.SearchParams{K: 3, Using: "code"})
+		byDoc, _ = coll.Search(types.Vector{Embedding: []float32{1, 0}}, engine.SearchParams{K: 3, Using: "docstring"})
+		if ids := fmt.Sprint(resultIDs(byCode), resultIDs(byDoc)); ids != "[main.rs] []" {
+			t.Errorf("Expected only main.rs left in the named indexes, got %s", ids)
+		}
+	})
+
+	t.Run("Scroll", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "pages", Dimensions: 2})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		vectors := make([]types.Vector, 25)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("v%02d", i), Embedding: []float32{float32(i), 1},
+				Metadata: map[string]interface{}{"group": i % 3}}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+
+		// Writes between pages don't make the scroll skip or repeat the
+		// vectors that are there throughout
+		seen := make(map[string]int)
+		cursor, pages := "", 0
+		for {
+			page, next, err := coll.Scroll(engine.ScrollParams{Cursor: cursor, Limit: 10})
+			if err != nil {
+				t.Fatalf("Scroll failed: %v", err)
+			}
+			pages++
+			for _, v := range page {
+				seen[v.ID]++
+				if v.Embedding != nil || v.Metadata == nil {
+					t.Errorf("Expected metadata without embeddings, got %+v", v)
+				}
+			}
+			if next == "" {
+				break
+			}
+			if pages == 1 {
+				coll.Delete("v03")
+				coll.Delete("v20")
+				coll.Insert(types.Vector{ID: "v00a", Embedding: []float32{0, 0}, Metadata: map[string]interface{}{"group": 0}})
+				coll.Insert(types.Vector{ID: "v99", Embedding: []float32{0, 0}, Metadata: map[string]interface{}{"group": 0}})
+			}
+			cursor = next
+		}
+		if pages != 3 {
+			t.Errorf("Expected 3 pages, got %d", pages)
+		}
+		for i := range vectors {
+			id := vectors[i].ID
+			if id != "v20" && seen[id] != 1 {
+				t.Errorf("Expected %s exactly once, got %d", id, seen[id])
+			}
+		}
+		if seen["v20"] != 0 || seen["v00a"] != 0 || seen["v99"] != 1 {
+			t.Errorf("Expected writes behind the cursor unseen and ahead of it seen, got %v", seen)
+		}
+
+		page, next, _ := coll.Scroll(engine.ScrollParams{Limit: 5, IncludeVecs: true,
+			Filter: &filter.Filter{Field: "group", Op: filter.OpEq, Value: 1}})
+		if ids := fmt.Sprint(resultIDs(wrapVectors(page))); ids != "[v01 v04 v07 v10 v13]" || next != "v13" || len(page[0].Embedding) != 2 {
+			t.Errorf("Expected the first filtered page with embeddings, got %s (next %q)", ids, next)
+		}
+		page, next, _ = coll.Scroll(engine.ScrollParams{Cursor: next, Limit: 5,
+			Filter: &filter.Filter{Field: "group", Op: filter.OpEq, Value: 1}})
+		if ids := fmt.Sprint(resultIDs(wrapVectors(page))); ids != "[v16 v19 v22]" || next != "" {
+			t.Errorf("Expected the last filtered page, got %s (next %q)", ids, next)
+		}
+	})
+
+	t.Run("MultiVectors", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{Name: "snippets", Dimensions: 1,
+			Vectors: map[string]engine.VectorConfig{"tokens": {Dimensions: 2, Metric: "dot", Multivector: true}}})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+
+		snippets := []types.Vector{
+			{ID: "a", Embedding: []float32{1}, MultiVectors: map[string][][]float32{"tokens": {{1, 0}, {0, 1}}}},
+			{ID: "b", Embedding: []float32{1}, Metadata: map[string]interface{}{"lang": "rust"},
+				MultiVectors: map[string][][]float32{"tokens": {{1, 0}, {1, 0}, {1, 0}}}},
+			{ID: "c", Embedding: []float32{1}, MultiVectors: map[string][][]float32{"tokens": {{0, 1}}}},
+			{ID: "plain", Embedding: []float32{1}},
+		}
+		if _, err := coll.BatchInsert(snippets); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if _, err := coll.Insert(types.Vector{ID: "x", Embedding: []float32{1}, Vectors: map[string][]float32{"tokens": {1, 0}}}); !errors.Is(err, engine.ErrNoSuchVector) {
+			t.Errorf("Expected a multi-vector given as a named vector to be rejected, got %v", err)
+		}
+		if _, err := coll.Insert(types.Vector{ID: "x", Embedding: []float32{1}, MultiVectors: map[string][][]float32{"tokens": {{1, 0}, {1}}}}); err == nil {
+			t.Errorf("Expected a token of the wrong dimension to be rejected")
+		}
+		eng1.Stop()
+
+		// The token index is snapshotted and restored with the collection
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+		coll, _ = eng2.Collection("snippets")
+		if v, ok := coll.Get("b"); !ok || len(v.MultiVectors["tokens"]) != 3 {
+			t.Fatalf("Expected the multi-vectors to be restored, got %+v", v)
+		}
+
+		// MaxSim: a scores 1 + 1, b 1 + 0 and c 0 + 1
+		query := types.Vector{MultiVectors: map[string][][]float32{"tokens": {{1, 0}, {0, 1}}}}
+		found, err := coll.Search(query, engine.SearchParams{K: 3, Using: "tokens"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if ids := fmt.Sprint(resultIDs(found)); ids != "[a b c]" || found[0].Score != 2 || found[1].Score != 1 {
+			t.Errorf("Expected a, then b and c tied, got %s (scores %v, %v)", ids, found[0].Score, found[1].Score)
+		}
+		exact, _ := coll.Search(query, engine.SearchParams{K: 3, Using: "tokens", Exact: true})
+		if fmt.Sprint(resultIDs(exact)) != fmt.Sprint(resultIDs(found)) {
+			t.Errorf("Expected the exact search to agree, got %v", resultIDs(exact))
+		}
+		filtered, _ := coll.Search(query, engine.SearchParams{K: 3, Using: "tokens",
+			Filter: &filter.Filter{Field: "lang", Op: filter.OpEq, Value: "rust"}})
+		threshold := float32(1.5)
+		thresholded, _ := coll.Search(query, engine.SearchParams{K: 3, Using: "tokens", Threshold: &threshold})
+		if ids := fmt.Sprint(resultIDs(filtered), resultIDs(thresholded)); ids != "[b] [a]" {
+			t.Errorf("Expected the filter and threshold to apply, got %s", ids)
+		}
+		if _, err := coll.Search(types.Vector{Embedding: []float32{1, 0}}, engine.SearchParams{K: 1, Using: "tokens"}); err == nil {
+			t.Errorf("Expected a search without tokens to be rejected")
+		}
+		if _, err := coll.Search(query, engine.SearchParams{K: 1, Using: "tokens", MMR: &engine.MMRParams{Lambda: 0.5}}); !errors.Is(err, engine.ErrMultiVectorSearch) {
+			t.Errorf("Expected ErrMultiVectorSearch for MMR, got %v", err)
+		}
+		if _, err := coll.Recommend(engine.RecommendQuery{Positive: []string{"a"}}, engine.SearchParams{K: 1, Using: "tokens"}); !errors.Is(err, engine.ErrMultiVectorSearch) {
+			t.Errorf("Expected ErrMultiVectorSearch for recommend, got %v", err)
+		}
+
+		// Replacing a vector with fewer tokens drops the others, and one
+		// without the multi-vector drops it from the index
+		coll.Insert(types.Vector{ID: "b", Embedding: []float32{1}, MultiVectors: map[string][][]float32{"tokens": {{0, 3}}}})
+		coll.Insert(types.Vector{ID: "a", Embedding: []float32{1}})
+		found, _ = coll.Search(query, engine.SearchParams{K: 3, Using: "tokens"})
+		if ids := fmt.Sprint(resultIDs(found)); ids != "[b c]" || found[0].Score != 3 {
+			t.Errorf("Expected b rescored and a gone, got %s", ids)
+		}
+		coll.Delete("c")
+		if ok, err := coll.Rebuild(nil, func() {}); !ok || err != nil {
+			t.Fatalf("Rebuild failed: %v", err)
+		}
+		if stats := coll.Stats()["vectors"].(map[string]interface{})["tokens"].(map[string]interface{}); stats["index"].(map[string]interface{})["tokens"] != 1 {
+			t.Errorf("Expected one token left after the rebuild, got %v", stats)
+		}
+	})
+
+	// Test 5: Snapshot restore replays only later writes
+	t.Run("SnapshotRestore", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		keep := types.Vector{ID: "snap-keep", Embedding: generateRandomVector(128)}
+		gone := types.Vector{ID: "snap-gone", Embedding: generateRandomVector(128)}
+		eng1.Insert(keep)
+		eng1.Insert(gone)
+		eng1.Stop()
+
+		// Keep a copy of the snapshot so the restart sees a stale one
+		stale := readIndexData(t, tempDir, log, "hnsw")
+
+		eng1, _ = engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+		added := types.Vector{ID: "snap-added", Embedding: generateRandomVector(128)}
+		eng1.Delete(gone.ID)
+		eng1.Insert(added)
+		eng1.Stop()
+
+		writeIndexData(t, tempDir, log, "hnsw", stale)
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		if err := eng2.Start(ctx); err != nil {
+			t.Fatalf("Failed to start engine: %v", err)
+		}
+		defer eng2.Stop()
+
+		for _, tc := range []struct {
+			vector types.Vector
+			found  bool
+		}{{keep, true}, {gone, false}, {added, true}} {
+			results, err := eng2.Search(tc.vector, engine.SearchParams{K: 1})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			found := len(results) > 0 && results[0].Vector.ID == tc.vector.ID
+			if found != tc.found {
+				t.Errorf("Vector %s: expected found=%v, got %v", tc.vector.ID, tc.found, found)
+			}
+		}
+	})
+
+	// Test 6: Collections with independent dimensions survive a restart
+	t.Run("Collections", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		code, err := eng1.CreateCollection(engine.CollectionConfig{Name: "code", Dimensions: 16})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		if _, err := eng1.CreateCollection(engine.CollectionConfig{Name: "code", Dimensions: 16}); err == nil {
+			t.Errorf("Expected duplicate collection to fail")
+		}
+
+		if _, err := code.Insert(types.Vector{ID: "shared-id", Embedding: generateRandomVector(16)}); err != nil {
+			t.Fatalf("Failed to insert into collection: %v", err)
+		}
+		if _, err := code.Insert(types.Vector{ID: "wrong-dims", Embedding: generateRandomVector(128)}); err == nil {
+			t.Errorf("Expected dimension mismatch for collection")
+		}
+		eng1.Insert(types.Vector{ID: "shared-id", Embedding: generateRandomVector(128)})
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		code, err = eng2.Collection("code")
+		if err != nil {
+			t.Fatalf("Collection not restored: %v", err)
+		}
+		vec, found := code.Get("shared-id")
+		if !found || len(vec.Embedding) != 16 {
+			t.Fatalf("Expected 16-dim vector in collection, got found=%v dims=%d", found, len(vec.Embedding))
+		}
+		vec, found = eng2.Get("shared-id")
+		if !found || len(vec.Embedding) != 128 {
+			t.Fatalf("Expected 128-dim vector in default collection, got found=%v dims=%d", found, len(vec.Embedding))
+		}
+
+		if err := eng2.DropCollection("code"); err != nil {
+			t.Fatalf("Failed to drop collection: %v", err)
+		}
+		if _, err := eng2.Collection("code"); err == nil {
+			t.Errorf("Expected dropped collection to be gone")
+		}
+		if err := eng2.DropCollection(engine.DefaultCollection); err == nil {
+			t.Errorf("Expected dropping the default collection to fail")
+		}
+	})
+
+	// Test 7: Writes that were logged but never applied are replayed
+	t.Run("WALReplay", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+		applied := types.Vector{ID: "wal-applied", Embedding: generateRandomVector(128)}
+		eng1.Insert(applied)
+		eng1.Stop()
+
+		// Simulate a crash between logging and applying
+		wal, err := persistence.OpenWAL(filepath.Join(tempDir, "wal.log"), log)
+		if err != nil {
+			t.Fatalf("Failed to open WAL: %v", err)
+		}
+		logged := types.Vector{ID: "wal-logged", Embedding: generateRandomVector(128)}
+		_, err = wal.Append(
+			&persistence.WALRecord{Op: persistence.WALPut, Collection: engine.DefaultCollection, Vector: logged},
+			&persistence.WALRecord{Op: persistence.WALDelete, Collection: engine.DefaultCollection, Vector: types.Vector{ID: applied.ID}},
+		)
+		if err != nil {
+			t.Fatalf("Failed to append to WAL: %v", err)
+		}
+		wal.Close()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		if _, found := eng2.Get(logged.ID); !found {
+			t.Errorf("Expected logged vector to be replayed into the store")
+		}
+		if _, found := eng2.Get(applied.ID); found {
+			t.Errorf("Expected logged delete to be replayed")
+		}
+		results, err := eng2.Search(logged, engine.SearchParams{K: 1})
+		if err != nil || len(results) == 0 || results[0].Vector.ID != logged.ID {
+			t.Errorf("Expected logged vector to be searchable, got %v (err %v)", results, err)
+		}
+
+		if err := eng2.BatchInsert([]types.Vector{
+			{ID: "batch-ok", Embedding: generateRandomVector(128)},
+			{ID: "batch-bad", Embedding: generateRandomVector(3)},
+		}); err == nil {
+			t.Errorf("Expected batch with wrong dimensions to be rejected")
+		}
+		if _, found := eng2.Get("batch-ok"); found {
+			t.Errorf("Expected rejected batch not to be persisted")
+		}
+	})
+
+	// Test 8: Upserts replace the indexed embedding and patches merge metadata
+	t.Run("Upsert", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		original := types.Vector{ID: "upsert", Embedding: generateRandomVector(128), Metadata: map[string]interface{}{"a": 1, "b": 2}}
+		if created, err := eng1.Upsert(original, false); err != nil || !created {
+			t.Fatalf("Expected vector to be created, got created=%v err=%v", created, err)
+		}
+		replaced := types.Vector{ID: "upsert", Embedding: generateRandomVector(128), Metadata: original.Metadata}
+		if created, err := eng1.Upsert(replaced, false); err != nil || created {
+			t.Fatalf("Expected vector to be replaced, got created=%v err=%v", created, err)
+		}
+		if _, err := eng1.Upsert(original, true); !errors.Is(err, engine.ErrVectorExists) {
+			t.Errorf("Expected ErrVectorExists, got %v", err)
+		}
+		if err := eng1.PatchMetadata("upsert", map[string]interface{}{"a": nil, "c": 3}); err != nil {
+			t.Fatalf("Failed to patch metadata: %v", err)
+		}
+		if err := eng1.PatchMetadata("upsert-missing", map[string]interface{}{"a": 1}); !errors.Is(err, engine.ErrVectorNotFound) {
+			t.Errorf("Expected ErrVectorNotFound, got %v", err)
+		}
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		vec, found := eng2.Get("upsert")
+		if !found {
+			t.Fatal("Upserted vector not persisted")
+		}
+		if vec.Embedding[0] != replaced.Embedding[0] {
+			t.Errorf("Expected the replaced embedding to be stored")
+		}
+		want := map[string]interface{}{"b": float64(2), "c": float64(3)}
+		if fmt.Sprint(vec.Metadata) != fmt.Sprint(want) {
+			t.Errorf("Expected merged metadata %v, got %v", want, vec.Metadata)
+		}
+
+		results, err := eng2.Search(replaced, engine.SearchParams{K: 1})
+		if err != nil || len(results) == 0 || results[0].Vector.ID != "upsert" || results[0].Score < 0.999 {
+			t.Errorf("Expected the replaced embedding to be indexed, got %v (err %v)", results, err)
+		}
+	})
+
+	// Test 9: Exact search matches a brute-force scan and honours filters
+	t.Run("ExactSearch", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "exact", Dimensions: 16, Metric: "euclidean"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		vectors := make([]types.Vector, 200)
+		for i := range vectors {
+			vectors[i] = types.Vector{
+				ID:        fmt.Sprintf("exact%d", i),
+				Embedding: generateRandomVector(16),
+				Metadata:  map[string]interface{}{"even": i%2 == 0},
+			}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+
+		query := types.Vector{Embedding: generateRandomVector(16)}
+		nearest, nearestDist := "", float32(math.MaxFloat32)
+		for _, v := range vectors {
+			if d, _ := vectormath.Euclidean.Distance(query.Embedding, v.Embedding); d < nearestDist {
+				nearest, nearestDist = v.ID, d
+			}
+		}
+
+		results, err := coll.Search(query, engine.SearchParams{K: 10, Exact: true})
+		if err != nil || len(results) != 10 {
+			t.Fatalf("Expected 10 exact results, got %d (err %v)", len(results), err)
+		}
+		if results[0].Vector.ID != nearest {
+			t.Errorf("Expected %s as exact nearest, got %s", nearest, results[0].Vector.ID)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Distance < results[i-1].Distance {
+				t.Errorf("Exact results not sorted by distance at %d", i)
+			}
+		}
+
+		approx, err := coll.Search(query, engine.SearchParams{K: 10, Ef: 1000})
+		if err != nil || len(approx) != 10 || approx[0].Vector.ID != nearest {
+			t.Errorf("Expected search with ef 1000 to find %s, got %v (err %v)", nearest, approx, err)
+		}
+
+		filtered, err := coll.Search(query, engine.SearchParams{
+			K:           5,
+			Exact:       true,
+			IncludeMeta: true,
+			Filter:      &filter.Filter{Field: "even", Op: filter.OpEq, Value: true},
+		})
+		if err != nil || len(filtered) != 5 {
+			t.Fatalf("Expected 5 filtered exact results, got %d (err %v)", len(filtered), err)
+		}
+		for _, r := range filtered {
+			if r.Vector.Metadata["even"] != true {
+				t.Errorf("Result %s does not match filter", r.Vector.ID)
+			}
+		}
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "threshold", Dimensions: 2, Metric: "dot"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		coll.BatchInsert([]types.Vector{
+			{ID: "ahead", Embedding: []float32{1, 0}},
+			{ID: "aside", Embedding: []float32{0, 1}},
+			{ID: "behind", Embedding: []float32{-1, 0}},
+		})
+
+		// Dot products below zero are kept unless a threshold is set
+		query := types.Vector{Embedding: []float32{1, 0}}
+		results, err := coll.Search(query, engine.SearchParams{K: 3})
+		if ids := fmt.Sprint(resultIDs(results)); err != nil || ids != "[ahead aside behind]" || results[2].Score != -1 {
+			t.Errorf("Expected every vector with behind scoring -1, got %s (err %v)", ids, err)
+		}
+		zero := float32(0)
+		results, _ = coll.Search(query, engine.SearchParams{K: 3, Threshold: &zero})
+		if ids := fmt.Sprint(resultIDs(results)); ids != "[ahead aside]" {
+			t.Errorf("Expected the threshold to drop behind, got %s", ids)
+		}
+	})
+
+	// Test 10:IVF-PQ collections train, re-rank and survive a restart
+	t.Run("IVFPQ", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		if _, err := eng1.CreateCollection(engine.CollectionConfig{Name: "bad-pq", Dimensions: 32, IndexType: "ivfpq", PQSubvectors: 5}); err == nil {
+			t.Errorf("Expected pq_subvectors that don't divide dimensions to fail")
+		}
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{
+			Name:       "pq",
+			Dimensions: 32,
+			IndexType:  engine.IndexIVFPQ,
+			NList:      8,
+			NProbe:     8,
+			TrainSize:  300,
+			Rerank:     4,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+
+		vectors := make([]types.Vector, 600)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("pq%d", i), Embedding: generateRandomVector(32)}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if trained := coll.Stats()["index_trained"]; trained != true {
+			t.Errorf("Expected the index to be trained, got %v", trained)
+		}
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		coll, err = eng2.Collection("pq")
+		if err != nil {
+			t.Fatalf("Collection not restored: %v", err)
+		}
+		if size := coll.Stats()["index_vectors"]; size != len(vectors) {
+			t.Errorf("Expected %d vectors after restart, got %v", len(vectors), size)
+		}
+		for _, v := range vectors[:20] {
+			results, err := coll.Search(v, engine.SearchParams{K: 1})
+			if err != nil || len(results) != 1 || results[0].Vector.ID != v.ID || results[0].Score < 0.999 {
+				t.Errorf("Expected %s with its exact score, got %v (err %v)", v.ID, results, err)
+			}
+		}
+	})
+
+	t.Run("Flat", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{
+			Name:       "brute",
+			Dimensions: 16,
+			Metric:     "euclidean",
+			IndexType:  engine.IndexFlat,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		vectors := make([]types.Vector, 300)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("brute%d", i), Embedding: generateRandomVector(16)}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		coll, err = eng2.Collection("brute")
+		if err != nil {
+			t.Fatalf("Collection not restored: %v", err)
+		}
+		if typ := coll.Stats()["index_type"]; typ != engine.IndexFlat {
+			t.Errorf("Expected a flat index, got %v", typ)
+		}
+		// The flat index agrees with a scan of the store
+		for i := 0; i < 10; i++ {
+			query := types.Vector{Embedding: generateRandomVector(16)}
+			got, err := coll.Search(query, engine.SearchParams{K: 5})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			want, _ := coll.Search(query, engine.SearchParams{K: 5, Exact: true})
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Flat search returned %v, want %v", got, want)
+			}
+		}
+	})
+
+	t.Run("IVFFlat", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{
+			Name:       "flat",
+			Dimensions: 16,
+			IndexType:  engine.IndexIVFFlat,
+			NList:      8,
+			NProbe:     2,
+			TrainSize:  1000,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+
+		vectors := make([]types.Vector, 400)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("flat%d", i), Embedding: generateRandomVector(16)}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if trained := coll.Stats()["index_trained"]; trained != false {
+			t.Errorf("Expected the index to wait for its train size, got trained=%v", trained)
+		}
+
+		// Retraining samples the store, so it works below the train size
+		if ok, err := coll.Retrain(); err != nil || !ok {
+			t.Fatalf("Expected the index to retrain, got %v (err %v)", ok, err)
+		}
+		if lists := coll.Stats()["index_lists"]; lists != 8 {
+			t.Errorf("Expected 8 lists after retraining, got %v", lists)
+		}
+		hnsw, _ := eng1.Collection(engine.DefaultCollection)
+		if ok, err := hnsw.Retrain(); err != nil || ok {
+			t.Errorf("Expected the HNSW collection not to retrain, got %v (err %v)", ok, err)
+		}
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		coll, err = eng2.Collection("flat")
+		if err != nil {
+			t.Fatalf("Collection not restored: %v", err)
+		}
+		if trained := coll.Stats()["index_trained"]; trained != true {
+			t.Errorf("Expected the restored index to be trained, got %v", trained)
+		}
+		for _, v := range vectors[:20] {
+			results, err := coll.Search(v, engine.SearchParams{K: 1, NProbe: 8})
+			if err != nil || len(results) != 1 || results[0].Vector.ID != v.ID {
+				t.Errorf("Expected %s scanning every list, got %v (err %v)", v.ID, results, err)
+			}
+		}
+	})
+
+	t.Run("Optimize", func(t *testing.T) {
+		eng, _ := engine.NewEngine(cfg, log)
+		eng.Start(ctx)
+		defer eng.Stop()
+
+		coll, err := eng.CreateCollection(engine.CollectionConfig{Name: "opt", Dimensions: 16})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		vectors := make([]types.Vector, 300)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("opt%d", i), Embedding: generateRandomVector(16)}
+		}
+		coll.BatchInsert(vectors)
+		for _, v := range vectors[:100] {
+			coll.Delete(v.ID)
+		}
+		if tombstones := coll.Stats()["index_tombstones"]; tombstones != 100 {
+			t.Fatalf("Expected 100 tombstones, got %v", tombstones)
+		}
+
+		wait := func(job engine.JobStatus) engine.JobStatus {
+			deadline := time.Now().Add(10 * time.Second)
+			for job.State == engine.JobRunning && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				job, _ = eng.OptimizeJob(job.ID)
+			}
+			return job
+		}
+
+		// Writes made while the new index is built must reach it, even with
+		// a compaction of the store asked for meanwhile: it has to wait, or
+		// it could drop the deletion before it is caught up with
+		late := types.Vector{ID: "late", Embedding: generateRandomVector(16)}
+		var compaction engine.JobStatus
+		calls := 0
+		ok, err := coll.Rebuild(nil, func() {
+			if calls++; calls == 1 {
+				coll.Insert(late)
+				coll.Delete(vectors[100].ID)
+				compaction, _ = eng.Optimize([]string{engine.DefaultCollection}, false)
+			}
+		})
+		if err != nil || !ok {
+			t.Fatalf("Rebuild failed: %v (err %v)", ok, err)
+		}
+		if compaction = wait(compaction); compaction.State != engine.JobCompleted {
+			t.Fatalf("Expected the compaction to complete, got %+v", compaction)
+		}
+		// Only the delete caught up after the build leaves a tombstone
+		stats := coll.Stats()
+		if stats["index_tombstones"] != 1 || stats["index_vectors"] != 200 {
+			t.Errorf("Expected 200 vectors and 1 tombstone, got %v and %v", stats["index_vectors"], stats["index_tombstones"])
+		}
+		if results, _ := coll.Search(late, engine.SearchParams{K: 1}); len(results) != 1 || results[0].Vector.ID != "late" {
+			t.Errorf("Expected the vector written during the rebuild to be searchable, got %v", results)
+		}
+		if results, _ := coll.Search(vectors[100], engine.SearchParams{K: 1}); len(results) == 1 && results[0].Vector.ID == vectors[100].ID {
+			t.Errorf("Expected the vector deleted during the rebuild to be gone")
+		}
+
+		for _, v := range vectors[101:150] {
+			coll.Delete(v.ID)
+		}
+		if _, err := eng.Optimize([]string{"missing"}, false); !errors.Is(err, engine.ErrNoSuchCollection) {
+			t.Errorf("Expected optimizing a missing collection to fail, got %v", err)
+		}
+		job, err := eng.Optimize(nil, false)
+		if err != nil {
+			t.Fatalf("Failed to start optimize job: %v", err)
+		}
+		if job = wait(job); job.State != engine.JobCompleted || job.Progress() != 1 {
+			t.Fatalf("Expected the job to complete, got %+v", job)
+		}
+		// Only the collection with tombstones needed rebuilding
+		if fmt.Sprint(job.Rebuilt) != "[opt]" || job.Total != 151 || job.Indexed != 151 {
+			t.Errorf("Expected only opt rebuilt from 151 vectors, got %+v", job)
+		}
+		if tombstones := coll.Stats()["index_tombstones"]; tombstones != 0 {
+			t.Errorf("Expected no tombstones after optimizing, got %v", tombstones)
+		}
+		if _, ok := eng.OptimizeJob("optimize-0"); ok {
+			t.Errorf("Expected an unknown job to be missing")
+		}
+	})
+
+	t.Run("Quantization", func(t *testing.T) {
+		eng1, _ := engine.NewEngine(cfg, log)
+		eng1.Start(ctx)
+
+		if _, err := eng1.CreateCollection(engine.CollectionConfig{Name: "bad-quant", Dimensions: 32, Quantization: "int4"}); err == nil {
+			t.Errorf("Expected an unknown quantization to fail")
+		}
+		if _, err := eng1.CreateCollection(engine.CollectionConfig{Name: "bad-quant", Dimensions: 32, IndexType: engine.IndexIVFPQ, Quantization: "int8"}); err == nil {
+			t.Errorf("Expected quantizing an IVF-PQ index to fail")
+		}
+		coll, err := eng1.CreateCollection(engine.CollectionConfig{
+			Name:         "quantized",
+			Dimensions:   32,
+			Quantization: "binary",
+			TrainSize:    200,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+
+		vectors := make([]types.Vector, 400)
+		for i := range vectors {
+			vectors[i] = types.Vector{ID: fmt.Sprintf("bq%d", i), Embedding: generateRandomVector(32)}
+		}
+		if _, err := coll.BatchInsert(vectors); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if quantized := coll.Stats()["index_quantized"]; quantized != true {
+			t.Errorf("Expected the index to be quantized, got %v", quantized)
+		}
+		eng1.Stop()
+
+		eng2, _ := engine.NewEngine(cfg, log)
+		eng2.Start(ctx)
+		defer eng2.Stop()
+
+		coll, err = eng2.Collection("quantized")
+		if err != nil {
+			t.Fatalf("Collection not restored: %v", err)
+		}
+		if quantized := coll.Stats()["index_quantized"]; quantized != true {
+			t.Errorf("Expected the restored index to be quantized, got %v", quantized)
+		}
+		// Rescoring from the store gives back exact scores
+		for _, v := range vectors[:20] {
+			results, err := coll.Search(v, engine.SearchParams{K: 1})
+			if err != nil || len(results) != 1 || results[0].Vector.ID != v.ID || results[0].Score < 0.999 {
+				t.Errorf("Expected %s with its exact score, got %v (err %v)", v.ID, results, err)
+			}
+		}
+	})
+}
+
+func readIndexData(t *testing.T, path string, log logger.Logger, name string) []byte {
+	store, err := persistence.NewBadgerStore(path, log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	r, found, err := store.LoadIndexData(name)
+	if err != nil || !found {
+		t.Fatalf("Failed to load index data: found=%v err=%v", found, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read index data: %v", err)
+	}
+	return data
+}
+
+func writeIndexData(t *testing.T, path string, log logger.Logger, name string, data []byte) {
+	store, err := persistence.NewBadgerStore(path, log)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	err = store.SaveIndexData(name, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to save index data: %v", err)
+	}
 }
 
 func generateRandomVector(dim int) []float32 {
@@ -132,3 +1319,19 @@ func generateRandomVector(dim int) []float32 {
 	}
 	return vec
 }
+
+func resultIDs(results []types.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Vector.ID
+	}
+	return ids
+}
+
+func wrapVectors(vectors []types.Vector) []types.SearchResult {
+	results := make([]types.SearchResult, len(vectors))
+	for i, v := range vectors {
+		results[i].Vector = v
+	}
+	return results
+}